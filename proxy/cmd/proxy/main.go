@@ -27,7 +27,7 @@ func main() {
 
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
-		case "serve", "index-messages", "find-conversations", "help", "-h", "--help":
+		case "serve", "index-messages", "find-conversations", "route-test", "pricing", "help", "-h", "--help":
 			cmd = os.Args[1]
 			args = os.Args[2:]
 		default:
@@ -44,6 +44,10 @@ func main() {
 		err = cli.RunIndexMessages(args)
 	case "find-conversations":
 		err = cli.RunFindConversations(args)
+	case "route-test":
+		err = cli.RunRouteTest(args)
+	case "pricing":
+		err = cli.RunPricing(args)
 	case "help", "-h", "--help":
 		printUsage()
 		return
@@ -65,6 +69,8 @@ Commands:
   serve              Start the proxy server (default)
   index-messages     Index requests into the messages table
   find-conversations Find conversation chain for a request
+  route-test         Replay stored requests through a candidate config
+  pricing import     Import model prices from a price sheet
   help               Show this help message
 
 Run 'proxy <command> --help' for more information on a command.
@@ -74,7 +80,9 @@ Examples:
   proxy serve                              # Start server explicitly
   proxy index-messages --db requests.db
   proxy index-messages --db requests.db --recreate
-  proxy find-conversations --id abc123`)
+  proxy find-conversations --id abc123
+  proxy route-test --config candidate.yaml --since 2025-01-01
+  proxy pricing import --db requests.db prices.yaml`)
 }
 
 func runServe(args []string) error {
@@ -85,13 +93,14 @@ func runServe(args []string) error {
 		logger.Fatalf("❌ Failed to load configuration: %v", err)
 	}
 
-	// Initialize providers
-	providers := make(map[string]provider.Provider)
-	providers["anthropic"] = provider.NewAnthropicProvider(&cfg.Providers.Anthropic)
-	providers["openai"] = provider.NewOpenAIProvider(&cfg.Providers.OpenAI)
-
-	// Initialize model router
-	modelRouter := service.NewModelRouter(cfg, providers, logger)
+	// The reloader builds the providers and model router, and rebuilds them when config.yaml
+	// or an agent definition changes
+	configReloader, err := service.NewConfigReloader(cfg, func(cfg *config.Config, reuse map[string]provider.Provider) (*service.ModelRouter, error) {
+		return newModelRouter(cfg, reuse, logger)
+	}, logger)
+	if err != nil {
+		logger.Fatalf("❌ Failed to initialize providers: %v", err)
+	}
 
 	// Use legacy anthropic service for backward compatibility
 	anthropicService := service.NewAnthropicService(&cfg.Anthropic)
@@ -103,7 +112,7 @@ func runServe(args []string) error {
 	}
 	logger.Println("🗄️ SQLite database ready")
 
-	h := handler.New(anthropicService, storageService, logger, modelRouter)
+	h := handler.New(anthropicService, storageService, logger, configReloader)
 
 	r := mux.NewRouter()
 
@@ -127,9 +136,16 @@ func runServe(args []string) error {
 	r.HandleFunc("/api/stats", h.GetStats).Methods("GET")
 	r.HandleFunc("/api/stats/hourly", h.GetHourlyStats).Methods("GET")
 	r.HandleFunc("/api/stats/models", h.GetModelStats).Methods("GET")
+	r.HandleFunc("/api/stats/agents", h.GetAgentStats).Methods("GET")
 	r.HandleFunc("/api/usage", h.GetUsage).Methods("GET")
 	r.HandleFunc("/api/usage/hourly", h.GetHourlyUsage).Methods("GET")
 	r.HandleFunc("/api/pricing", h.GetPricing).Methods("GET")
+	r.HandleFunc("/api/pricing", h.CreatePricing).Methods("POST")
+	r.HandleFunc("/api/pricing", h.UpdatePricing).Methods("PUT")
+	r.HandleFunc("/api/pricing", h.DeletePricing).Methods("DELETE")
+	r.HandleFunc("/api/shadow", h.GetShadowPairs).Methods("GET")
+	r.HandleFunc("/api/budgets", h.GetBudgets).Methods("GET")
+	r.HandleFunc("/api/config", h.GetConfig).Methods("GET")
 	r.HandleFunc("/api/conversations", h.GetConversations).Methods("GET")
 	r.HandleFunc("/api/conversations/{id}", h.GetConversationByID).Methods("GET")
 	r.HandleFunc("/api/conversations/project", h.GetConversationsByProject).Methods("GET")
@@ -164,6 +180,26 @@ func runServe(args []string) error {
 		}
 	}()
 
+	// Reload on SIGHUP and, unless polling is disabled, when a watched file changes
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	if interval := cfg.Reload.PollIntervalDuration; interval > 0 {
+		go configReloader.Watch(watchCtx, interval)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logger.Println("🔄 SIGHUP received, reloading configuration")
+			if err := configReloader.Reload(); err != nil {
+				logger.Printf("❌ Failed to reload configuration, keeping the active one: %v", err)
+			} else {
+				logger.Println("✅ Configuration reloaded")
+			}
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -180,3 +216,20 @@ func runServe(args []string) error {
 	logger.Println("✅ Server exited")
 	return nil
 }
+
+// newModelRouter creates the providers of a config, each behind a circuit breaker, and the
+// model router that picks between them. Providers in reuse are kept as they are.
+func newModelRouter(cfg *config.Config, reuse map[string]provider.Provider, logger *log.Logger) (*service.ModelRouter, error) {
+	providers, err := provider.NewProviders(&cfg.Providers, cfg.Models)
+	if err != nil {
+		return nil, err
+	}
+	for name, p := range providers {
+		if previous, ok := reuse[name]; ok {
+			providers[name] = previous
+			continue
+		}
+		providers[name] = provider.NewCircuitBreaker(p, &cfg.CircuitBreaker)
+	}
+	return service.NewModelRouter(cfg, providers, logger), nil
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+	"github.com/seifghazi/claude-code-monitor/internal/service"
+)
+
+type PricingImportOptions struct {
+	DBPath string
+	File   string
+	DryRun bool
+}
+
+func RunPricing(args []string) error {
+	if len(args) == 0 || args[0] != "import" {
+		fmt.Println(`Usage: proxy pricing <command> [options]
+
+Commands:
+  import   Import model prices from a YAML or JSON price sheet`)
+		if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+			return nil
+		}
+		return fmt.Errorf("unknown pricing command '%s'", args[0])
+	}
+	return runPricingImport(args[1:])
+}
+
+func runPricingImport(args []string) error {
+	fs := flag.NewFlagSet("pricing import", flag.ExitOnError)
+	opts := &PricingImportOptions{}
+
+	fs.StringVar(&opts.DBPath, "db", "requests.db", "Path to SQLite database")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Validate the price sheet without writing it")
+
+	fs.Usage = func() {
+		fmt.Println(`Usage: proxy pricing import [options] [file]
+
+Import model prices from a YAML or JSON price sheet, a "models" list in the format
+GET /api/pricing returns. Prices are in USD per million tokens. Every entry is validated
+before anything is written, and rows with the same model, pricing_date and pricing_tier
+are replaced. Without a file the built-in Anthropic and OpenAI prices are imported.
+
+Options:`)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one price sheet, got %d", fs.NArg())
+	}
+	opts.File = fs.Arg(0)
+
+	var prices []model.PricingModel
+	var err error
+	if opts.File == "" {
+		prices, err = service.DefaultPriceSheet()
+	} else {
+		var data []byte
+		data, err = os.ReadFile(opts.File)
+		if err != nil {
+			return fmt.Errorf("failed to read price sheet: %w", err)
+		}
+		prices, err = service.ParsePriceSheet(data)
+	}
+	if err != nil {
+		return err
+	}
+
+	source := opts.File
+	if source == "" {
+		source = "the built-in price sheet"
+	}
+	if opts.DryRun {
+		fmt.Printf("%d prices in %s are valid\n", len(prices), source)
+		return nil
+	}
+
+	if _, err := os.Stat(opts.DBPath); os.IsNotExist(err) {
+		return fmt.Errorf("database file '%s' not found", opts.DBPath)
+	}
+
+	storage, err := service.NewSQLiteStorageService(&config.StorageConfig{DBPath: opts.DBPath})
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := storage.ImportPricing(prices); err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d prices from %s into %s\n", len(prices), source, opts.DBPath)
+	return nil
+}
@@ -0,0 +1,302 @@
+package cli
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+	"github.com/seifghazi/claude-code-monitor/internal/provider"
+	"github.com/seifghazi/claude-code-monitor/internal/service"
+)
+
+type RouteTestOptions struct {
+	DBPath     string
+	ConfigPath string
+	Since      string
+	Limit      int
+	Verbose    bool
+}
+
+// replayedRequest is a stored request with the route it took and the tokens it used
+type replayedRequest struct {
+	ID        string
+	Timestamp string
+	Date      string // UTC date of the request, prices in effect on it apply
+	Body      string
+	Headers   string
+	OldModel  string
+	Usage     service.UsageTokens
+}
+
+// routeChange aggregates the requests that moved from one model to another
+type routeChange struct {
+	From       string
+	To         string
+	Requests   int
+	CostBefore float64
+	CostAfter  float64
+}
+
+func RunRouteTest(args []string) error {
+	fs := flag.NewFlagSet("route-test", flag.ExitOnError)
+	opts := &RouteTestOptions{}
+
+	fs.StringVar(&opts.DBPath, "db", "requests.db", "Path to SQLite database")
+	fs.StringVar(&opts.ConfigPath, "config", "", "Candidate config file to route with (required)")
+	fs.StringVar(&opts.Since, "since", "", "Only replay requests from this date or timestamp on")
+	fs.IntVar(&opts.Limit, "limit", 0, "Replay at most this many of the most recent requests (0: all)")
+	fs.BoolVar(&opts.Verbose, "verbose", false, "List every rerouted request and show router logs")
+
+	fs.Usage = func() {
+		fmt.Println(`Usage: proxy route-test --config <file> [options]
+
+Replay stored requests through a router built from a candidate config and show which
+requests would go to a different model. Cost impact is estimated with the pricing table,
+assuming the new model would use as many tokens as the old one did.
+
+Options:`)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if opts.ConfigPath == "" {
+		fs.Usage()
+		return fmt.Errorf("--config is required")
+	}
+
+	if _, err := os.Stat(opts.DBPath); os.IsNotExist(err) {
+		return fmt.Errorf("database file '%s' not found", opts.DBPath)
+	}
+
+	cfg, err := config.LoadFile(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	// Shadow sampling is random and doesn't change where requests go
+	cfg.Shadow.Enable = false
+
+	logger := log.New(io.Discard, "", 0)
+	if opts.Verbose {
+		logger = log.New(os.Stdout, "router: ", 0)
+	}
+
+	// Providers are only needed for their names, nothing is forwarded
+	providers, err := provider.NewProviders(&cfg.Providers, cfg.Models)
+	if err != nil {
+		return fmt.Errorf("failed to initialize providers: %w", err)
+	}
+	router := service.NewModelRouter(cfg, providers, logger)
+
+	dbPath := opts.DBPath + "?_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL"
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	pricing, err := loadPricing(db)
+	if err != nil {
+		return err
+	}
+
+	requests, err := fetchReplayRequests(db, opts.Since, opts.Limit)
+	if err != nil {
+		return fmt.Errorf("failed to fetch requests: %w", err)
+	}
+
+	changes := map[string]*routeChange{}
+	var replayed, skipped, rerouted, rejected int
+	var totalBefore, totalAfter float64
+
+	for _, r := range requests {
+		var req model.AnthropicRequest
+		if err := json.Unmarshal([]byte(r.Body), &req); err != nil || req.Model == "" {
+			skipped++
+			continue
+		}
+		var header http.Header
+		json.Unmarshal([]byte(r.Headers), &header)
+
+		decision, err := router.DetermineRoute(&req, header)
+		var contextErr *service.ContextLengthError
+		if err != nil && !errors.As(err, &contextErr) {
+			skipped++
+			continue
+		}
+		replayed++
+
+		// A rejected request isn't a saving, the client retries it elsewhere. It is only counted.
+		if contextErr != nil {
+			rejected++
+			if opts.Verbose {
+				fmt.Printf("  %-26s %-18s %s rejected  %s\n", r.Timestamp, r.ID, r.OldModel, contextErr.Error())
+			}
+			continue
+		}
+
+		costBefore := replayCost(pricing, r.OldModel, r.Date, r.Usage)
+		totalBefore += costBefore
+		if decision.TargetModel == r.OldModel {
+			totalAfter += costBefore
+			continue
+		}
+		newModel := decision.TargetModel
+		costAfter := replayCost(pricing, newModel, r.Date, r.Usage)
+		reason := decision.Detail
+		totalAfter += costAfter
+		rerouted++
+
+		key := r.OldModel + "\x00" + newModel
+		change, ok := changes[key]
+		if !ok {
+			change = &routeChange{From: r.OldModel, To: newModel}
+			changes[key] = change
+		}
+		change.Requests++
+		change.CostBefore += costBefore
+		change.CostAfter += costAfter
+
+		if opts.Verbose {
+			fmt.Printf("  %-26s %-18s %s → %s  %s\n", r.Timestamp, r.ID, r.OldModel, newModel, reason)
+		}
+	}
+
+	fmt.Printf("\nReplayed %d requests from %s through %s", replayed, opts.DBPath, opts.ConfigPath)
+	if skipped > 0 {
+		fmt.Printf(" (%d skipped)", skipped)
+	}
+	fmt.Printf("\n\n")
+
+	if rejected > 0 {
+		fmt.Printf("Rejected: %d of %d requests would exceed their model's context window, they are left out of the cost impact\n\n", rejected, replayed)
+	}
+
+	if rerouted == 0 {
+		fmt.Println("No request would be routed differently.")
+		return nil
+	}
+
+	sorted := make([]*routeChange, 0, len(changes))
+	for _, c := range changes {
+		sorted = append(sorted, c)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Requests != sorted[j].Requests {
+			return sorted[i].Requests > sorted[j].Requests
+		}
+		return sorted[i].From+sorted[i].To < sorted[j].From+sorted[j].To
+	})
+
+	fmt.Printf("Rerouted: %d of %d requests\n\n", rerouted, replayed)
+
+	fmt.Printf("  %-32s %-32s %8s %12s %12s %12s\n", "From", "To", "Requests", "Cost before", "Cost after", "Delta")
+	for _, c := range sorted {
+		fmt.Printf("  %-32s %-32s %8d %12s %12s %12s\n",
+			c.From, c.To, c.Requests, formatDollars(c.CostBefore), formatDollars(c.CostAfter), formatDollars(c.CostAfter-c.CostBefore))
+	}
+
+	delta := totalAfter - totalBefore
+	fmt.Printf("\nEstimated cost impact: %s → %s (%s", formatDollars(totalBefore), formatDollars(totalAfter), formatDollars(delta))
+	if totalBefore > 0 {
+		fmt.Printf(", %+.1f%%", 100*delta/totalBefore)
+	}
+	fmt.Println(")")
+
+	return nil
+}
+
+func fetchReplayRequests(db *sql.DB, since string, limit int) ([]replayedRequest, error) {
+	query := `
+		SELECT r.id, r.timestamp, COALESCE(date(r.timestamp), ''), r.body, r.headers, COALESCE(NULLIF(r.routed_model, ''), r.model, ''),
+			COALESCE(u.input_tokens, 0), COALESCE(u.cache_creation_input_tokens, 0), COALESCE(u.cache_read_input_tokens, 0),
+			COALESCE(u.cache_creation_ephemeral_5m_input_tokens, 0), COALESCE(u.cache_creation_ephemeral_1h_input_tokens, 0),
+			COALESCE(u.output_tokens, 0)
+		FROM requests r
+		LEFT JOIN usage u ON u.id = r.id
+	`
+	args := []interface{}{}
+	if since != "" {
+		query += " WHERE datetime(r.timestamp) >= datetime(?)"
+		args = append(args, since)
+	}
+	query += " ORDER BY r.timestamp DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []replayedRequest
+	for rows.Next() {
+		var r replayedRequest
+		err := rows.Scan(&r.ID, &r.Timestamp, &r.Date, &r.Body, &r.Headers, &r.OldModel,
+			&r.Usage.Input, &r.Usage.CacheCreation, &r.Usage.CacheRead, &r.Usage.Cache5m, &r.Usage.Cache1h, &r.Usage.Output)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, r)
+	}
+	return requests, rows.Err()
+}
+
+func loadPricing(db *sql.DB) (*service.PricingTable, error) {
+	rows, err := db.Query(`
+		SELECT model, family, date(pricing_date), pricing_tier, input_tokens, output_tokens, cache_read_input_tokens,
+			cache_creation_ephemeral_5m_input_tokens, cache_creation_ephemeral_1h_input_tokens
+		FROM pricing
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pricing: %w", err)
+	}
+	defer rows.Close()
+
+	var prices []model.PricingModel
+	for rows.Next() {
+		var p model.PricingModel
+		err := rows.Scan(&p.Model, &p.Family, &p.PricingDate, &p.PricingTier, &p.InputTokens, &p.OutputTokens, &p.CacheReadInputTokens,
+			&p.CacheCreationEphemeral5mInputTokens, &p.CacheCreationEphemeral1hInputTokens)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan pricing: %w", err)
+		}
+		prices = append(prices, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return service.NewPricingTable(prices), nil
+}
+
+// replayCost prices the tokens of a request for a model in dollars, 0 when the model has no price
+func replayCost(pricing *service.PricingTable, modelName, date string, usage service.UsageTokens) float64 {
+	p, ok := pricing.LookupUsage(modelName, date, usage)
+	if !ok {
+		return 0
+	}
+	return usage.Cost(p) / 1_000_000
+}
+
+func formatDollars(v float64) string {
+	if v < 0 {
+		return fmt.Sprintf("-$%.2f", -v)
+	}
+	return fmt.Sprintf("$%.2f", v)
+}
@@ -1,9 +1,14 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -15,7 +20,18 @@ type Config struct {
 	Providers ProvidersConfig `yaml:"providers"`
 	Storage   StorageConfig   `yaml:"storage"`
 	Subagents SubagentsConfig `yaml:"subagents"`
-	Anthropic AnthropicConfig
+	// Failover maps a model to the ordered list of models to try when its upstream fails
+	Failover       map[string][]string  `yaml:"failover"`
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+	Models         ModelCatalog         `yaml:"models"`
+	Routing        RoutingConfig        `yaml:"routing"`
+	ContextRouting ContextRoutingConfig `yaml:"context_routing"`
+	Shadow         ShadowConfig         `yaml:"shadow"`
+	Budgets        BudgetsConfig        `yaml:"budgets"`
+	Reload         ReloadConfig         `yaml:"reload"`
+	Anthropic      AnthropicConfig      `yaml:"-"`
+	// Path is the file the config was loaded from
+	Path string `yaml:"-"`
 }
 
 type ServerConfig struct {
@@ -36,17 +52,81 @@ type TimeoutsConfig struct {
 type ProvidersConfig struct {
 	Anthropic AnthropicProviderConfig `yaml:"anthropic"`
 	OpenAI    OpenAIProviderConfig    `yaml:"openai"`
+	// Named holds every other entry under providers:, keyed by the name the router targets
+	Named map[string]ProviderConfig `yaml:"-"`
+}
+
+// ProviderConfig describes an additional Anthropic- or OpenAI-compatible upstream
+type ProviderConfig struct {
+	Type       string            `yaml:"type"` // "anthropic" or "openai"
+	BaseURL    string            `yaml:"base_url"`
+	APIKeyEnv  string            `yaml:"api_key_env"`
+	Version    string            `yaml:"version"`
+	MaxRetries int               `yaml:"max_retries"`
+	Headers    map[string]string `yaml:"headers"`
+}
+
+// UnmarshalYAML keeps the built-in anthropic and openai entries in their own fields and
+// collects any other provider into Named
+func (p *ProvidersConfig) UnmarshalYAML(value *yaml.Node) error {
+	var entries map[string]yaml.Node
+	if err := value.Decode(&entries); err != nil {
+		return err
+	}
+
+	for name, node := range entries {
+		var err error
+		switch name {
+		case "anthropic":
+			err = node.Decode(&p.Anthropic)
+		case "openai":
+			err = node.Decode(&p.OpenAI)
+		default:
+			var named ProviderConfig
+			if err = node.Decode(&named); err == nil {
+				if p.Named == nil {
+					p.Named = make(map[string]ProviderConfig)
+				}
+				p.Named[name] = named
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("providers.%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// MarshalYAML writes the built-in and named providers back as a single map, the inverse of
+// UnmarshalYAML
+func (p ProvidersConfig) MarshalYAML() (interface{}, error) {
+	entries := map[string]interface{}{
+		"anthropic": p.Anthropic,
+		"openai":    p.OpenAI,
+	}
+	for name, named := range p.Named {
+		entries[name] = named
+	}
+	return entries, nil
 }
 
 type AnthropicProviderConfig struct {
 	BaseURL    string `yaml:"base_url"`
 	Version    string `yaml:"version"`
 	MaxRetries int    `yaml:"max_retries"`
+	// Set for named providers only, the built-in one forwards the client's credentials
+	Name    string            `yaml:"-"`
+	APIKey  string            `yaml:"-"`
+	Headers map[string]string `yaml:"headers"`
 }
 
 type OpenAIProviderConfig struct {
-	BaseURL string `yaml:"base_url"`
-	APIKey  string `yaml:"api_key"`
+	BaseURL    string            `yaml:"base_url"`
+	APIKey     string            `yaml:"api_key"`
+	MaxRetries int               `yaml:"max_retries"`
+	Headers    map[string]string `yaml:"headers"`
+	// Set for named providers only
+	Name string `yaml:"-"`
 }
 
 type AnthropicConfig struct {
@@ -55,17 +135,161 @@ type AnthropicConfig struct {
 	MaxRetries int
 }
 
+type CircuitBreakerConfig struct {
+	// Consecutive upstream failures that open the circuit, 0 disables the breaker
+	FailureThreshold int    `yaml:"failure_threshold"`
+	Cooldown         string `yaml:"cooldown"`
+	// Parsed from Cooldown
+	CooldownDuration time.Duration `yaml:"-"`
+}
+
+type RoutingConfig struct {
+	// Rules are evaluated in order, the first one that matches decides the route
+	Rules []RoutingRule `yaml:"rules"`
+}
+
+type RoutingRule struct {
+	Name  string    `yaml:"name"`
+	Match RuleMatch `yaml:"match"`
+	// Target is a model, an alias or "provider/model". Empty keeps the requested model.
+	Target string `yaml:"target"`
+	// Overrides replace top-level request parameters such as temperature or max_tokens
+	Overrides map[string]interface{} `yaml:"overrides"`
+}
+
+// RuleMatch conditions are ANDed, unset ones always match
+type RuleMatch struct {
+	Model            string            `yaml:"model"`              // glob on the requested model
+	System           string            `yaml:"system"`             // regex on the system prompt
+	Tools            []string          `yaml:"tools"`              // tool names that must all be present
+	MinInputTokens   int               `yaml:"min_input_tokens"`   // estimated
+	MaxInputTokens   int               `yaml:"max_input_tokens"`   // estimated
+	Headers          map[string]string `yaml:"headers"`            // header name -> regex
+	UserAgentVersion string            `yaml:"user_agent_version"` // e.g. ">=1.0.80 <2.0.0"
+}
+
+// ContextRoutingConfig moves requests that won't fit their model's context window before they
+// reach the upstream
+type ContextRoutingConfig struct {
+	Enable bool `yaml:"enable"`
+	// Threshold is the share of the model's context window an estimated input may fill
+	Threshold float64 `yaml:"threshold"`
+	// Targets maps a model or glob to the long-context model oversized requests go to
+	Targets map[string]string `yaml:"targets"`
+	// Reject oversized requests that have no target instead of forwarding them
+	Reject bool `yaml:"reject"`
+}
+
+// ShadowConfig mirrors a sample of requests to a second model, to compare it with the model
+// that answered without affecting the client
+type ShadowConfig struct {
+	Enable bool `yaml:"enable"`
+	// SampleRate is the share of matching requests that are mirrored, from 0 to 1
+	SampleRate float64 `yaml:"sample_rate"`
+	// Targets maps a routed model or glob to its shadow model
+	Targets map[string]string `yaml:"targets"`
+	// MaxConcurrent caps the shadow calls running at once, copies beyond it are dropped
+	MaxConcurrent int `yaml:"max_concurrent"`
+}
+
+// BudgetsConfig caps what requests may cost, as priced by the pricing table
+type BudgetsConfig struct {
+	Enable bool          `yaml:"enable"`
+	Limits []BudgetLimit `yaml:"limits"`
+}
+
+// BudgetLimit is a spending budget for a day or a month. A request counts against it, and is
+// checked against it, when its routed model and API key match; unset ones match every request.
+type BudgetLimit struct {
+	Name   string `yaml:"name"`
+	Period string `yaml:"period"` // "day" or "month", in the proxy's local time
+	Model  string `yaml:"model"`  // glob on the routed model
+	// APIKeyHash is the "sha256:..." value stored for the x-api-key, or else Authorization, header
+	APIKeyHash string `yaml:"api_key_hash"`
+	// In USD. Spend above Soft is logged, requests are rejected once it reaches Hard; 0 disables either.
+	Soft float64 `yaml:"soft"`
+	Hard float64 `yaml:"hard"`
+}
+
+type ReloadConfig struct {
+	// How often config.yaml and the agent files are checked for changes, "0" disables polling
+	PollInterval string `yaml:"poll_interval"`
+	// Parsed from PollInterval
+	PollIntervalDuration time.Duration `yaml:"-"`
+}
+
 type StorageConfig struct {
 	RequestsDir string `yaml:"requests_dir"`
 	DBPath      string `yaml:"db_path"`
 }
 
 type SubagentsConfig struct {
-	Enable   bool              `yaml:"enable"`
-	Mappings map[string]string `yaml:"mappings"`
+	Enable   bool                       `yaml:"enable"`
+	Mappings map[string]SubagentMapping `yaml:"mappings"`
+}
+
+// SubagentMapping routes a subagent to a model and adjusts its requests. In config.yaml it is
+// either just the model or a block with the model and overrides.
+type SubagentMapping struct {
+	// Model is a model, an alias or "provider/model". Empty keeps the model the agent asks for.
+	Model     string         `yaml:"model"`
+	Overrides AgentOverrides `yaml:"overrides"`
+}
+
+// AgentOverrides are applied to a subagent's requests before they are forwarded
+type AgentOverrides struct {
+	Temperature *float64 `yaml:"temperature"`
+	MaxTokens   *int     `yaml:"max_tokens"`
+	// ThinkingBudget enables extended thinking with this many budget tokens, 0 disables it
+	ThinkingBudget *int `yaml:"thinking_budget"`
+	// SystemAppend is added to the end of the system prompt
+	SystemAppend string `yaml:"system_append"`
+}
+
+func (m *SubagentMapping) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		m.Model = value.Value
+		return nil
+	}
+	type plain SubagentMapping
+	return value.Decode((*plain)(m))
+}
+
+// MarshalYAML writes a mapping without overrides back as just the model
+func (m SubagentMapping) MarshalYAML() (interface{}, error) {
+	if m.Overrides == (AgentOverrides{}) {
+		return m.Model, nil
+	}
+	type plain SubagentMapping
+	return plain(m), nil
 }
 
 func Load() (*Config, error) {
+	// Try to load config.yaml from the project root
+	// The proxy binary is in proxy/ directory, config.yaml is in the parent
+	configPath := filepath.Join(filepath.Dir(os.Args[0]), "..", "config.yaml")
+
+	// If that doesn't work, try relative to current directory
+	if _, err := os.Stat(configPath); err != nil {
+		// Try common locations relative to where the binary might be run
+		for _, tryPath := range []string{"config.yaml", "../config.yaml", "../../config.yaml"} {
+			if _, err := os.Stat(tryPath); err == nil {
+				configPath = tryPath
+				break
+			}
+		}
+	}
+
+	return load(configPath, false)
+}
+
+// LoadFile loads the configuration from the given file instead of the default locations.
+// Unlike Load, a missing or unreadable file is an error.
+func LoadFile(path string) (*Config, error) {
+	return load(path, true)
+}
+
+func load(configPath string, required bool) (*Config, error) {
 	// Load .env file if it exists
 	// Look for .env file in the project root (one level up from proxy/)
 	envPath := filepath.Join("..", ".env")
@@ -99,28 +323,42 @@ func Load() (*Config, error) {
 		Storage: StorageConfig{
 			DBPath: "requests.db",
 		},
+		CircuitBreaker: CircuitBreakerConfig{
+			FailureThreshold: 5,
+			CooldownDuration: 30 * time.Second,
+		},
+		ContextRouting: ContextRoutingConfig{
+			Threshold: 0.9,
+		},
+		Shadow: ShadowConfig{
+			SampleRate:    0.1,
+			MaxConcurrent: 4,
+		},
+		Reload: ReloadConfig{
+			PollIntervalDuration: 5 * time.Second,
+		},
 		Subagents: SubagentsConfig{
 			Enable:   false,
-			Mappings: make(map[string]string),
+			Mappings: make(map[string]SubagentMapping),
 		},
+		Path: configPath,
 	}
 
-	// Try to load config.yaml from the project root
-	// The proxy binary is in proxy/ directory, config.yaml is in the parent
-	configPath := filepath.Join(filepath.Dir(os.Args[0]), "..", "config.yaml")
-
-	// If that doesn't work, try relative to current directory
-	if _, err := os.Stat(configPath); err != nil {
-		// Try common locations relative to where the binary might be run
-		for _, tryPath := range []string{"config.yaml", "../config.yaml", "../../config.yaml"} {
-			if _, err := os.Stat(tryPath); err == nil {
-				configPath = tryPath
-				break
-			}
-		}
+	if err := cfg.loadFromFile(configPath); err != nil && required {
+		return nil, fmt.Errorf("failed to load %s: %w", configPath, err)
 	}
 
-	cfg.loadFromFile(configPath)
+	// A budget that can't be enforced must not be silently ignored
+	if err := cfg.Budgets.validate(); err != nil {
+		return nil, fmt.Errorf("invalid budgets in %s: %w", configPath, err)
+	}
+	// Overrides the API refuses would make every request of the agent fail upstream
+	if err := cfg.Subagents.validate(); err != nil {
+		return nil, fmt.Errorf("invalid subagents in %s: %w", configPath, err)
+	}
+	if err := cfg.Shadow.validate(); err != nil {
+		return nil, fmt.Errorf("invalid shadow in %s: %w", configPath, err)
+	}
 
 	// Apply environment variable overrides AFTER loading from file
 	if envPort := os.Getenv("PORT"); envPort != "" {
@@ -155,6 +393,14 @@ func Load() (*Config, error) {
 		cfg.Providers.OpenAI.APIKey = envKey
 	}
 
+	// Override circuit breaker settings
+	if envThreshold := os.Getenv("CIRCUIT_BREAKER_THRESHOLD"); envThreshold != "" {
+		cfg.CircuitBreaker.FailureThreshold = getInt("CIRCUIT_BREAKER_THRESHOLD", cfg.CircuitBreaker.FailureThreshold)
+	}
+	if envCooldown := os.Getenv("CIRCUIT_BREAKER_COOLDOWN"); envCooldown != "" {
+		cfg.CircuitBreaker.Cooldown = envCooldown
+	}
+
 	// Override storage settings
 	if envPath := os.Getenv("DB_PATH"); envPath != "" {
 		cfg.Storage.DBPath = envPath
@@ -184,6 +430,21 @@ func Load() (*Config, error) {
 		}
 	}
 
+	// Configured models take precedence over the built-in catalog
+	cfg.Models = append(cfg.Models, DefaultModels()...)
+
+	if cfg.CircuitBreaker.Cooldown != "" {
+		if duration, err := time.ParseDuration(cfg.CircuitBreaker.Cooldown); err == nil {
+			cfg.CircuitBreaker.CooldownDuration = duration
+		}
+	}
+
+	if cfg.Reload.PollInterval != "" {
+		if duration, err := time.ParseDuration(cfg.Reload.PollInterval); err == nil {
+			cfg.Reload.PollIntervalDuration = duration
+		}
+	}
+
 	// Sync legacy Anthropic config with new structure
 	cfg.Anthropic = AnthropicConfig{
 		BaseURL:    cfg.Providers.Anthropic.BaseURL,
@@ -203,6 +464,91 @@ func (c *Config) loadFromFile(path string) error {
 	return yaml.Unmarshal(data, c)
 }
 
+// Smallest thinking budget the API accepts
+const minThinkingBudget = 1024
+
+// validate checks that the overrides of every mapping make a request the API accepts
+func (s *SubagentsConfig) validate() error {
+	for name, mapping := range s.Mappings {
+		if err := mapping.Overrides.validate(); err != nil {
+			return fmt.Errorf("mappings.%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// validate checks a thinking budget against the API's limits. The client's max_tokens may be
+// below any budget, so a budget needs max_tokens set alongside it.
+func (o AgentOverrides) validate() error {
+	if o.MaxTokens != nil && *o.MaxTokens < 1 {
+		return fmt.Errorf("max_tokens must be positive")
+	}
+	if o.ThinkingBudget == nil || *o.ThinkingBudget == 0 {
+		return nil
+	}
+
+	budget := *o.ThinkingBudget
+	switch {
+	case budget < minThinkingBudget:
+		return fmt.Errorf("thinking_budget must be 0 or at least %d, got %d", minThinkingBudget, budget)
+	case o.MaxTokens == nil:
+		return fmt.Errorf("thinking_budget needs max_tokens set above it")
+	case budget >= *o.MaxTokens:
+		return fmt.Errorf("thinking_budget %d must be below max_tokens %d", budget, *o.MaxTokens)
+	case o.Temperature != nil && *o.Temperature != 1:
+		return fmt.Errorf("temperature %v can't be combined with thinking, only 1 is allowed", *o.Temperature)
+	}
+	return nil
+}
+
+// validate rejects a concurrency cap that would let no shadow call run
+func (s *ShadowConfig) validate() error {
+	if s.MaxConcurrent < 1 {
+		return fmt.Errorf("max_concurrent must be at least 1, got %d", s.MaxConcurrent)
+	}
+	return nil
+}
+
+// validate checks the budget limits and normalizes their period, API key hash and name
+func (b *BudgetsConfig) validate() error {
+	for i := range b.Limits {
+		limit := &b.Limits[i]
+		if limit.Name == "" {
+			limit.Name = fmt.Sprintf("limit %d", i+1)
+		}
+
+		limit.Period = strings.ToLower(strings.TrimSpace(limit.Period))
+		if limit.Period != "day" && limit.Period != "month" {
+			return fmt.Errorf("%s: period must be day or month, got %q", limit.Name, limit.Period)
+		}
+
+		if limit.Model != "" {
+			if _, err := path.Match(limit.Model, ""); err != nil {
+				return fmt.Errorf("%s: invalid model pattern %q: %w", limit.Name, limit.Model, err)
+			}
+		}
+
+		if limit.APIKeyHash != "" {
+			hash := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(limit.APIKeyHash)), "sha256:")
+			if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha256.Size {
+				return fmt.Errorf("%s: api_key_hash must be a sha256 hash as stored in the request headers", limit.Name)
+			}
+			limit.APIKeyHash = "sha256:" + hash
+		}
+
+		if limit.Soft < 0 || limit.Hard < 0 {
+			return fmt.Errorf("%s: soft and hard must not be negative", limit.Name)
+		}
+		if limit.Soft == 0 && limit.Hard == 0 {
+			return fmt.Errorf("%s: set a soft or a hard limit", limit.Name)
+		}
+		if limit.Hard > 0 && limit.Soft > limit.Hard {
+			return fmt.Errorf("%s: soft limit $%.2f is above the hard limit $%.2f", limit.Name, limit.Soft, limit.Hard)
+		}
+	}
+	return nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -237,3 +583,55 @@ func getInt(key string, defaultValue int) int {
 
 	return intValue
 }
+
+// Header names whose values are treated as credentials in Redacted
+var sensitiveHeaderParts = []string{"key", "auth", "token", "secret", "cookie", "bearer"}
+
+// Redacted returns the config as a generic map shaped like config.yaml, with API keys and
+// credential headers replaced, for showing the active config
+func (c *Config) Redacted() (map[string]interface{}, error) {
+	redacted := *c
+	redacted.Providers.Anthropic.Headers = redactHeaders(c.Providers.Anthropic.Headers)
+	redacted.Providers.OpenAI.Headers = redactHeaders(c.Providers.OpenAI.Headers)
+	redacted.Providers.OpenAI.APIKey = redactSecret(c.Providers.OpenAI.APIKey)
+	redacted.Providers.Named = make(map[string]ProviderConfig, len(c.Providers.Named))
+	for name, named := range c.Providers.Named {
+		named.Headers = redactHeaders(named.Headers)
+		redacted.Providers.Named[name] = named
+	}
+
+	data, err := yaml.Marshal(&redacted)
+	if err != nil {
+		return nil, err
+	}
+	var view map[string]interface{}
+	if err := yaml.Unmarshal(data, &view); err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+func redactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		lowerName := strings.ToLower(name)
+		for _, part := range sensitiveHeaderParts {
+			if strings.Contains(lowerName, part) {
+				value = redactSecret(value)
+				break
+			}
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+func redactSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "[REDACTED]"
+}
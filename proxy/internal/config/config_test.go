@@ -0,0 +1,204 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestProvidersConfig_UnmarshalYAML(t *testing.T) {
+	data := `
+providers:
+  anthropic:
+    max_retries: 5
+  openai:
+    api_key: "sk-test"
+  local-llama:
+    type: openai
+    base_url: "http://localhost:8080/v1"
+  gateway:
+    type: anthropic
+    base_url: "https://llm-gateway.example.com/anthropic"
+    api_key_env: GATEWAY_KEY
+    headers:
+      X-Team: platform
+`
+
+	cfg := &Config{Providers: ProvidersConfig{Anthropic: AnthropicProviderConfig{BaseURL: "https://api.anthropic.com", MaxRetries: 3}}}
+	if err := yaml.Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if cfg.Providers.Anthropic.MaxRetries != 5 || cfg.Providers.Anthropic.BaseURL != "https://api.anthropic.com" {
+		t.Errorf("built-in anthropic config should be merged over its defaults: %+v", cfg.Providers.Anthropic)
+	}
+	if cfg.Providers.OpenAI.APIKey != "sk-test" {
+		t.Errorf("openai api_key = %q", cfg.Providers.OpenAI.APIKey)
+	}
+
+	if len(cfg.Providers.Named) != 2 {
+		t.Fatalf("got %d named providers, want 2: %+v", len(cfg.Providers.Named), cfg.Providers.Named)
+	}
+	if llama := cfg.Providers.Named["local-llama"]; llama.Type != "openai" || llama.BaseURL != "http://localhost:8080/v1" {
+		t.Errorf("unexpected local-llama config: %+v", llama)
+	}
+	gateway := cfg.Providers.Named["gateway"]
+	if gateway.Type != "anthropic" || gateway.APIKeyEnv != "GATEWAY_KEY" || gateway.Headers["X-Team"] != "platform" {
+		t.Errorf("unexpected gateway config: %+v", gateway)
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	cfg := &Config{
+		Providers: ProvidersConfig{
+			OpenAI: OpenAIProviderConfig{APIKey: "sk-secret", Headers: map[string]string{"OpenAI-Organization": "org-1"}},
+			Named: map[string]ProviderConfig{
+				"gateway": {Type: "anthropic", APIKeyEnv: "GATEWAY_KEY", Headers: map[string]string{"X-Team": "platform", "X-Api-Key": "gw-secret"}},
+			},
+		},
+		Subagents: SubagentsConfig{Mappings: map[string]SubagentMapping{"code-reviewer": {Model: "gpt-4o"}}},
+	}
+
+	view, err := cfg.Redacted()
+	if err != nil {
+		t.Fatalf("Redacted returned error: %v", err)
+	}
+
+	data, err := yaml.Marshal(view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	for _, secret := range []string{"sk-secret", "gw-secret"} {
+		if strings.Contains(text, secret) {
+			t.Errorf("redacted config contains %q:\n%s", secret, text)
+		}
+	}
+	for _, kept := range []string{"org-1", "platform", "GATEWAY_KEY", "code-reviewer: gpt-4o", "gateway:"} {
+		if !strings.Contains(text, kept) {
+			t.Errorf("redacted config is missing %q:\n%s", kept, text)
+		}
+	}
+
+	if cfg.Providers.OpenAI.APIKey != "sk-secret" || cfg.Providers.Named["gateway"].Headers["X-Api-Key"] != "gw-secret" {
+		t.Error("Redacted must not modify the config")
+	}
+}
+
+func TestSubagentMapping_UnmarshalYAML(t *testing.T) {
+	data := `
+mappings:
+  code-reviewer: gpt-4o
+  data-analyst:
+    model: o3
+    overrides:
+      temperature: 0.2
+      max_tokens: 8000
+      thinking_budget: 0
+      system_append: "Answer in English."
+`
+
+	var cfg SubagentsConfig
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+
+	if got := cfg.Mappings["code-reviewer"]; got.Model != "gpt-4o" || got.Overrides != (AgentOverrides{}) {
+		t.Errorf("unexpected code-reviewer mapping: %+v", got)
+	}
+	analyst := cfg.Mappings["data-analyst"]
+	overrides := analyst.Overrides
+	if analyst.Model != "o3" || overrides.Temperature == nil || *overrides.Temperature != 0.2 ||
+		overrides.MaxTokens == nil || *overrides.MaxTokens != 8000 ||
+		overrides.ThinkingBudget == nil || *overrides.ThinkingBudget != 0 ||
+		overrides.SystemAppend != "Answer in English." {
+		t.Errorf("unexpected data-analyst mapping: %+v", analyst)
+	}
+}
+
+func TestBudgetsConfig_Validate(t *testing.T) {
+	data := `
+enable: true
+limits:
+  - name: daily
+    period: Day
+    soft: 20
+    hard: 50
+  - period: month
+    model: "claude-opus-*"
+    api_key_hash: "SHA256:9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08"
+    hard: 500
+`
+	var budgets BudgetsConfig
+	if err := yaml.Unmarshal([]byte(data), &budgets); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if err := budgets.validate(); err != nil {
+		t.Fatalf("validate returned error: %v", err)
+	}
+
+	if daily := budgets.Limits[0]; daily.Period != "day" || daily.Soft != 20 || daily.Hard != 50 {
+		t.Errorf("unexpected daily limit: %+v", daily)
+	}
+	monthly := budgets.Limits[1]
+	if monthly.Name != "limit 2" || monthly.APIKeyHash != "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08" {
+		t.Errorf("name and hash should be normalized: %+v", monthly)
+	}
+
+	tests := []struct {
+		name    string
+		limit   BudgetLimit
+		wantErr string
+	}{
+		{"unknown period", BudgetLimit{Period: "week", Hard: 10}, "period"},
+		{"no amounts", BudgetLimit{Period: "day"}, "soft or a hard"},
+		{"negative", BudgetLimit{Period: "day", Hard: -1}, "negative"},
+		{"soft above hard", BudgetLimit{Period: "day", Soft: 20, Hard: 10}, "above the hard limit"},
+		{"bad pattern", BudgetLimit{Period: "day", Model: "claude-[", Hard: 10}, "invalid model pattern"},
+		{"bad hash", BudgetLimit{Period: "day", APIKeyHash: "sk-ant-123", Hard: 10}, "api_key_hash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budgets := BudgetsConfig{Limits: []BudgetLimit{tt.limit}}
+			if err := budgets.validate(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAgentOverrides_Validate(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	floatPtr := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name      string
+		overrides AgentOverrides
+		wantErr   string
+	}{
+		{"no thinking", AgentOverrides{Temperature: floatPtr(0.2)}, ""},
+		{"thinking off", AgentOverrides{ThinkingBudget: intPtr(0), Temperature: floatPtr(0.2)}, ""},
+		{"valid budget", AgentOverrides{ThinkingBudget: intPtr(4000), MaxTokens: intPtr(8000), Temperature: floatPtr(1)}, ""},
+		{"budget too small", AgentOverrides{ThinkingBudget: intPtr(500), MaxTokens: intPtr(8000)}, "at least 1024"},
+		{"without max_tokens", AgentOverrides{ThinkingBudget: intPtr(32000)}, "needs max_tokens"},
+		{"budget equals max_tokens", AgentOverrides{ThinkingBudget: intPtr(32000), MaxTokens: intPtr(32000)}, "below max_tokens"},
+		{"temperature with thinking", AgentOverrides{ThinkingBudget: intPtr(4000), MaxTokens: intPtr(8000), Temperature: floatPtr(0.2)}, "temperature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subagents := SubagentsConfig{Mappings: map[string]SubagentMapping{"test-runner": {Overrides: tt.overrides}}}
+			err := subagents.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate returned error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"path"
+	"strings"
+)
+
+// Model capabilities used in the catalog
+const (
+	CapabilityTools    = "tools"
+	CapabilityVision   = "vision"
+	CapabilityThinking = "thinking"
+)
+
+// ModelConfig is an entry of the models: catalog
+type ModelConfig struct {
+	// Match is an exact model ID or a glob pattern such as "claude-sonnet-4*"
+	Match           string   `yaml:"match" json:"match"`
+	Aliases         []string `yaml:"aliases" json:"aliases,omitempty"`
+	Provider        string   `yaml:"provider" json:"provider"`
+	ContextWindow   int      `yaml:"context_window" json:"contextWindow,omitempty"`
+	MaxOutputTokens int      `yaml:"max_output_tokens" json:"maxOutputTokens,omitempty"`
+	Capabilities    []string `yaml:"capabilities" json:"capabilities,omitempty"`
+}
+
+// IsPattern reports whether the entry matches a family of models rather than one ID
+func (m *ModelConfig) IsPattern() bool {
+	return strings.ContainsAny(m.Match, "*?[")
+}
+
+// Supports reports whether the model has the given capability
+func (m *ModelConfig) Supports(capability string) bool {
+	for _, c := range m.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// ModelCatalog is the ordered list of known models. Entries from config.yaml come before the
+// built-in defaults, so they take precedence.
+type ModelCatalog []ModelConfig
+
+// Lookup returns the entry for a model: exact IDs and aliases first, then the first matching pattern
+func (c ModelCatalog) Lookup(name string) (*ModelConfig, bool) {
+	for i := range c {
+		if c[i].IsPattern() {
+			continue
+		}
+		if c[i].Match == name {
+			return &c[i], true
+		}
+		for _, alias := range c[i].Aliases {
+			if alias == name {
+				return &c[i], true
+			}
+		}
+	}
+
+	for i := range c {
+		if !c[i].IsPattern() {
+			continue
+		}
+		if ok, _ := path.Match(c[i].Match, name); ok {
+			return &c[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// Resolve returns the model ID an alias stands for, or name unchanged
+func (c ModelCatalog) Resolve(name string) string {
+	if entry, ok := c.Lookup(name); ok && !entry.IsPattern() {
+		return entry.Match
+	}
+	return name
+}
+
+// DefaultModels is the built-in catalog, used after any models: entries from config.yaml
+func DefaultModels() ModelCatalog {
+	claude := []string{CapabilityTools, CapabilityVision, CapabilityThinking}
+	gpt := []string{CapabilityTools, CapabilityVision}
+	reasoning := []string{CapabilityTools, CapabilityVision, CapabilityThinking}
+
+	return ModelCatalog{
+		// Anthropic
+		{Match: "claude-opus-4-5-20251101", Aliases: []string{"claude-opus-4-5"}, Provider: "anthropic", ContextWindow: 200000, MaxOutputTokens: 64000, Capabilities: claude},
+		{Match: "claude-sonnet-4-5-20250929", Aliases: []string{"claude-sonnet-4-5"}, Provider: "anthropic", ContextWindow: 200000, MaxOutputTokens: 64000, Capabilities: claude},
+		{Match: "claude-haiku-4-5-20251001", Aliases: []string{"claude-haiku-4-5"}, Provider: "anthropic", ContextWindow: 200000, MaxOutputTokens: 64000, Capabilities: claude},
+		{Match: "claude-opus-4-1-20250805", Aliases: []string{"claude-opus-4-1"}, Provider: "anthropic", ContextWindow: 200000, MaxOutputTokens: 32000, Capabilities: claude},
+		{Match: "claude-opus-4-20250514", Aliases: []string{"claude-opus-4-0"}, Provider: "anthropic", ContextWindow: 200000, MaxOutputTokens: 32000, Capabilities: claude},
+		{Match: "claude-sonnet-4-20250514", Aliases: []string{"claude-sonnet-4-0"}, Provider: "anthropic", ContextWindow: 200000, MaxOutputTokens: 64000, Capabilities: claude},
+		{Match: "claude-3-7-sonnet-20250219", Aliases: []string{"claude-3-7-sonnet-latest"}, Provider: "anthropic", ContextWindow: 200000, MaxOutputTokens: 64000, Capabilities: claude},
+		{Match: "claude-3-5-haiku-20241022", Aliases: []string{"claude-3-5-haiku-latest"}, Provider: "anthropic", ContextWindow: 200000, MaxOutputTokens: 8192, Capabilities: gpt},
+		{Match: "claude-*", Provider: "anthropic", ContextWindow: 200000, MaxOutputTokens: 8192, Capabilities: gpt},
+
+		// OpenAI
+		{Match: "gpt-5*", Provider: "openai", ContextWindow: 400000, MaxOutputTokens: 128000, Capabilities: reasoning},
+		{Match: "gpt-4.1*", Provider: "openai", ContextWindow: 1047576, MaxOutputTokens: 32768, Capabilities: gpt},
+		{Match: "gpt-4.5*", Provider: "openai", ContextWindow: 128000, MaxOutputTokens: 16384, Capabilities: gpt},
+		{Match: "gpt-4o*", Provider: "openai", ContextWindow: 128000, MaxOutputTokens: 16384, Capabilities: gpt},
+		{Match: "gpt-3.5-turbo*", Provider: "openai", ContextWindow: 16385, MaxOutputTokens: 4096, Capabilities: []string{CapabilityTools}},
+		{Match: "o1*", Provider: "openai", ContextWindow: 200000, MaxOutputTokens: 100000, Capabilities: reasoning},
+		{Match: "o3*", Provider: "openai", ContextWindow: 200000, MaxOutputTokens: 100000, Capabilities: reasoning},
+		{Match: "o4*", Provider: "openai", ContextWindow: 200000, MaxOutputTokens: 100000, Capabilities: reasoning},
+	}
+}
@@ -0,0 +1,60 @@
+package config
+
+import "testing"
+
+func TestModelCatalog_Lookup(t *testing.T) {
+	catalog := append(ModelCatalog{
+		{Match: "qwen*", Provider: "local-llama", MaxOutputTokens: 8192},
+		{Match: "claude-sonnet-4-5-20250929", Aliases: []string{"fast"}, Provider: "anthropic", MaxOutputTokens: 1000},
+	}, DefaultModels()...)
+
+	tests := []struct {
+		name         string
+		wantMatch    string
+		wantProvider string
+	}{
+		{"claude-sonnet-4-5-20250929", "claude-sonnet-4-5-20250929", "anthropic"},
+		{"fast", "claude-sonnet-4-5-20250929", "anthropic"},
+		{"claude-opus-4-1", "claude-opus-4-1-20250805", "anthropic"},
+		{"claude-3-opus-20240229", "claude-*", "anthropic"},
+		{"qwen2.5-coder:32b", "qwen*", "local-llama"},
+		{"gpt-4o-mini", "gpt-4o*", "openai"},
+		{"o3-mini", "o3*", "openai"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, ok := catalog.Lookup(tt.name)
+			if !ok {
+				t.Fatalf("Lookup(%q) found nothing", tt.name)
+			}
+			if entry.Match != tt.wantMatch || entry.Provider != tt.wantProvider {
+				t.Errorf("Lookup(%q) = %s/%s, want %s/%s", tt.name, entry.Match, entry.Provider, tt.wantMatch, tt.wantProvider)
+			}
+		})
+	}
+
+	// Configured entries come first and override the defaults
+	if entry, _ := catalog.Lookup("claude-sonnet-4-5-20250929"); entry.MaxOutputTokens != 1000 {
+		t.Errorf("configured entry should take precedence, got %+v", entry)
+	}
+
+	if _, ok := catalog.Lookup("mistral-large"); ok {
+		t.Error("unknown models should not match")
+	}
+}
+
+func TestModelCatalog_Resolve(t *testing.T) {
+	catalog := DefaultModels()
+
+	tests := map[string]string{
+		"claude-sonnet-4-5":      "claude-sonnet-4-5-20250929",
+		"claude-3-opus-20240229": "claude-3-opus-20240229", // pattern matches keep the name
+		"unknown":                "unknown",
+	}
+	for name, want := range tests {
+		if got := catalog.Resolve(name); got != want {
+			t.Errorf("Resolve(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
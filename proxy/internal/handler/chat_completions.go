@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+	"github.com/seifghazi/claude-code-monitor/internal/provider"
+)
+
+// ChatCompletions accepts OpenAI-format clients. The request is translated to the Anthropic
+// Messages format and goes through the same routing, storage and indexing as /v1/messages;
+// the response is translated back to the OpenAI format on the way out.
+func (h *Handler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
+	receivedAt := time.Now()
+
+	bodyBytes := getBodyBytes(r)
+	if bodyBytes == nil {
+		writeOpenAIErrorResponse(w, "Error reading request body", "invalid_request_error", http.StatusBadRequest)
+		return
+	}
+
+	anthropicBody, opts, err := provider.ConvertOpenAIRequestToAnthropic(bodyBytes)
+	if err != nil {
+		log.Printf("❌ Error converting chat completions request: %v", err)
+		writeOpenAIErrorResponse(w, err.Error(), "invalid_request_error", http.StatusBadRequest)
+		return
+	}
+
+	var req model.AnthropicRequest
+	if err := json.Unmarshal(anthropicBody, &req); err != nil {
+		log.Printf("❌ Error parsing converted request: %v", err)
+		writeOpenAIErrorResponse(w, "Invalid request", "invalid_request_error", http.StatusBadRequest)
+		return
+	}
+
+	endpoint := r.URL.Path
+	anthropicReq := r.Clone(r.Context())
+	anthropicReq.URL.Path = "/v1/messages"
+	moveBearerAPIKey(anthropicReq.Header)
+	replaceRequestBody(anthropicReq, anthropicBody)
+
+	ow := newOpenAIResponseWriter(w, opts)
+	h.proxyMessages(ow, anthropicReq, anthropicBody, &req, endpoint, receivedAt)
+	ow.finish()
+}
+
+// moveBearerAPIKey lets OpenAI clients pass an Anthropic API key the way they pass OpenAI keys.
+// OAuth tokens are left in the Authorization header where Anthropic expects them.
+func moveBearerAPIKey(header http.Header) {
+	if header.Get("x-api-key") != "" {
+		return
+	}
+	token, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer ")
+	if !ok || !strings.HasPrefix(token, "sk-ant-api") {
+		return
+	}
+	header.Set("x-api-key", token)
+	header.Del("Authorization")
+}
+
+func writeOpenAIErrorResponse(w http.ResponseWriter, message, errorType string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    errorType,
+			"param":   nil,
+			"code":    nil,
+		},
+	})
+}
+
+// openAIResponseWriter sits between proxyMessages and the client and rewrites the Anthropic
+// output into the OpenAI format. Streams are translated line by line as they are flushed,
+// everything else is buffered and converted in finish.
+type openAIResponseWriter struct {
+	http.ResponseWriter
+	opts        provider.ChatCompletionOptions
+	statusCode  int
+	wroteHeader bool
+	buf         bytes.Buffer
+	stream      *provider.AnthropicToOpenAIStream
+}
+
+func newOpenAIResponseWriter(w http.ResponseWriter, opts provider.ChatCompletionOptions) *openAIResponseWriter {
+	return &openAIResponseWriter{
+		ResponseWriter: w,
+		opts:           opts,
+		statusCode:     http.StatusOK,
+	}
+}
+
+// streaming reports whether SSE headers have gone out, after which output is translated as events
+func (ow *openAIResponseWriter) streaming() bool {
+	return ow.stream != nil
+}
+
+func (ow *openAIResponseWriter) WriteHeader(code int) {
+	if ow.wroteHeader {
+		// Errors after the stream started are reported inside the stream
+		return
+	}
+	ow.wroteHeader = true
+	ow.statusCode = code
+
+	if ow.opts.Stream && code == http.StatusOK {
+		ow.stream = provider.NewAnthropicToOpenAIStream(ow.ResponseWriter, ow.opts.IncludeUsage)
+		ow.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (ow *openAIResponseWriter) Write(p []byte) (int, error) {
+	if !ow.wroteHeader {
+		ow.WriteHeader(http.StatusOK)
+	}
+	ow.buf.Write(p)
+
+	if ow.streaming() {
+		ow.processLines()
+	}
+	return len(p), nil
+}
+
+// Flush implements http.Flusher so translated chunks reach the client as they arrive
+func (ow *openAIResponseWriter) Flush() {
+	if !ow.streaming() {
+		return
+	}
+	if f, ok := ow.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// processLines translates every complete SSE line in the buffer
+func (ow *openAIResponseWriter) processLines() {
+	for {
+		line, err := ow.buf.ReadBytes('\n')
+		if err != nil {
+			// Keep the partial line for the next write
+			rest := append([]byte(nil), line...)
+			ow.buf.Reset()
+			ow.buf.Write(rest)
+			return
+		}
+
+		line = bytes.TrimRight(line, "\r\n")
+		switch {
+		case len(line) == 0, bytes.HasPrefix(line, []byte("event:")):
+			continue
+		case bytes.HasPrefix(line, []byte("data:")):
+			ow.stream.HandleEvent(bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:"))))
+		default:
+			// Upstream errors are written as plain JSON once the stream has started
+			ow.stream.HandleError(line, http.StatusBadGateway)
+		}
+	}
+}
+
+// finish converts whatever was buffered and terminates the stream
+func (ow *openAIResponseWriter) finish() {
+	if ow.streaming() {
+		if rest := bytes.TrimSpace(ow.buf.Bytes()); len(rest) > 0 {
+			ow.stream.HandleError(rest, http.StatusBadGateway)
+		}
+		ow.stream.Finish()
+		ow.Flush()
+		return
+	}
+
+	body := ow.buf.Bytes()
+	if ow.statusCode == http.StatusOK {
+		converted, err := provider.TransformAnthropicResponseToOpenAI(body)
+		if err != nil {
+			log.Printf("❌ Error converting response to OpenAI format: %v", err)
+			writeOpenAIErrorResponse(ow.ResponseWriter, "Failed to convert response", "api_error", http.StatusBadGateway)
+			return
+		}
+		body = converted
+	} else {
+		body = provider.OpenAIErrorBody(body, ow.statusCode)
+	}
+
+	ow.ResponseWriter.Header().Set("Content-Type", "application/json")
+	ow.ResponseWriter.WriteHeader(ow.statusCode)
+	ow.ResponseWriter.Write(body)
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/seifghazi/claude-code-monitor/internal/provider"
+)
+
+func TestOpenAIResponseWriter_Stream(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ow := newOpenAIResponseWriter(rec, provider.ChatCompletionOptions{Stream: true})
+
+	ow.WriteHeader(http.StatusOK)
+	sse := "event: message_start\n" +
+		`data: {"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-5"}}` + "\n\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}` + "\n\n" +
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"}}` + "\n\n" +
+		`data: {"type":"message_stop"}` + "\n\n"
+
+	// Lines arrive split across writes
+	for len(sse) > 0 {
+		n := min(17, len(sse))
+		ow.Write([]byte(sse[:n]))
+		sse = sse[n:]
+	}
+	ow.finish()
+
+	out := rec.Body.String()
+	if strings.Contains(out, "event:") || !strings.HasSuffix(out, "data: [DONE]\n\n") {
+		t.Fatalf("unexpected stream output: %q", out)
+	}
+	if !strings.Contains(out, `"content":"Hello"`) || !strings.Contains(out, `"finish_reason":"stop"`) {
+		t.Errorf("missing translated chunks: %q", out)
+	}
+}
+
+func TestOpenAIResponseWriter_StreamUpstreamError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ow := newOpenAIResponseWriter(rec, provider.ChatCompletionOptions{Stream: true})
+
+	// Headers are flushed before the upstream responds, so the error arrives in the stream
+	ow.WriteHeader(http.StatusOK)
+	ow.WriteHeader(http.StatusTooManyRequests)
+	ow.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"Slow down"}}`))
+	ow.finish()
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+	out := rec.Body.String()
+	if !strings.Contains(out, `"type":"rate_limit_error"`) || strings.Count(out, "[DONE]") != 1 {
+		t.Errorf("unexpected stream output: %q", out)
+	}
+}
+
+func TestOpenAIResponseWriter_NonStreamingError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ow := newOpenAIResponseWriter(rec, provider.ChatCompletionOptions{})
+
+	writeErrorResponse(ow, "Failed to route request", http.StatusInternalServerError)
+	ow.finish()
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	var got struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || got.Error.Message != "Failed to route request" {
+		t.Errorf("unexpected body %q (err=%v)", rec.Body.String(), err)
+	}
+}
+
+func TestMoveBearerAPIKey(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer sk-ant-api03-abc")
+	moveBearerAPIKey(header)
+	if header.Get("x-api-key") != "sk-ant-api03-abc" || header.Get("Authorization") != "" {
+		t.Errorf("API key not moved: %v", header)
+	}
+
+	header = http.Header{}
+	header.Set("Authorization", "Bearer sk-ant-oat01-abc")
+	moveBearerAPIKey(header)
+	if header.Get("x-api-key") != "" || header.Get("Authorization") == "" {
+		t.Errorf("OAuth token should stay in Authorization: %v", header)
+	}
+}
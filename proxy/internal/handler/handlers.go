@@ -2,10 +2,10 @@ package handler
 
 import (
 	"bufio"
-	"bytes"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -14,11 +14,14 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
 
+	"github.com/seifghazi/claude-code-monitor/internal/config"
 	"github.com/seifghazi/claude-code-monitor/internal/model"
+	"github.com/seifghazi/claude-code-monitor/internal/provider"
 	"github.com/seifghazi/claude-code-monitor/internal/service"
 )
 
@@ -26,28 +29,25 @@ type Handler struct {
 	anthropicService    service.AnthropicService
 	storageService      service.StorageService
 	conversationService service.ConversationService
-	modelRouter         *service.ModelRouter
+	configReloader      *service.ConfigReloader
+	budgetTracker       *service.BudgetTracker
+	shadowsInFlight     atomic.Int32
 	logger              *log.Logger
 }
 
-func New(anthropicService service.AnthropicService, storageService service.StorageService, logger *log.Logger, modelRouter *service.ModelRouter) *Handler {
+func New(anthropicService service.AnthropicService, storageService service.StorageService, logger *log.Logger, configReloader *service.ConfigReloader) *Handler {
 	conversationService := service.NewConversationService()
 
 	return &Handler{
 		anthropicService:    anthropicService,
 		storageService:      storageService,
 		conversationService: conversationService,
-		modelRouter:         modelRouter,
+		configReloader:      configReloader,
+		budgetTracker:       service.NewBudgetTracker(storageService, logger),
 		logger:              logger,
 	}
 }
 
-func (h *Handler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
-	// This endpoint is for compatibility but we're an Anthropic proxy
-	// Return a helpful error message
-	writeErrorResponse(w, "This is an Anthropic proxy. Please use the /v1/messages endpoint instead of /v1/chat/completions", http.StatusBadRequest)
-}
-
 func (h *Handler) Messages(w http.ResponseWriter, r *http.Request) {
 	receivedAt := time.Now()
 
@@ -66,55 +66,97 @@ func (h *Handler) Messages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.proxyMessages(w, r, bodyBytes, &req, r.URL.Path, receivedAt)
+}
+
+// proxyMessages routes an Anthropic Messages request, forwards it to the selected provider and
+// stores the exchange. endpoint is the path the client called, which is what gets logged.
+func (h *Handler) proxyMessages(w http.ResponseWriter, r *http.Request, bodyBytes []byte, req *model.AnthropicRequest, endpoint string, receivedAt time.Time) {
 	requestID := generateRequestID()
 	startTime := time.Now()
 
 	log.Printf("→ [RECV] id=%s stream=%v model=%s",
 		requestID, req.Stream, req.Model)
 
-	// Use model router to determine provider and route the request
-	decision, err := h.modelRouter.DetermineRoute(&req)
+	// Use model router to determine provider and route the request. The decision carries the
+	// providers, so a config reload from here on doesn't affect this request.
+	decision, err := h.configReloader.Router().DetermineRoute(req, r.Header)
+	var contextErr *service.ContextLengthError
+	if errors.As(err, &contextErr) {
+		log.Printf("📐 [REJECT] id=%s model=%s estimated_tokens=%d limit=%d",
+			requestID, contextErr.Model, contextErr.EstimatedTokens, contextErr.Limit)
+		writeAnthropicErrorResponse(w, "invalid_request_error", contextErr.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		log.Printf("❌ Error routing request: %v", err)
 		writeErrorResponse(w, "Failed to route request", http.StatusInternalServerError)
 		return
 	}
 
+	// The routed model is tried first, then its failover chain. Models whose budget is used up
+	// are left out, checked with the active config so a raised limit applies on reload.
+	keyHash := apiKeyHash(r.Header)
+	cfg, _ := h.configReloader.Config()
+	candidates, budgetErr := h.withinBudget(requestID, cfg, keyHash,
+		append([]service.RouteCandidate{{Model: decision.TargetModel, Provider: decision.Provider}}, decision.Fallbacks...))
+	if len(candidates) == 0 {
+		writeAnthropicErrorResponse(w, "permission_error", budgetErr.Error(), http.StatusForbidden)
+		return
+	}
+
+	// Apply the parameters set by the routing rule or subagent mapping. The patched body is the
+	// base for the model changes below, so the overrides carry over to failover attempts too.
+	bodyBytes, parameterOverrides, err := applyParameterOverrides(bodyBytes, decision.Overrides, decision.SystemAppend)
+	if err != nil {
+		log.Printf("❌ Error applying routing overrides: %v", err)
+		writeErrorResponse(w, "Failed to process request", http.StatusInternalServerError)
+		return
+	}
+	if parameterOverrides != nil {
+		replaceRequestBody(r, bodyBytes)
+	}
+
 	// Create request log with routing information
 	requestLog := &model.RequestLog{
-		RequestID:     requestID,
-		Timestamp:     time.Now().Format(time.RFC3339),
-		Method:        r.Method,
-		Endpoint:      r.URL.Path,
-		Headers:       SanitizeHeaders(r.Header),
-		Body:          req,
-		Model:         decision.OriginalModel,
-		OriginalModel: decision.OriginalModel,
-		RoutedModel:   decision.TargetModel,
-		UserAgent:     r.Header.Get("User-Agent"),
-		ContentType:   r.Header.Get("Content-Type"),
+		RequestID:          requestID,
+		Timestamp:          time.Now().Format(time.RFC3339),
+		Method:             r.Method,
+		Endpoint:           endpoint,
+		Headers:            SanitizeHeaders(r.Header),
+		Body:               *req,
+		Model:              decision.OriginalModel,
+		OriginalModel:      decision.OriginalModel,
+		RoutedModel:        candidates[0].Model,
+		RoutingRule:        decision.Rule,
+		APIKeyHash:         keyHash,
+		Routing:            decision.Trace(),
+		ParameterOverrides: parameterOverrides,
+		UserAgent:          r.Header.Get("User-Agent"),
+		ContentType:        r.Header.Get("Content-Type"),
 	}
 
 	if _, err := h.storageService.SaveRequest(requestLog); err != nil {
 		log.Printf("❌ Error saving request: %v", err)
 	}
 
-	// If the model was changed by routing, update the request body
-	if decision.TargetModel != decision.OriginalModel {
-		req.Model = decision.TargetModel
+	if decision.Shadow != nil {
+		h.mirrorToShadow(requestID, r, bodyBytes, decision.Shadow)
+	}
+
+	// If the model was changed by routing, update the request body.
+	// Only the model field is patched so everything else is forwarded untouched.
+	if candidates[0].Model != decision.OriginalModel {
+		req.Model = candidates[0].Model
 
-		// Re-marshal the request with the updated model
-		updatedBodyBytes, err := json.Marshal(req)
+		updatedBodyBytes, err := setTopLevelJSONField(bodyBytes, "model", candidates[0].Model)
 		if err != nil {
-			log.Printf("❌ Error marshaling updated request: %v", err)
+			log.Printf("❌ Error updating request model: %v", err)
 			writeErrorResponse(w, "Failed to process request", http.StatusInternalServerError)
 			return
 		}
 
-		// Update the request body
-		r.Body = io.NopCloser(bytes.NewReader(updatedBodyBytes))
-		r.ContentLength = int64(len(updatedBodyBytes))
-		r.Header.Set("Content-Length", fmt.Sprintf("%d", len(updatedBodyBytes)))
+		replaceRequestBody(r, updatedBodyBytes)
 	}
 
 	// For streaming requests, immediately send headers to the client
@@ -138,17 +180,70 @@ func (h *Handler) Messages(w http.ResponseWriter, r *http.Request) {
 	log.Printf("↑ [FWD] id=%s delay_ms=%d",
 		requestID, forwardedAt.Sub(receivedAt).Milliseconds())
 
-	resp, err := decision.Provider.ForwardRequest(r.Context(), r)
+	// Record every upstream attempt, including the ones that were retried
+	ctx := provider.WithAttemptObserver(r.Context(), func(attempt model.RequestAttempt) {
+		attempt.Attempt = len(requestLog.Attempts) + 1
+		attempt.Model = req.Model
+		requestLog.Attempts = append(requestLog.Attempts, attempt)
+	})
+
+	// Move down the candidates while the upstream keeps failing. Nothing from a failed attempt
+	// has reached the client, so switching models is invisible to it.
+	var resp *http.Response
+	var attempted []string
+	for i, candidate := range candidates {
+		if candidate.Model != req.Model {
+			updatedBodyBytes, patchErr := setTopLevelJSONField(bodyBytes, "model", candidate.Model)
+			if patchErr != nil {
+				// The previous candidate's response was already drained, report the failure instead
+				log.Printf("❌ Error updating request model for failover: %v", patchErr)
+				resp, err = nil, patchErr
+				break
+			}
+			replaceRequestBody(r, updatedBodyBytes)
+			req.Model = candidate.Model
+			requestLog.RoutedModel = candidate.Model
+		}
+
+		attempted = append(attempted, candidate.Model)
+		requestLog.AttemptedModels = strings.Join(attempted, " -> ")
+		resp, err = candidate.Provider.ForwardRequest(ctx, r)
+		if i == len(candidates)-1 || r.Context().Err() != nil || !shouldFailover(resp, err) {
+			break
+		}
+
+		if err != nil {
+			log.Printf("⚠️ [FAILOVER] id=%s %s (%s) failed: %v, trying %s",
+				requestID, candidate.Model, candidate.Provider.Name(), err, candidates[i+1].Model)
+		} else {
+			log.Printf("⚠️ [FAILOVER] id=%s %s (%s) returned %d, trying %s",
+				requestID, candidate.Model, candidate.Provider.Name(), resp.StatusCode, candidates[i+1].Model)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
 	if err != nil {
-		log.Printf("❌ Error forwarding to %s API: %v", decision.Provider.Name(), err)
+		log.Printf("❌ Error forwarding %s: %v", req.Model, err)
+
+		requestLog.Response = &model.ResponseLog{
+			StatusCode:   http.StatusInternalServerError,
+			BodyText:     err.Error(),
+			ResponseTime: time.Since(startTime).Milliseconds(),
+			IsStreaming:  req.Stream,
+			CompletedAt:  time.Now().Format(time.RFC3339),
+		}
+		if err := h.storageService.UpdateRequestWithResponse(requestLog); err != nil {
+			log.Printf("❌ Error updating request with forward error: %v", err)
+		}
+
 		writeErrorResponse(w, "Failed to forward request", http.StatusInternalServerError)
 		return
 	}
 	defer resp.Body.Close()
 
 	responseAt := time.Now()
-	log.Printf("↓ [RESP] id=%s status=%d latency_ms=%d",
-		requestID, resp.StatusCode, responseAt.Sub(forwardedAt).Milliseconds())
+	log.Printf("↓ [RESP] id=%s status=%d latency_ms=%d attempts=%d",
+		requestID, resp.StatusCode, responseAt.Sub(forwardedAt).Milliseconds(), len(requestLog.Attempts))
 
 	if req.Stream {
 		h.handleStreamingResponse(w, resp, requestLog, startTime)
@@ -158,30 +253,50 @@ func (h *Handler) Messages(w http.ResponseWriter, r *http.Request) {
 	h.handleNonStreamingResponse(w, resp, requestLog, startTime)
 }
 
+// withinBudget returns the candidates no hard budget limit blocks, in order. When every one is
+// blocked, the error names the first candidate's exhausted budget.
+func (h *Handler) withinBudget(requestID string, cfg *config.Config, keyHash string, candidates []service.RouteCandidate) ([]service.RouteCandidate, *service.BudgetExceededError) {
+	var allowed []service.RouteCandidate
+	var exceeded *service.BudgetExceededError
+	for _, candidate := range candidates {
+		err := h.budgetTracker.Check(cfg.Budgets, candidate.Model, keyHash)
+		var budgetErr *service.BudgetExceededError
+		if errors.As(err, &budgetErr) {
+			log.Printf("💸 [BUDGET] id=%s model=%s budget=%q spent=%.2f hard=%.2f",
+				requestID, candidate.Model, budgetErr.Budget.Name, budgetErr.Budget.Spent, budgetErr.Budget.Hard)
+			if exceeded == nil {
+				exceeded = budgetErr
+			}
+			continue
+		}
+		if err != nil {
+			// Spend that can't be summed doesn't block requests
+			log.Printf("❌ Error checking budgets: %v", err)
+		}
+		allowed = append(allowed, candidate)
+	}
+	return allowed, exceeded
+}
+
+// Models lists the model catalog. Pattern entries stand for a family of models and are flagged as such.
 func (h *Handler) Models(w http.ResponseWriter, r *http.Request) {
+	catalog := h.configReloader.Router().Models()
 
 	response := &model.ModelsResponse{
 		Object: "list",
-		Data: []model.ModelInfo{
-			{
-				ID:      "claude-3-sonnet-20240229",
-				Object:  "model",
-				Created: 1677610602,
-				OwnedBy: "anthropic",
-			},
-			{
-				ID:      "claude-3-opus-20240229",
-				Object:  "model",
-				Created: 1677610602,
-				OwnedBy: "anthropic",
-			},
-			{
-				ID:      "claude-3-haiku-20240307",
-				Object:  "model",
-				Created: 1677610602,
-				OwnedBy: "anthropic",
-			},
-		},
+		Data:   make([]model.ModelInfo, 0, len(catalog)),
+	}
+	for _, entry := range catalog {
+		response.Data = append(response.Data, model.ModelInfo{
+			ID:              entry.Match,
+			Object:          "model",
+			OwnedBy:         entry.Provider,
+			Pattern:         entry.IsPattern(),
+			Aliases:         entry.Aliases,
+			ContextWindow:   entry.ContextWindow,
+			MaxOutputTokens: entry.MaxOutputTokens,
+			Capabilities:    entry.Capabilities,
+		})
 	}
 
 	writeJSONResponse(w, response)
@@ -191,11 +306,38 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 	response := &model.HealthResponse{
 		Status:    "healthy",
 		Timestamp: time.Now(),
+		Providers: h.configReloader.Router().ProviderHealth(),
+	}
+
+	// The proxy itself is up, but requests to a provider with an open circuit fail fast
+	for _, p := range response.Providers {
+		if p.State != provider.CircuitClosed {
+			response.Status = "degraded"
+			break
+		}
 	}
 
 	writeJSONResponse(w, response)
 }
 
+// GetConfig shows the active configuration with API keys and credential headers redacted
+func (h *Handler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	cfg, loadedAt := h.configReloader.Config()
+
+	view, err := cfg.Redacted()
+	if err != nil {
+		log.Printf("Error rendering config: %v", err)
+		http.Error(w, "Failed to get config", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, &model.ActiveConfigResponse{
+		Path:     cfg.Path,
+		LoadedAt: loadedAt,
+		Config:   view,
+	})
+}
+
 func (h *Handler) UI(w http.ResponseWriter, r *http.Request) {
 	htmlContent, err := os.ReadFile("index.html")
 	if err != nil {
@@ -276,7 +418,14 @@ func (h *Handler) GetRequestsSummary(w http.ResponseWriter, r *http.Request) {
 	startTime := r.URL.Query().Get("start")
 	endTime := r.URL.Query().Get("end")
 
-	summaries, total, err := h.storageService.GetRequestsSummary(modelFilter, startTime, endTime)
+	routingFilter := model.RoutingFilter{
+		Reason:   r.URL.Query().Get("reason"),
+		Agent:    r.URL.Query().Get("agent"),
+		Rule:     r.URL.Query().Get("rule"),
+		Provider: r.URL.Query().Get("provider"),
+	}
+
+	summaries, total, err := h.storageService.GetRequestsSummary(modelFilter, startTime, endTime, routingFilter)
 	if err != nil {
 		log.Printf("Error getting request summaries: %v", err)
 		http.Error(w, "Failed to get requests", http.StatusInternalServerError)
@@ -357,6 +506,21 @@ func (h *Handler) GetModelStats(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(stats)
 }
 
+// GetAgentStats returns tokens and cost per agent. start and end are optional.
+func (h *Handler) GetAgentStats(w http.ResponseWriter, r *http.Request) {
+	startTime := r.URL.Query().Get("start")
+	endTime := r.URL.Query().Get("end")
+
+	stats, err := h.storageService.GetAgentStats(startTime, endTime)
+	if err != nil {
+		log.Printf("Error getting agent stats: %v", err)
+		http.Error(w, "Failed to get agent stats", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, stats)
+}
+
 // GetLatestRequestDate returns the date of the most recent request
 func (h *Handler) GetLatestRequestDate(w http.ResponseWriter, r *http.Request) {
 	latestDate, err := h.storageService.GetLatestRequestDate()
@@ -493,7 +657,7 @@ func (h *Handler) handleStreamingResponse(w http.ResponseWriter, resp *http.Resp
 	var fullResponseText strings.Builder
 	var toolCalls []model.ContentBlock
 	var streamingChunks []string
-	var finalUsage *model.AnthropicUsage
+	var finalUsage map[string]interface{}
 	var messageID string
 	var modelName string
 	var stopReason string
@@ -574,25 +738,14 @@ func (h *Handler) handleStreamingResponse(w http.ResponseWriter, resp *http.Resp
 		if eventType, ok := genericEvent["type"].(string); ok && eventType == "message_delta" {
 			// Usage is at top level for message_delta events
 			if usage, ok := genericEvent["usage"].(map[string]interface{}); ok {
-				// Create finalUsage if it doesn't exist yet
+				// Keep every field, including the cache_creation breakdown and fields the
+				// usage table has no column for, so they are stored with the response
 				if finalUsage == nil {
-					finalUsage = &model.AnthropicUsage{}
-				}
-
-				// Capture all usage fields
-				if inputTokens, ok := usage["input_tokens"].(float64); ok {
-					finalUsage.InputTokens = int(inputTokens)
-				}
-				if outputTokens, ok := usage["output_tokens"].(float64); ok {
-					finalUsage.OutputTokens = int(outputTokens)
+					finalUsage = map[string]interface{}{}
 				}
-				if cacheCreation, ok := usage["cache_creation_input_tokens"].(float64); ok {
-					finalUsage.CacheCreationInputTokens = int(cacheCreation)
+				for key, value := range usage {
+					finalUsage[key] = value
 				}
-				if cacheRead, ok := usage["cache_read_input_tokens"].(float64); ok {
-					finalUsage.CacheReadInputTokens = int(cacheRead)
-				}
-
 			}
 		}
 
@@ -755,6 +908,15 @@ func (h *Handler) handleNonStreamingResponse(w http.ResponseWriter, resp *http.R
 		requestLog.RequestID, time.Since(startTime).Milliseconds())
 }
 
+// shouldFailover reports whether an upstream failure is worth retrying on the next model of the
+// failover chain: transport errors, rate limits, overloads and server errors
+func shouldFailover(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return provider.IsRetryableStatus(resp.StatusCode) || resp.StatusCode >= http.StatusInternalServerError
+}
+
 // Helper function to get minimum of two integers
 func min(a, b int) int {
 	if a < b {
@@ -790,6 +952,19 @@ func writeErrorResponse(w http.ResponseWriter, message string, statusCode int) {
 	json.NewEncoder(w).Encode(&model.ErrorResponse{Error: message})
 }
 
+// writeAnthropicErrorResponse writes an error in the Messages API format so clients handle it like an upstream error
+func writeAnthropicErrorResponse(w http.ResponseWriter, errorType, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type": "error",
+		"error": map[string]interface{}{
+			"type":    errorType,
+			"message": message,
+		},
+	})
+}
+
 // extractTextFromMessage tries multiple strategies to extract text from a message
 func extractTextFromMessage(message json.RawMessage) string {
 	// Strategy 1: Direct string (simple text message)
@@ -988,6 +1163,108 @@ func (h *Handler) GetPricing(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// CreatePricing adds a price for a model, on a date and in a tier
+func (h *Handler) CreatePricing(w http.ResponseWriter, r *http.Request) {
+	p, ok := decodePricing(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.storageService.CreatePricing(p); err != nil {
+		if errors.Is(err, service.ErrPricingExists) {
+			writeErrorResponse(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("❌ Error creating pricing: %v", err)
+		writeErrorResponse(w, "Failed to create pricing", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSONResponse(w, p)
+}
+
+// UpdatePricing changes the prices of the row with the model, date and tier of the body
+func (h *Handler) UpdatePricing(w http.ResponseWriter, r *http.Request) {
+	p, ok := decodePricing(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.storageService.UpdatePricing(p); err != nil {
+		if errors.Is(err, service.ErrPricingNotFound) {
+			writeErrorResponse(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("❌ Error updating pricing: %v", err)
+		writeErrorResponse(w, "Failed to update pricing", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, p)
+}
+
+// DeletePricing removes the row given by the model, pricing_date and pricing_tier (standard
+// by default) query parameters
+func (h *Handler) DeletePricing(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	modelName, pricingDate, pricingTier := query.Get("model"), query.Get("pricing_date"), query.Get("pricing_tier")
+	if modelName == "" || pricingDate == "" {
+		writeErrorResponse(w, "model and pricing_date parameters are required", http.StatusBadRequest)
+		return
+	}
+	if pricingTier == "" {
+		pricingTier = "standard"
+	}
+
+	if err := h.storageService.DeletePricing(modelName, pricingDate, pricingTier); err != nil {
+		switch {
+		case errors.Is(err, service.ErrPricingNotFound):
+			writeErrorResponse(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, service.ErrDefaultPricing):
+			writeErrorResponse(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("❌ Error deleting pricing: %v", err)
+			writeErrorResponse(w, "Failed to delete pricing", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodePricing reads and validates a pricing row from the request body, writing the error
+// response when it isn't valid
+func decodePricing(w http.ResponseWriter, r *http.Request) (model.PricingModel, bool) {
+	var p model.PricingModel
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+		return p, false
+	}
+	if err := service.ValidatePricing(&p); err != nil {
+		writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return p, false
+	}
+	return p, true
+}
+
+// GetBudgets returns every configured budget limit with what has been spent in its period
+func (h *Handler) GetBudgets(w http.ResponseWriter, r *http.Request) {
+	cfg, _ := h.configReloader.Config()
+	statuses, err := h.budgetTracker.Status(cfg.Budgets)
+	if err != nil {
+		log.Printf("❌ Error getting budgets: %v", err)
+		writeErrorResponse(w, "Failed to get budgets", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, model.BudgetsResponse{
+		Enabled: cfg.Budgets.Enable,
+		Budgets: statuses,
+	})
+}
+
 // GetTurns returns turn summaries with context information
 func (h *Handler) GetTurns(w http.ResponseWriter, r *http.Request) {
 	startTime := r.URL.Query().Get("start")
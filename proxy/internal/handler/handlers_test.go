@@ -0,0 +1,297 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+	"github.com/seifghazi/claude-code-monitor/internal/provider"
+	"github.com/seifghazi/claude-code-monitor/internal/service"
+)
+
+func TestShouldFailover(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   bool
+	}{
+		{"transport error", 0, errors.New("connection refused"), true},
+		{"overloaded", 529, nil, true},
+		{"rate limited", http.StatusTooManyRequests, nil, true},
+		{"bad gateway", http.StatusBadGateway, nil, true},
+		{"ok", http.StatusOK, nil, false},
+		{"bad request", http.StatusBadRequest, nil, false},
+		{"unauthorized", http.StatusUnauthorized, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *http.Response
+			if tt.err == nil {
+				resp = &http.Response{StatusCode: tt.status}
+			}
+			if got := shouldFailover(resp, tt.err); got != tt.want {
+				t.Errorf("shouldFailover() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// upstreamResult is what a scriptedProvider answers for a model
+type upstreamResult struct {
+	status int
+	body   string
+	err    error
+}
+
+// scriptedProvider answers each model with a fixed result and records the models it was sent
+type scriptedProvider struct {
+	name    string
+	results map[string]upstreamResult
+	models  []string
+}
+
+func (p *scriptedProvider) Name() string { return p.name }
+
+func (p *scriptedProvider) ForwardRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
+	var body struct {
+		Model string `json:"model"`
+	}
+	bodyBytes, _ := io.ReadAll(req.Body)
+	json.Unmarshal(bodyBytes, &body)
+	p.models = append(p.models, body.Model)
+
+	result, ok := p.results[body.Model]
+	if !ok {
+		result = upstreamResult{status: http.StatusOK, body: `{"id":"msg_1","type":"message","role":"assistant","model":"` + body.Model + `","content":[]}`}
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+	return &http.Response{StatusCode: result.status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(result.body))}, nil
+}
+
+// recordingStorage keeps the last request log it was given. Spend is looked up by model pattern.
+type recordingStorage struct {
+	service.StorageService
+	saved *model.RequestLog
+	spend map[string]float64
+}
+
+func (s *recordingStorage) GetCostSince(since time.Time, modelPattern, apiKeyHash string) (float64, error) {
+	return s.spend[modelPattern], nil
+}
+
+func (s *recordingStorage) SaveRequest(request *model.RequestLog) (string, error) {
+	s.saved = request
+	return request.RequestID, nil
+}
+
+func (s *recordingStorage) UpdateRequestWithResponse(request *model.RequestLog) error {
+	s.saved = request
+	return nil
+}
+
+func (s *recordingStorage) IndexRequest(requestID, timestamp string, body, response json.RawMessage) error {
+	return nil
+}
+
+func newTestHandler(t *testing.T, cfg *config.Config, storage service.StorageService, providers map[string]provider.Provider) *Handler {
+	t.Helper()
+	logger := log.New(io.Discard, "", 0)
+	reloader, err := service.NewConfigReloader(cfg, func(cfg *config.Config, reuse map[string]provider.Provider) (*service.ModelRouter, error) {
+		return service.NewModelRouter(cfg, providers, logger), nil
+	}, logger)
+	if err != nil {
+		t.Fatalf("NewConfigReloader returned error: %v", err)
+	}
+	return New(nil, storage, logger, reloader)
+}
+
+// postMessages sends a non-streaming Messages request through the handler
+func postMessages(h *Handler, requestModel string) *httptest.ResponseRecorder {
+	body := []byte(`{"model":"` + requestModel + `","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`)
+	r := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(string(body)))
+	r = r.WithContext(context.WithValue(r.Context(), model.BodyBytesKey, body))
+	rec := httptest.NewRecorder()
+	h.Messages(rec, r)
+	return rec
+}
+
+func TestMessages_Failover(t *testing.T) {
+	const (
+		opus   = "claude-opus-4-1-20250805"
+		sonnet = "claude-sonnet-4-5-20250929"
+		gpt    = "gpt-4o"
+	)
+	overloaded := upstreamResult{status: 529, body: `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`}
+
+	tests := []struct {
+		name          string
+		anthropic     map[string]upstreamResult
+		openai        map[string]upstreamResult
+		wantStatus    int
+		wantBody      string
+		wantRouted    string
+		wantAttempted string
+	}{
+		{
+			name:          "overloaded primary fails over",
+			anthropic:     map[string]upstreamResult{opus: overloaded},
+			wantStatus:    http.StatusOK,
+			wantRouted:    sonnet,
+			wantAttempted: opus + " -> " + sonnet,
+		},
+		{
+			name:          "client error doesn't fail over",
+			anthropic:     map[string]upstreamResult{opus: {status: http.StatusBadRequest, body: `{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`}},
+			wantStatus:    http.StatusBadRequest,
+			wantBody:      "invalid_request_error",
+			wantRouted:    opus,
+			wantAttempted: opus,
+		},
+		{
+			name:          "last candidate's error reaches the client",
+			anthropic:     map[string]upstreamResult{opus: overloaded, sonnet: overloaded},
+			openai:        map[string]upstreamResult{gpt: {status: http.StatusServiceUnavailable, body: "upstream down"}},
+			wantStatus:    http.StatusServiceUnavailable,
+			wantBody:      "upstream down",
+			wantRouted:    gpt,
+			wantAttempted: opus + " -> " + sonnet + " -> " + gpt,
+		},
+		{
+			name:          "last candidate's transport error reaches the client",
+			anthropic:     map[string]upstreamResult{opus: overloaded, sonnet: {err: errors.New("connection refused")}},
+			openai:        map[string]upstreamResult{gpt: {err: errors.New("connection refused")}},
+			wantStatus:    http.StatusInternalServerError,
+			wantBody:      "Failed to forward request",
+			wantRouted:    gpt,
+			wantAttempted: opus + " -> " + sonnet + " -> " + gpt,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anthropic := &scriptedProvider{name: "anthropic", results: tt.anthropic}
+			openai := &scriptedProvider{name: "openai", results: tt.openai}
+			storage := &recordingStorage{}
+			cfg := &config.Config{Failover: map[string][]string{opus: {sonnet, gpt}}}
+			h := newTestHandler(t, cfg, storage, map[string]provider.Provider{"anthropic": anthropic, "openai": openai})
+
+			rec := postMessages(h, opus)
+
+			if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("response = %d %q, want %d containing %q", rec.Code, rec.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+			sent := strings.Join(append(anthropic.models, openai.models...), " -> ")
+			if sent != tt.wantAttempted {
+				t.Errorf("upstream calls = %q, want %q", sent, tt.wantAttempted)
+			}
+			saved := storage.saved
+			if saved == nil || saved.Response == nil {
+				t.Fatalf("the response was not stored: %+v", saved)
+			}
+			if saved.OriginalModel != opus || saved.RoutedModel != tt.wantRouted || saved.AttemptedModels != tt.wantAttempted {
+				t.Errorf("stored original=%q routed=%q attempted=%q, want %q %q %q",
+					saved.OriginalModel, saved.RoutedModel, saved.AttemptedModels, opus, tt.wantRouted, tt.wantAttempted)
+			}
+			if saved.Response.StatusCode != tt.wantStatus {
+				t.Errorf("stored status = %d, want %d", saved.Response.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestMessages_Budgets(t *testing.T) {
+	const (
+		opus   = "claude-opus-4-1-20250805"
+		sonnet = "claude-sonnet-4-5-20250929"
+		gpt    = "gpt-4o"
+	)
+	budgets := config.BudgetsConfig{Enable: true, Limits: []config.BudgetLimit{
+		{Name: "opus", Period: "day", Model: "claude-opus-*", Hard: 10},
+		{Name: "sonnet", Period: "day", Model: "claude-sonnet-*", Hard: 10},
+		{Name: "daily", Period: "day", Hard: 100},
+	}}
+	overloaded := upstreamResult{status: 529, body: `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`}
+
+	tests := []struct {
+		name          string
+		spend         map[string]float64 // raw cost per limit's model pattern
+		anthropic     map[string]upstreamResult
+		wantStatus    int
+		wantAttempted string
+	}{
+		{
+			name:          "exhausted primary goes to its fallback",
+			spend:         map[string]float64{"claude-opus-*": 12_000_000},
+			wantStatus:    http.StatusOK,
+			wantAttempted: sonnet,
+		},
+		{
+			name:          "failover skips an exhausted fallback",
+			spend:         map[string]float64{"claude-sonnet-*": 12_000_000},
+			anthropic:     map[string]upstreamResult{opus: overloaded},
+			wantStatus:    http.StatusOK,
+			wantAttempted: opus + " -> " + gpt,
+		},
+		{
+			name:       "every candidate exhausted",
+			spend:      map[string]float64{"": 150_000_000},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anthropic := &scriptedProvider{name: "anthropic", results: tt.anthropic}
+			openai := &scriptedProvider{name: "openai"}
+			storage := &recordingStorage{spend: tt.spend}
+			cfg := &config.Config{Failover: map[string][]string{opus: {sonnet, gpt}}, Budgets: budgets}
+			h := newTestHandler(t, cfg, storage, map[string]provider.Provider{"anthropic": anthropic, "openai": openai})
+
+			rec := postMessages(h, opus)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			sent := strings.Join(append(anthropic.models, openai.models...), " -> ")
+			if sent != tt.wantAttempted {
+				t.Errorf("upstream calls = %q, want %q", sent, tt.wantAttempted)
+			}
+			if tt.wantStatus != http.StatusForbidden {
+				if storage.saved.AttemptedModels != tt.wantAttempted {
+					t.Errorf("stored attempted models = %q, want %q", storage.saved.AttemptedModels, tt.wantAttempted)
+				}
+				return
+			}
+
+			var body struct {
+				Type  string `json:"type"`
+				Error struct {
+					Type    string `json:"type"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid error body %q: %v", rec.Body.String(), err)
+			}
+			if body.Type != "error" || body.Error.Type != "permission_error" || !strings.Contains(body.Error.Message, `"daily"`) {
+				t.Errorf("unexpected error body: %s", rec.Body.String())
+			}
+			if storage.saved != nil {
+				t.Errorf("a rejected request was stored: %+v", storage.saved)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+	"github.com/seifghazi/claude-code-monitor/internal/provider"
+	"github.com/seifghazi/claude-code-monitor/internal/service"
+)
+
+// How long a shadow request may run, it isn't bound to the client connection
+const shadowTimeout = 10 * time.Minute
+
+// mirrorToShadow sends a copy of the request to the shadow model in the background and stores
+// the answer linked to the primary request. The copy is never streamed and the client sees none of it.
+// At most shadow.max_concurrent copies run at once, the others are dropped.
+func (h *Handler) mirrorToShadow(requestID string, r *http.Request, bodyBytes []byte, shadow *service.RouteCandidate) {
+	cfg, _ := h.configReloader.Config()
+	if inFlight := h.shadowsInFlight.Add(1); int(inFlight) > cfg.Shadow.MaxConcurrent {
+		h.shadowsInFlight.Add(-1)
+		log.Printf("👥 [SHADOW] id=%s model=%s dropped, %d shadow calls already running",
+			requestID, shadow.Model, cfg.Shadow.MaxConcurrent)
+		return
+	}
+
+	body, err := setTopLevelJSONField(bodyBytes, "model", shadow.Model)
+	if err == nil {
+		body, err = setTopLevelJSONField(body, "stream", false)
+	}
+	if err != nil {
+		h.shadowsInFlight.Add(-1)
+		log.Printf("❌ Error building shadow request for %s: %v", requestID, err)
+		return
+	}
+
+	header := r.Header.Clone()
+	url := *r.URL
+	method := r.Method
+
+	go func() {
+		defer h.shadowsInFlight.Add(-1)
+
+		// Shadow failures must not open the primary traffic's circuit or use up its retries
+		ctx, cancel := context.WithTimeout(provider.WithShadowTraffic(context.Background()), shadowTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, method, url.String(), bytes.NewReader(body))
+		if err != nil {
+			log.Printf("❌ Error building shadow request for %s: %v", requestID, err)
+			return
+		}
+		req.Header = header
+
+		result := &model.ShadowResponse{
+			RequestID: requestID,
+			Model:     shadow.Model,
+			Provider:  shadow.Provider.Name(),
+			CreatedAt: time.Now().Format(time.RFC3339),
+		}
+
+		startTime := time.Now()
+		resp, err := shadow.Provider.ForwardRequest(ctx, req)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			respBody, readErr := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			result.StatusCode = resp.StatusCode
+			if readErr != nil {
+				result.Error = readErr.Error()
+			} else if json.Valid(respBody) {
+				result.Body = respBody
+			}
+		}
+		result.DurationMs = time.Since(startTime).Milliseconds()
+
+		log.Printf("👥 [SHADOW] id=%s model=%s status=%d latency_ms=%d",
+			requestID, result.Model, result.StatusCode, result.DurationMs)
+
+		if err := h.storageService.SaveShadowResponse(result); err != nil {
+			log.Printf("❌ Error saving shadow response: %v", err)
+		}
+	}()
+}
+
+// GetShadowPairs lists primary/shadow response pairs with their cost and latency deltas.
+// start and end are optional and filter on the request timestamp.
+func (h *Handler) GetShadowPairs(w http.ResponseWriter, r *http.Request) {
+	startTime := r.URL.Query().Get("start")
+	endTime := r.URL.Query().Get("end")
+
+	pairs, err := h.storageService.GetShadowPairs(startTime, endTime)
+	if err != nil {
+		log.Printf("Error getting shadow pairs: %v", err)
+		http.Error(w, "Failed to get shadow pairs", http.StatusInternalServerError)
+		return
+	}
+
+	response := &model.ShadowPairsResponse{
+		Pairs: pairs,
+		Count: len(pairs),
+	}
+	var latencyDelta int64
+	for _, p := range pairs {
+		response.TotalCostDelta += p.CostDelta
+		latencyDelta += p.LatencyDeltaMs
+	}
+	if len(pairs) > 0 {
+		response.AvgLatencyDeltaMs = latencyDelta / int64(len(pairs))
+	}
+
+	writeJSONResponse(w, response)
+}
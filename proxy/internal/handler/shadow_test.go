@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+	"github.com/seifghazi/claude-code-monitor/internal/provider"
+	"github.com/seifghazi/claude-code-monitor/internal/service"
+)
+
+// blockingProvider holds every request until release is closed
+type blockingProvider struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (p *blockingProvider) Name() string { return "blocking" }
+
+func (p *blockingProvider) ForwardRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
+	p.calls.Add(1)
+	<-p.release
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+}
+
+// shadowStorage counts the stored shadow responses
+type shadowStorage struct {
+	recordingStorage
+	shadows atomic.Int32
+}
+
+func (s *shadowStorage) SaveShadowResponse(shadow *model.ShadowResponse) error {
+	s.shadows.Add(1)
+	return nil
+}
+
+func TestMirrorToShadow_DropsCopiesOverTheLimit(t *testing.T) {
+	cfg := &config.Config{Shadow: config.ShadowConfig{MaxConcurrent: 2}}
+	storage := &shadowStorage{}
+	h := newTestHandler(t, cfg, storage, map[string]provider.Provider{})
+
+	upstream := &blockingProvider{release: make(chan struct{})}
+	shadow := &service.RouteCandidate{Model: "claude-haiku-4-5", Provider: upstream}
+	body := []byte(`{"model":"claude-opus-4-1","stream":true,"messages":[]}`)
+	mirror := func() {
+		r := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(string(body)))
+		h.mirrorToShadow("req", r, body, shadow)
+	}
+
+	for i := 0; i < 5; i++ {
+		mirror()
+	}
+	waitFor(t, func() bool { return upstream.calls.Load() == 2 })
+	if inFlight := h.shadowsInFlight.Load(); inFlight != 2 {
+		t.Errorf("%d shadow calls in flight, want 2", inFlight)
+	}
+
+	// Finished calls free their slot
+	close(upstream.release)
+	waitFor(t, func() bool { return h.shadowsInFlight.Load() == 0 })
+	mirror()
+	waitFor(t, func() bool { return storage.shadows.Load() == 3 })
+	if calls := upstream.calls.Load(); calls != 3 {
+		t.Errorf("upstream got %d shadow calls, want 3", calls)
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within a second")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -39,8 +42,7 @@ func SanitizeHeaders(headers http.Header) http.Header {
 			// Calculate SHA256 hash for each sensitive header value
 			hashedValues := make([]string, len(values))
 			for i, value := range values {
-				hash := sha256.Sum256([]byte(value))
-				hashedValues[i] = fmt.Sprintf("sha256:%x", hash)
+				hashedValues[i] = hashHeaderValue(value)
 			}
 			sanitized[key] = hashedValues
 		} else {
@@ -51,6 +53,184 @@ func SanitizeHeaders(headers http.Header) http.Header {
 	return sanitized
 }
 
+func hashHeaderValue(value string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(value)))
+}
+
+// apiKeyHash identifies the credential a request was sent with: the hash SanitizeHeaders stores
+// for its x-api-key header, or for its Authorization header when it has no key
+func apiKeyHash(headers http.Header) string {
+	if key := headers.Get("X-Api-Key"); key != "" {
+		return hashHeaderValue(key)
+	}
+	if auth := headers.Get("Authorization"); auth != "" {
+		return hashHeaderValue(auth)
+	}
+	return ""
+}
+
+// setTopLevelJSONField replaces the value of a top-level field in a JSON object without
+// re-encoding the rest of the document, so fields the proxy doesn't model (thinking, metadata,
+// cache_control, ...) reach the upstream byte-for-byte. A missing field is appended to the object.
+func setTopLevelJSONField(body []byte, key string, value interface{}) ([]byte, error) {
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", key, err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse request body: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("request body is not a JSON object")
+	}
+
+	fieldCount := 0
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse request body: %w", err)
+		}
+		fieldCount++
+
+		// The value starts after the colon that follows the key
+		valueStart := int(dec.InputOffset())
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("failed to parse request body: %w", err)
+		}
+		valueEnd := int(dec.InputOffset())
+
+		if name, _ := keyTok.(string); name != key {
+			continue
+		}
+
+		colon := bytes.IndexByte(body[valueStart:valueEnd], ':')
+		if colon < 0 {
+			return nil, fmt.Errorf("malformed field %s", key)
+		}
+		valueStart += colon + 1
+		// Keep the original whitespace between the colon and the value
+		for valueStart < valueEnd && isJSONSpace(body[valueStart]) {
+			valueStart++
+		}
+
+		patched := make([]byte, 0, len(body)-(valueEnd-valueStart)+len(valueBytes))
+		patched = append(patched, body[:valueStart]...)
+		patched = append(patched, valueBytes...)
+		patched = append(patched, body[valueEnd:]...)
+		return patched, nil
+	}
+
+	// Field not present: insert it before the closing brace
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("failed to parse request body: %w", err)
+	}
+	closing := int(dec.InputOffset()) - 1
+
+	keyBytes, _ := json.Marshal(key)
+	insert := append(keyBytes, ':')
+	insert = append(insert, valueBytes...)
+	if fieldCount > 0 {
+		insert = append([]byte{','}, insert...)
+	}
+
+	patched := make([]byte, 0, len(body)+len(insert))
+	patched = append(patched, body[:closing]...)
+	patched = append(patched, insert...)
+	patched = append(patched, body[closing:]...)
+	return patched, nil
+}
+
+// applyParameterOverrides sets the parameters chosen by routing on the request body and appends
+// to its system prompt, in a stable order. It returns the patched body and a record of what
+// changed, nil when nothing did.
+func applyParameterOverrides(body []byte, overrides map[string]interface{}, systemAppend string) ([]byte, *model.ParameterOverrides, error) {
+	if len(overrides) == 0 && systemAppend == "" {
+		return body, nil, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse request body: %w", err)
+	}
+
+	record := &model.ParameterOverrides{
+		Original:     make(map[string]interface{}, len(overrides)),
+		Effective:    make(map[string]interface{}, len(overrides)),
+		SystemAppend: systemAppend,
+	}
+
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var original interface{}
+		if raw, ok := fields[key]; ok {
+			json.Unmarshal(raw, &original)
+		}
+		record.Original[key] = original
+		record.Effective[key] = overrides[key]
+
+		patched, err := setTopLevelJSONField(body, key, overrides[key])
+		if err != nil {
+			return nil, nil, fmt.Errorf("override %s: %w", key, err)
+		}
+		body = patched
+	}
+
+	if systemAppend != "" {
+		system, err := appendSystemPrompt(fields["system"], systemAppend)
+		if err != nil {
+			return nil, nil, err
+		}
+		if body, err = setTopLevelJSONField(body, "system", system); err != nil {
+			return nil, nil, fmt.Errorf("system prompt: %w", err)
+		}
+	}
+
+	return body, record, nil
+}
+
+// appendSystemPrompt adds text to the end of a system prompt, which is either a string or a
+// list of content blocks. Existing blocks are passed through as they are, cache_control included.
+func appendSystemPrompt(system json.RawMessage, text string) (interface{}, error) {
+	trimmed := bytes.TrimSpace(system)
+	switch {
+	case len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")):
+		return text, nil
+	case trimmed[0] == '"':
+		var prompt string
+		if err := json.Unmarshal(trimmed, &prompt); err != nil {
+			return nil, fmt.Errorf("failed to parse system prompt: %w", err)
+		}
+		return prompt + "\n\n" + text, nil
+	default:
+		var blocks []json.RawMessage
+		if err := json.Unmarshal(trimmed, &blocks); err != nil {
+			return nil, fmt.Errorf("failed to parse system prompt: %w", err)
+		}
+		block, _ := json.Marshal(model.AnthropicSystemMessage{Type: "text", Text: text})
+		return append(blocks, block), nil
+	}
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// replaceRequestBody swaps the body that will be forwarded upstream
+func replaceRequestBody(r *http.Request, body []byte) {
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", fmt.Sprintf("%d", len(body)))
+}
+
 // ConversationDiffAnalyzer analyzes conversation flows to identify new vs repeated content
 type ConversationDiffAnalyzer struct{}
 
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetTopLevelJSONField_PreservesUnroutedFields(t *testing.T) {
+	original := `{
+  "model": "claude-sonnet-4-5-20250929",
+  "max_tokens": 32000,
+  "thinking": {"type": "enabled", "budget_tokens": 31999},
+  "metadata": {"user_id": "user_abc", "model": "not-this-one"},
+  "top_p": 0.95,
+  "top_k": 40,
+  "stop_sequences": ["\n\nHuman:"],
+  "system": [{"type": "text", "text": "You are Claude Code", "cache_control": {"type": "ephemeral"}}],
+  "tools": [{"name": "Read", "description": "Reads é files", "input_schema": {"type": "object"}, "cache_control": {"type": "ephemeral", "ttl": "1h"}}],
+  "messages": [{"role": "user", "content": [{"type": "text", "text": "hi \"there\"", "cache_control": {"type": "ephemeral"}}]}],
+  "stream": true
+}`
+
+	patched, err := setTopLevelJSONField([]byte(original), "model", "gpt-4o")
+	if err != nil {
+		t.Fatalf("setTopLevelJSONField() error: %v", err)
+	}
+
+	expected := strings.Replace(original, `"model": "claude-sonnet-4-5-20250929"`, `"model": "gpt-4o"`, 1)
+	if string(patched) != expected {
+		t.Errorf("patched body differs outside the model field:\ngot:  %s\nwant: %s", patched, expected)
+	}
+}
+
+func TestSetTopLevelJSONField(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		key      string
+		value    interface{}
+		expected string
+	}{
+		{
+			name:     "compact body",
+			body:     `{"model":"claude-opus-4-1-20250805","messages":[]}`,
+			key:      "model",
+			value:    "o3",
+			expected: `{"model":"o3","messages":[]}`,
+		},
+		{
+			name:     "field after nested objects",
+			body:     `{"messages":[{"role":"user","content":"{\"model\":1}"}],"model":"a"}`,
+			key:      "model",
+			value:    "b",
+			expected: `{"messages":[{"role":"user","content":"{\"model\":1}"}],"model":"b"}`,
+		},
+		{
+			name:     "missing field is appended",
+			body:     `{"messages":[]}`,
+			key:      "temperature",
+			value:    0.5,
+			expected: `{"messages":[],"temperature":0.5}`,
+		},
+		{
+			name:     "empty object",
+			body:     `{ }`,
+			key:      "model",
+			value:    "x",
+			expected: `{ "model":"x"}`,
+		},
+		{
+			name:     "non-string value replaced",
+			body:     `{"max_tokens" : 32000, "model":"a"}`,
+			key:      "max_tokens",
+			value:    8192,
+			expected: `{"max_tokens" : 8192, "model":"a"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patched, err := setTopLevelJSONField([]byte(tt.body), tt.key, tt.value)
+			if err != nil {
+				t.Fatalf("setTopLevelJSONField() error: %v", err)
+			}
+			if string(patched) != tt.expected {
+				t.Errorf("got %s, want %s", patched, tt.expected)
+			}
+			if !json.Valid(patched) {
+				t.Errorf("patched body is not valid JSON: %s", patched)
+			}
+		})
+	}
+
+	if _, err := setTopLevelJSONField([]byte(`["model"]`), "model", "x"); err == nil {
+		t.Error("expected an error for a non-object body")
+	}
+}
+
+func TestApplyParameterOverrides(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		overrides    map[string]interface{}
+		systemAppend string
+		expected     string
+		original     map[string]interface{}
+	}{
+		{
+			name:      "parameters only",
+			body:      `{"model":"a","temperature":1,"max_tokens":32000}`,
+			overrides: map[string]interface{}{"temperature": 0.2, "thinking": map[string]interface{}{"type": "disabled"}},
+			expected:  `{"model":"a","temperature":0.2,"max_tokens":32000,"thinking":{"type":"disabled"}}`,
+			original:  map[string]interface{}{"temperature": float64(1), "thinking": nil},
+		},
+		{
+			name:         "string system prompt",
+			body:         `{"model":"a","system":"Be brief."}`,
+			systemAppend: "Answer in English.",
+			expected:     `{"model":"a","system":"Be brief.\n\nAnswer in English."}`,
+			original:     map[string]interface{}{},
+		},
+		{
+			name:         "system prompt blocks",
+			body:         `{"model":"a","system":[{"type":"text","text":"Be brief.","cache_control":{"type":"ephemeral"}}]}`,
+			systemAppend: "Answer in English.",
+			expected:     `{"model":"a","system":[{"type":"text","text":"Be brief.","cache_control":{"type":"ephemeral"}},{"text":"Answer in English.","type":"text"}]}`,
+			original:     map[string]interface{}{},
+		},
+		{
+			name:         "no system prompt",
+			body:         `{"model":"a"}`,
+			systemAppend: "Answer in English.",
+			expected:     `{"model":"a","system":"Answer in English."}`,
+			original:     map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patched, record, err := applyParameterOverrides([]byte(tt.body), tt.overrides, tt.systemAppend)
+			if err != nil {
+				t.Fatalf("applyParameterOverrides() error: %v", err)
+			}
+			if string(patched) != tt.expected {
+				t.Errorf("got %s, want %s", patched, tt.expected)
+			}
+			if !reflect.DeepEqual(record.Original, tt.original) {
+				t.Errorf("original parameters = %v, want %v", record.Original, tt.original)
+			}
+			if record.SystemAppend != tt.systemAppend {
+				t.Errorf("system append = %q, want %q", record.SystemAppend, tt.systemAppend)
+			}
+		})
+	}
+
+	patched, record, err := applyParameterOverrides([]byte(`{"model":"a"}`), nil, "")
+	if err != nil || record != nil || string(patched) != `{"model":"a"}` {
+		t.Errorf("without overrides got %s, %+v, %v", patched, record, err)
+	}
+}
+
+func TestAPIKeyHash(t *testing.T) {
+	headers := http.Header{}
+	if got := apiKeyHash(headers); got != "" {
+		t.Errorf("no credentials: got %q", got)
+	}
+
+	headers.Set("Authorization", "Bearer oauth-token")
+	if got, want := apiKeyHash(headers), SanitizeHeaders(headers).Get("Authorization"); got != want {
+		t.Errorf("Authorization: got %q, want the stored hash %q", got, want)
+	}
+
+	// The API key wins over the Authorization header
+	headers.Set("X-Api-Key", "sk-ant-test")
+	if got, want := apiKeyHash(headers), SanitizeHeaders(headers).Get("X-Api-Key"); got != want {
+		t.Errorf("x-api-key: got %q, want the stored hash %q", got, want)
+	}
+}
@@ -34,6 +34,12 @@ type RequestLog struct {
 	Model                string              `json:"model,omitempty"`
 	OriginalModel        string              `json:"originalModel,omitempty"`
 	RoutedModel          string              `json:"routedModel,omitempty"`
+	AttemptedModels      string              `json:"attemptedModels,omitempty"` // every model the request was sent to, e.g. "opus -> sonnet"
+	RoutingRule          string              `json:"routingRule,omitempty"`
+	APIKeyHash           string              `json:"apiKeyHash,omitempty"`
+	Routing              *RoutingTrace       `json:"routing,omitempty"`
+	ParameterOverrides   *ParameterOverrides `json:"parameterOverrides,omitempty"`
+	Shadow               *ShadowResponse     `json:"shadow,omitempty"`
 	UserAgent            string              `json:"userAgent"`
 	ContentType          string              `json:"contentType"`
 	PromptGrade          *PromptGrade        `json:"promptGrade,omitempty"`
@@ -43,6 +49,19 @@ type RequestLog struct {
 	TokensCached         int64               `json:"tokensCached,omitempty"`
 	CacheCreationTokens  int64               `json:"cacheCreationTokens,omitempty"`
 	CacheReadTokens      int64               `json:"cacheReadTokens,omitempty"`
+	Attempts             []RequestAttempt    `json:"attempts,omitempty"`
+}
+
+// RequestAttempt is one upstream call made while serving a request, retries add more than one
+type RequestAttempt struct {
+	Attempt    int    `json:"attempt"`
+	Provider   string `json:"provider"`
+	Model      string `json:"model,omitempty"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+	BackoffMs  int64  `json:"backoffMs,omitempty"`
+	StartedAt  string `json:"startedAt"`
 }
 
 type ResponseLog struct {
@@ -56,6 +75,121 @@ type ResponseLog struct {
 	CompletedAt     string              `json:"completedAt"`
 }
 
+
+// Why a request went to its model
+const (
+	RoutingReasonDefault     = "default"      // the requested model, nothing changed it
+	RoutingReasonRule        = "rule"         // a routing rule matched
+	RoutingReasonSubagent    = "subagent"     // the system prompt matched a mapped subagent
+	RoutingReasonLongContext = "long_context" // moved to a long-context model
+)
+
+// Agents a Claude Code request is attributed to besides the named subagents
+const (
+	AgentMain    = "main"    // the main conversation
+	AgentUnknown = "unknown" // a subagent without a known definition
+)
+
+// RoutingTrace explains the routing decision made for a request
+type RoutingTrace struct {
+	Reason               string `json:"reason"`
+	Detail               string `json:"detail,omitempty"`
+	Rule                 string `json:"rule,omitempty"`
+	Agent                string `json:"agent,omitempty"`
+	PromptHash           string `json:"promptHash,omitempty"`
+	Provider             string `json:"provider"`
+	EstimatedInputTokens int    `json:"estimatedInputTokens,omitempty"`
+	// Candidates are the routed model followed by its failover chain, in the order they're tried
+	Candidates []RouteCandidate `json:"candidates"`
+	Shadow     *RouteCandidate  `json:"shadow,omitempty"`
+}
+
+// ParameterOverrides records the request parameters routing changed before forwarding. The
+// stored body is the one the client sent.
+type ParameterOverrides struct {
+	// Original holds the client's value of every overridden parameter, null when it wasn't set
+	Original  map[string]interface{} `json:"original"`
+	Effective map[string]interface{} `json:"effective"`
+	// SystemAppend is the text added to the end of the system prompt
+	SystemAppend string `json:"systemAppend,omitempty"`
+}
+
+type RouteCandidate struct {
+	Model    string `json:"model"`
+	Provider string `json:"provider"`
+}
+
+// RoutingFilter narrows the request summary to a routing outcome, empty fields match everything
+type RoutingFilter struct {
+	Reason   string
+	Agent    string
+	Rule     string
+	Provider string
+}
+
+// ShadowResponse is what the shadow model answered to a mirrored copy of a request
+type ShadowResponse struct {
+	RequestID                string          `json:"requestId"`
+	Model                    string          `json:"model"`
+	Provider                 string          `json:"provider"`
+	StatusCode               int             `json:"statusCode"`
+	Error                    string          `json:"error,omitempty"`
+	Body                     json.RawMessage `json:"body,omitempty"`
+	InputTokens              int64           `json:"inputTokens"`
+	CacheCreationInputTokens int64           `json:"cacheCreationInputTokens"`
+	CacheReadInputTokens     int64           `json:"cacheReadInputTokens"`
+	OutputTokens             int64           `json:"outputTokens"`
+	DurationMs               int64           `json:"durationMs"`
+	CreatedAt                string          `json:"createdAt"`
+}
+
+// ShadowPair compares a primary response with its shadow. Costs are in the same units as
+// /api/usage, deltas are shadow minus primary.
+type ShadowPair struct {
+	RequestID           string  `json:"requestId"`
+	Timestamp           string  `json:"timestamp"`
+	PrimaryModel        string  `json:"primaryModel"`
+	ShadowModel         string  `json:"shadowModel"`
+	PrimaryStatus       int     `json:"primaryStatus"`
+	ShadowStatus        int     `json:"shadowStatus"`
+	ShadowError         string  `json:"shadowError,omitempty"`
+	PrimaryLatencyMs    int64   `json:"primaryLatencyMs"`
+	ShadowLatencyMs     int64   `json:"shadowLatencyMs"`
+	LatencyDeltaMs      int64   `json:"latencyDeltaMs"`
+	PrimaryOutputTokens int64   `json:"primaryOutputTokens"`
+	ShadowOutputTokens  int64   `json:"shadowOutputTokens"`
+	PrimaryCost         float64 `json:"primaryCost"`
+	ShadowCost          float64 `json:"shadowCost"`
+	CostDelta           float64 `json:"costDelta"`
+}
+
+type ShadowPairsResponse struct {
+	Pairs             []ShadowPair `json:"pairs"`
+	Count             int          `json:"count"`
+	TotalCostDelta    float64      `json:"totalCostDelta"`
+	AvgLatencyDeltaMs int64        `json:"avgLatencyDeltaMs"`
+}
+
+// BudgetStatus is a spending budget and what its requests cost in the current period, in USD
+type BudgetStatus struct {
+	Name         string  `json:"name"`
+	Period       string  `json:"period"`
+	Model        string  `json:"model,omitempty"`
+	APIKeyHash   string  `json:"apiKeyHash,omitempty"`
+	PeriodStart  string  `json:"periodStart"`
+	ResetsAt     string  `json:"resetsAt"`
+	Spent        float64 `json:"spent"`
+	Soft         float64 `json:"soft,omitempty"`
+	Hard         float64 `json:"hard,omitempty"`
+	SoftExceeded bool    `json:"softExceeded"`
+	HardExceeded bool    `json:"hardExceeded"`
+}
+
+type BudgetsResponse struct {
+	Enabled bool           `json:"enabled"`
+	Budgets []BudgetStatus `json:"budgets"`
+}
+
 type ChatMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
@@ -72,6 +206,7 @@ type AnthropicUsage struct {
 	OutputTokens             int    `json:"output_tokens"`
 	CacheCreationInputTokens int    `json:"cache_creation_input_tokens,omitempty"`
 	CacheReadInputTokens     int    `json:"cache_read_input_tokens,omitempty"`
+	ReasoningTokens          int    `json:"reasoning_tokens,omitempty"`
 	ServiceTier              string `json:"service_tier,omitempty"`
 }
 
@@ -150,6 +285,13 @@ type AnthropicRequest struct {
 	Stream      bool                     `json:"stream,omitempty"`
 	Tools       []Tool                   `json:"tools,omitempty"`
 	ToolChoice  interface{}              `json:"tool_choice,omitempty"`
+	Thinking    *ThinkingConfig          `json:"thinking,omitempty"`
+}
+
+// ThinkingConfig is the extended thinking parameter of an Anthropic request
+type ThinkingConfig struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens,omitempty"`
 }
 
 type ModelsResponse struct {
@@ -158,10 +300,15 @@ type ModelsResponse struct {
 }
 
 type ModelInfo struct {
-	ID      string `json:"id"`
-	Object  string `json:"object"`
-	Created int64  `json:"created"`
-	OwnedBy string `json:"owned_by"`
+	ID              string   `json:"id"`
+	Object          string   `json:"object"`
+	Created         int64    `json:"created"`
+	OwnedBy         string   `json:"owned_by"`
+	Pattern         bool     `json:"pattern,omitempty"`
+	Aliases         []string `json:"aliases,omitempty"`
+	ContextWindow   int      `json:"context_window,omitempty"`
+	MaxOutputTokens int      `json:"max_output_tokens,omitempty"`
+	Capabilities    []string `json:"capabilities,omitempty"`
 }
 
 type GradeRequest struct {
@@ -171,8 +318,26 @@ type GradeRequest struct {
 }
 
 type HealthResponse struct {
-	Status    string    `json:"status"`
-	Timestamp time.Time `json:"timestamp"`
+	Status    string           `json:"status"`
+	Timestamp time.Time        `json:"timestamp"`
+	Providers []ProviderHealth `json:"providers,omitempty"`
+}
+
+// ActiveConfigResponse is the configuration the proxy currently routes with, secrets redacted
+type ActiveConfigResponse struct {
+	Path     string                 `json:"path"`
+	LoadedAt time.Time              `json:"loadedAt"`
+	Config   map[string]interface{} `json:"config"`
+}
+
+// ProviderHealth is the circuit breaker state of an upstream provider
+type ProviderHealth struct {
+	Name                string     `json:"name"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastError           string     `json:"lastError,omitempty"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"`
+	RetryAt             *time.Time `json:"retryAt,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -210,10 +375,19 @@ type UsageRecord struct {
 	CacheCreationEphemeral5mInputTokens  int64   `json:"cache_creation_ephemeral_5m_input_tokens"`
 	CacheCreationEphemeral1hInputTokens  int64   `json:"cache_creation_ephemeral_1h_input_tokens"`
 	OutputTokens                         int64   `json:"output_tokens"`
+	ReasoningTokens                      int64   `json:"reasoning_tokens"`
 	ServiceTier                          string  `json:"service_tier"`
 	Timestamp                            string  `json:"timestamp"`
 	UserAgent                            string  `json:"user_agent"`
 	Model                                string  `json:"model"`
+	// Past the long context threshold, priced at the long_context tier when the model has one
+	LongContext bool `json:"long_context"`
+	// Usage fields without a column of their own, as returned by the API
+	ExtraUsage json.RawMessage `json:"extra_usage,omitempty"`
+	// The pricing row the costs were computed with
+	PricingModel string `json:"pricing_model"`
+	PricingDate  string `json:"pricing_date"`
+	PricingTier  string `json:"pricing_tier"`
 	// Cost fields
 	InputCost         float64 `json:"input_cost"`
 	CacheCreationCost float64 `json:"cache_creation_cost"`
@@ -222,6 +396,8 @@ type UsageRecord struct {
 	Cache1hCost       float64 `json:"cache_1h_cost"`
 	OutputCost        float64 `json:"output_cost"`
 	TotalCost         float64 `json:"total_cost"`
+	// Part of the total above what the request would have cost at standard rates
+	LongContextCost float64 `json:"long_context_cost"`
 	// Percentage fields
 	InputPct         float64 `json:"input_pct"`
 	CacheCreationPct float64 `json:"cache_creation_pct"`
@@ -240,30 +416,36 @@ type HourlyUsage struct {
 }
 
 type PricingModel struct {
-	Model                                string  `json:"model"`
-	DisplayName                          string  `json:"display_name"`
-	Family                               string  `json:"family"`
-	PricingDate                          string  `json:"pricing_date"`
-	PricingTier                          string  `json:"pricing_tier"`
-	InputTokens                          float64 `json:"input_tokens"`
-	OutputTokens                         float64 `json:"output_tokens"`
-	CacheReadInputTokens                 float64 `json:"cache_read_input_tokens"`
-	CacheCreationEphemeral5mInputTokens  float64 `json:"cache_creation_ephemeral_5m_input_tokens"`
-	CacheCreationEphemeral1hInputTokens  float64 `json:"cache_creation_ephemeral_1h_input_tokens"`
+	Model                               string  `json:"model" yaml:"model"`
+	DisplayName                         string  `json:"display_name" yaml:"display_name"`
+	Family                              string  `json:"family" yaml:"family"`
+	PricingDate                         string  `json:"pricing_date" yaml:"pricing_date"`
+	PricingTier                         string  `json:"pricing_tier" yaml:"pricing_tier"`
+	InputTokens                         float64 `json:"input_tokens" yaml:"input_tokens"`
+	OutputTokens                        float64 `json:"output_tokens" yaml:"output_tokens"`
+	CacheReadInputTokens                float64 `json:"cache_read_input_tokens" yaml:"cache_read_input_tokens"`
+	CacheCreationEphemeral5mInputTokens float64 `json:"cache_creation_ephemeral_5m_input_tokens" yaml:"cache_creation_ephemeral_5m_input_tokens"`
+	CacheCreationEphemeral1hInputTokens float64 `json:"cache_creation_ephemeral_1h_input_tokens" yaml:"cache_creation_ephemeral_1h_input_tokens"`
 }
 
 // RequestSummary is a lightweight version of RequestLog for list views
 type RequestSummary struct {
-	RequestID     string          `json:"requestId"`
-	Timestamp     string          `json:"timestamp"`
-	Method        string          `json:"method"`
-	Endpoint      string          `json:"endpoint"`
-	Model         string          `json:"model,omitempty"`
-	OriginalModel string          `json:"originalModel,omitempty"`
-	RoutedModel   string          `json:"routedModel,omitempty"`
-	StatusCode    int             `json:"statusCode,omitempty"`
-	ResponseTime  int64           `json:"responseTime,omitempty"`
-	Usage         *AnthropicUsage `json:"usage,omitempty"`
+	RequestID       string          `json:"requestId"`
+	Timestamp       string          `json:"timestamp"`
+	Method          string          `json:"method"`
+	Endpoint        string          `json:"endpoint"`
+	Model           string          `json:"model,omitempty"`
+	OriginalModel   string          `json:"originalModel,omitempty"`
+	RoutedModel     string          `json:"routedModel,omitempty"`
+	AttemptedModels string          `json:"attemptedModels,omitempty"`
+	RoutingRule     string          `json:"routingRule,omitempty"`
+	RoutingReason   string          `json:"routingReason,omitempty"`
+	RoutingAgent    string          `json:"routingAgent,omitempty"`
+	Provider        string          `json:"provider,omitempty"`
+	StatusCode      int             `json:"statusCode,omitempty"`
+	ResponseTime    int64           `json:"responseTime,omitempty"`
+	Attempts        int             `json:"attempts,omitempty"`
+	Usage           *AnthropicUsage `json:"usage,omitempty"`
 }
 
 // Dashboard stats structures
@@ -307,6 +489,22 @@ type ModelTokens struct {
 	Requests int    `json:"requests"`
 }
 
+type AgentStatsResponse struct {
+	AgentStats []AgentStats `json:"agentStats"`
+}
+
+// AgentStats is the usage of one agent: main, a subagent name, unknown, or unclassified for
+// requests that didn't come from Claude Code or predate attribution
+type AgentStats struct {
+	Agent                    string  `json:"agent"`
+	Requests                 int     `json:"requests"`
+	InputTokens              int64   `json:"inputTokens"`
+	OutputTokens             int64   `json:"outputTokens"`
+	CacheCreationInputTokens int64   `json:"cacheCreationInputTokens"`
+	CacheReadInputTokens     int64   `json:"cacheReadInputTokens"`
+	TotalCost                float64 `json:"totalCost"`
+}
+
 // TurnSummary represents a request with its context summary for the Turns tab
 type TurnSummary struct {
 	ID                string  `json:"id"`
@@ -328,6 +526,7 @@ type TurnSummary struct {
 	InputTokens       int64   `json:"inputTokens"`
 	OutputTokens      int64   `json:"outputTokens"`
 	CacheReads        int64   `json:"cacheReads"`
+	LongContext       bool    `json:"longContext"`
 	SystemCount       int     `json:"systemCount"`
 	ToolsCount        int     `json:"toolsCount"`
 	Reason            string  `json:"reason"`
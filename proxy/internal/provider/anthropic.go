@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"fmt"
@@ -29,10 +30,24 @@ func NewAnthropicProvider(cfg *config.AnthropicProviderConfig) Provider {
 }
 
 func (p *AnthropicProvider) Name() string {
+	if p.config.Name != "" {
+		return p.config.Name
+	}
 	return "anthropic"
 }
 
 func (p *AnthropicProvider) ForwardRequest(ctx context.Context, originalReq *http.Request) (*http.Response, error) {
+	// Buffer the body so it can be replayed on retries
+	var bodyBytes []byte
+	if originalReq.Body != nil {
+		var err error
+		bodyBytes, err = io.ReadAll(originalReq.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		originalReq.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	}
+
 	// Clone the request to avoid modifying the original
 	proxyReq := originalReq.Clone(ctx)
 
@@ -66,11 +81,29 @@ func (p *AnthropicProvider) ForwardRequest(ctx context.Context, originalReq *htt
 		proxyReq.Header.Set("anthropic-version", p.config.Version)
 	}
 
+	// Named providers authenticate with their own key. The client's credentials are meant for
+	// Anthropic and never reach a third-party base_url, even when no key is configured.
+	if p.config.Name != "" {
+		proxyReq.Header.Del("x-api-key")
+		proxyReq.Header.Del("Authorization")
+		if p.config.APIKey != "" {
+			proxyReq.Header.Set("x-api-key", p.config.APIKey)
+		}
+	}
+	for name, value := range p.config.Headers {
+		proxyReq.Header.Set(name, value)
+	}
+
 	// Support gzip encoding
 	proxyReq.Header.Set("Accept-Encoding", "gzip")
 
-	// Forward the request
-	resp, err := p.client.Do(proxyReq)
+	// Forward the request, retrying rate limits, overloads and transport errors
+	resp, err := newRetryPolicy(p.config.MaxRetries).do(ctx, p.Name(), func() (*http.Response, error) {
+		attemptReq := proxyReq.Clone(ctx)
+		attemptReq.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		attemptReq.ContentLength = int64(len(bodyBytes))
+		return p.client.Do(attemptReq)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to forward request: %w", err)
 	}
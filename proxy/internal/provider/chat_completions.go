@@ -0,0 +1,649 @@
+package provider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ChatCompletionOptions holds the client-side options of an OpenAI request that affect how
+// the Anthropic response has to be translated back
+type ChatCompletionOptions struct {
+	Stream       bool
+	IncludeUsage bool
+}
+
+// defaultChatCompletionMaxTokens is used when an OpenAI client doesn't set a limit, Anthropic requires one
+const defaultChatCompletionMaxTokens = 4096
+
+// ConvertOpenAIRequestToAnthropic converts an OpenAI Chat Completions request body into an
+// Anthropic Messages request body
+func ConvertOpenAIRequestToAnthropic(body []byte) ([]byte, ChatCompletionOptions, error) {
+	var opts ChatCompletionOptions
+
+	var openAIReq map[string]interface{}
+	if err := json.Unmarshal(body, &openAIReq); err != nil {
+		return nil, opts, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	modelName, _ := openAIReq["model"].(string)
+	if modelName == "" {
+		return nil, opts, fmt.Errorf("model is required")
+	}
+	if n, ok := toInt(openAIReq["n"]); ok && n > 1 {
+		return nil, opts, fmt.Errorf("n > 1 is not supported")
+	}
+
+	rawMessages, ok := openAIReq["messages"].([]interface{})
+	if !ok || len(rawMessages) == 0 {
+		return nil, opts, fmt.Errorf("messages is required")
+	}
+
+	var systemBlocks []map[string]interface{}
+	var messages []map[string]interface{}
+
+	// appendBlocks adds content blocks to the conversation, merging consecutive messages of the
+	// same role because Anthropic requires user and assistant turns to alternate
+	appendBlocks := func(role string, blocks []interface{}) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(messages); n > 0 && messages[n-1]["role"] == role {
+			messages[n-1]["content"] = append(messages[n-1]["content"].([]interface{}), blocks...)
+			return
+		}
+		messages = append(messages, map[string]interface{}{
+			"role":    role,
+			"content": blocks,
+		})
+	}
+
+	for i, item := range rawMessages {
+		msg, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, opts, fmt.Errorf("messages.%d: invalid message", i)
+		}
+		role, _ := msg["role"].(string)
+
+		switch role {
+		case "system", "developer":
+			for _, block := range openAIContentToAnthropic(msg["content"]) {
+				if block["type"] == "text" {
+					systemBlocks = append(systemBlocks, block)
+				}
+			}
+		case "user":
+			var blocks []interface{}
+			for _, block := range openAIContentToAnthropic(msg["content"]) {
+				blocks = append(blocks, block)
+			}
+			appendBlocks("user", blocks)
+		case "assistant":
+			var blocks []interface{}
+			for _, block := range openAIContentToAnthropic(msg["content"]) {
+				blocks = append(blocks, block)
+			}
+			if toolCalls, ok := msg["tool_calls"].([]interface{}); ok {
+				for _, tc := range toolCalls {
+					if toolCall, ok := tc.(map[string]interface{}); ok {
+						blocks = append(blocks, openAIToolCallToAnthropic(toolCall))
+					}
+				}
+			}
+			appendBlocks("assistant", blocks)
+		case "tool":
+			toolCallID, _ := msg["tool_call_id"].(string)
+			var resultText []string
+			for _, block := range openAIContentToAnthropic(msg["content"]) {
+				if block["type"] == "text" {
+					resultText = append(resultText, block["text"].(string))
+				}
+			}
+			appendBlocks("user", []interface{}{map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": toolCallID,
+				"content":     strings.Join(resultText, "\n"),
+			}})
+		default:
+			return nil, opts, fmt.Errorf("messages.%d: unsupported role %q", i, role)
+		}
+	}
+
+	maxTokens := defaultChatCompletionMaxTokens
+	if v, ok := toInt(openAIReq["max_completion_tokens"]); ok && v > 0 {
+		maxTokens = v
+	} else if v, ok := toInt(openAIReq["max_tokens"]); ok && v > 0 {
+		maxTokens = v
+	}
+
+	anthropicReq := map[string]interface{}{
+		"model":      modelName,
+		"messages":   messages,
+		"max_tokens": maxTokens,
+	}
+	if len(systemBlocks) > 0 {
+		anthropicReq["system"] = systemBlocks
+	}
+
+	if stream, ok := openAIReq["stream"].(bool); ok && stream {
+		opts.Stream = true
+		anthropicReq["stream"] = true
+		if streamOptions, ok := openAIReq["stream_options"].(map[string]interface{}); ok {
+			opts.IncludeUsage, _ = streamOptions["include_usage"].(bool)
+		}
+	}
+
+	if temperature, ok := openAIReq["temperature"].(float64); ok {
+		// OpenAI accepts up to 2.0, Anthropic up to 1.0
+		if temperature > 1 {
+			temperature = 1
+		}
+		anthropicReq["temperature"] = temperature
+	}
+	if topP, ok := openAIReq["top_p"].(float64); ok {
+		anthropicReq["top_p"] = topP
+	}
+
+	switch stop := openAIReq["stop"].(type) {
+	case string:
+		anthropicReq["stop_sequences"] = []string{stop}
+	case []interface{}:
+		anthropicReq["stop_sequences"] = stop
+	}
+
+	if user, ok := openAIReq["user"].(string); ok && user != "" {
+		anthropicReq["metadata"] = map[string]interface{}{"user_id": user}
+	}
+
+	// Reasoning effort maps onto an extended thinking budget, which needs room in max_tokens
+	// and doesn't allow a custom temperature
+	if effort, ok := openAIReq["reasoning_effort"].(string); ok {
+		if budget := thinkingBudgetForReasoningEffort(effort); budget > 0 {
+			anthropicReq["thinking"] = map[string]interface{}{
+				"type":          "enabled",
+				"budget_tokens": budget,
+			}
+			if maxTokens <= budget {
+				anthropicReq["max_tokens"] = budget + maxTokens
+			}
+			delete(anthropicReq, "temperature")
+		}
+	}
+
+	if tools, ok := openAIReq["tools"].([]interface{}); ok && len(tools) > 0 {
+		anthropicTools := make([]map[string]interface{}, 0, len(tools))
+		for _, t := range tools {
+			tool, ok := t.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			function, ok := tool["function"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := function["name"].(string)
+			if name == "" {
+				continue
+			}
+
+			inputSchema, ok := function["parameters"].(map[string]interface{})
+			if !ok {
+				inputSchema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+			}
+			anthropicTool := map[string]interface{}{
+				"name":         name,
+				"input_schema": inputSchema,
+			}
+			if description, ok := function["description"].(string); ok && description != "" {
+				anthropicTool["description"] = description
+			}
+			anthropicTools = append(anthropicTools, anthropicTool)
+		}
+		anthropicReq["tools"] = anthropicTools
+
+		switch toolChoice := openAIReq["tool_choice"].(type) {
+		case string:
+			switch toolChoice {
+			case "auto":
+				anthropicReq["tool_choice"] = map[string]interface{}{"type": "auto"}
+			case "required":
+				anthropicReq["tool_choice"] = map[string]interface{}{"type": "any"}
+			case "none":
+				anthropicReq["tool_choice"] = map[string]interface{}{"type": "none"}
+			}
+		case map[string]interface{}:
+			if function, ok := toolChoice["function"].(map[string]interface{}); ok {
+				if name, ok := function["name"].(string); ok {
+					anthropicReq["tool_choice"] = map[string]interface{}{"type": "tool", "name": name}
+				}
+			}
+		}
+	}
+
+	anthropicBody, err := json.Marshal(anthropicReq)
+	if err != nil {
+		return nil, opts, fmt.Errorf("failed to marshal anthropic request: %w", err)
+	}
+	return anthropicBody, opts, nil
+}
+
+// thinkingBudgetForReasoningEffort is the inverse of reasoningEffortForThinking
+func thinkingBudgetForReasoningEffort(effort string) int {
+	switch effort {
+	case "low":
+		return 2048
+	case "medium":
+		return 8192
+	case "high":
+		return 24576
+	default:
+		return 0
+	}
+}
+
+// openAIContentToAnthropic converts OpenAI message content (a string or an array of parts)
+// into Anthropic content blocks
+func openAIContentToAnthropic(content interface{}) []map[string]interface{} {
+	switch v := content.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []map[string]interface{}{{"type": "text", "text": v}}
+	case []interface{}:
+		var blocks []map[string]interface{}
+		for _, item := range v {
+			part, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch part["type"] {
+			case "text":
+				if text, ok := part["text"].(string); ok && text != "" {
+					blocks = append(blocks, map[string]interface{}{"type": "text", "text": text})
+				}
+			case "image_url":
+				imageURL, ok := part["image_url"].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if url, ok := imageURL["url"].(string); ok && url != "" {
+					blocks = append(blocks, map[string]interface{}{
+						"type":   "image",
+						"source": imageSourceFromURL(url),
+					})
+				}
+			case "file":
+				file, ok := part["file"].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				fileData, _ := file["file_data"].(string)
+				mediaType, data, ok := parseDataURL(fileData)
+				if !ok {
+					continue
+				}
+				document := map[string]interface{}{
+					"type": "document",
+					"source": map[string]interface{}{
+						"type":       "base64",
+						"media_type": mediaType,
+						"data":       data,
+					},
+				}
+				if filename, ok := file["filename"].(string); ok && filename != "" {
+					document["title"] = filename
+				}
+				blocks = append(blocks, document)
+			}
+		}
+		return blocks
+	default:
+		return nil
+	}
+}
+
+// imageSourceFromURL builds an Anthropic image source from an OpenAI image URL, which may be a data URL
+func imageSourceFromURL(url string) map[string]interface{} {
+	if mediaType, data, ok := parseDataURL(url); ok {
+		return map[string]interface{}{
+			"type":       "base64",
+			"media_type": mediaType,
+			"data":       data,
+		}
+	}
+	return map[string]interface{}{
+		"type": "url",
+		"url":  url,
+	}
+}
+
+// parseDataURL splits a base64 data URL into its media type and payload
+func parseDataURL(url string) (mediaType, data string, ok bool) {
+	if !strings.HasPrefix(url, "data:") {
+		return "", "", false
+	}
+	meta, payload, found := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !found || !strings.HasSuffix(meta, ";base64") {
+		return "", "", false
+	}
+	if _, err := base64.StdEncoding.DecodeString(payload); err != nil {
+		return "", "", false
+	}
+	return strings.TrimSuffix(meta, ";base64"), payload, true
+}
+
+// openAIToolCallToAnthropic converts an OpenAI tool call into an Anthropic tool_use block
+func openAIToolCallToAnthropic(toolCall map[string]interface{}) map[string]interface{} {
+	function, _ := toolCall["function"].(map[string]interface{})
+	name, _ := function["name"].(string)
+
+	input := map[string]interface{}{}
+	if argsStr, ok := function["arguments"].(string); ok && argsStr != "" {
+		if err := json.Unmarshal([]byte(argsStr), &input); err != nil {
+			input = map[string]interface{}{"raw": argsStr}
+		}
+	}
+
+	return map[string]interface{}{
+		"type":  "tool_use",
+		"id":    toolCall["id"],
+		"name":  name,
+		"input": input,
+	}
+}
+
+// mapAnthropicStopReason converts an Anthropic stop_reason into the equivalent OpenAI finish_reason
+func mapAnthropicStopReason(stopReason string) string {
+	switch stopReason {
+	case "max_tokens", "model_context_window_exceeded":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+// anthropicUsageToOpenAI converts Anthropic usage, where cached input is reported separately,
+// into OpenAI usage where prompt_tokens includes the cached tokens
+func anthropicUsageToOpenAI(usage map[string]interface{}) map[string]interface{} {
+	inputTokens, _ := toInt(usage["input_tokens"])
+	cacheCreation, _ := toInt(usage["cache_creation_input_tokens"])
+	cacheRead, _ := toInt(usage["cache_read_input_tokens"])
+	outputTokens, _ := toInt(usage["output_tokens"])
+
+	promptTokens := inputTokens + cacheCreation + cacheRead
+	return map[string]interface{}{
+		"prompt_tokens":     promptTokens,
+		"completion_tokens": outputTokens,
+		"total_tokens":      promptTokens + outputTokens,
+		"prompt_tokens_details": map[string]interface{}{
+			"cached_tokens": cacheRead,
+		},
+	}
+}
+
+// TransformAnthropicResponseToOpenAI converts a non-streaming Anthropic Messages response into
+// an OpenAI chat.completion object
+func TransformAnthropicResponseToOpenAI(body []byte) ([]byte, error) {
+	var anthropicResp map[string]interface{}
+	if err := json.Unmarshal(body, &anthropicResp); err != nil {
+		return nil, fmt.Errorf("failed to parse anthropic response: %w", err)
+	}
+
+	var textParts []string
+	var toolCalls []map[string]interface{}
+	if content, ok := anthropicResp["content"].([]interface{}); ok {
+		for _, item := range content {
+			block, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch block["type"] {
+			case "text":
+				if text, ok := block["text"].(string); ok {
+					textParts = append(textParts, text)
+				}
+			case "tool_use":
+				toolCalls = append(toolCalls, convertToolUseToOpenAI(block))
+			}
+		}
+	}
+
+	message := map[string]interface{}{
+		"role":    "assistant",
+		"content": nil,
+	}
+	if len(textParts) > 0 {
+		message["content"] = strings.Join(textParts, "")
+	}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = toolCalls
+	}
+
+	stopReason, _ := anthropicResp["stop_reason"].(string)
+	openAIResp := map[string]interface{}{
+		"id":      anthropicResp["id"],
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   anthropicResp["model"],
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"message":       message,
+			"finish_reason": mapAnthropicStopReason(stopReason),
+		}},
+	}
+	if usage, ok := anthropicResp["usage"].(map[string]interface{}); ok {
+		openAIResp["usage"] = anthropicUsageToOpenAI(usage)
+	}
+
+	return json.Marshal(openAIResp)
+}
+
+// OpenAIErrorBody converts an error payload into the OpenAI error format. It understands
+// Anthropic errors as well as the proxy's own {"error": "..."} responses.
+func OpenAIErrorBody(body []byte, statusCode int) []byte {
+	errorType := "api_error"
+	message := strings.TrimSpace(string(body))
+
+	var anthropicErr struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &anthropicErr); err == nil && len(anthropicErr.Error) > 0 {
+		var detailed struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		}
+		var plain string
+		if err := json.Unmarshal(anthropicErr.Error, &detailed); err == nil && detailed.Message != "" {
+			errorType = detailed.Type
+			message = detailed.Message
+		} else if err := json.Unmarshal(anthropicErr.Error, &plain); err == nil && plain != "" {
+			message = plain
+		}
+	}
+	if message == "" {
+		message = fmt.Sprintf("upstream returned status %d", statusCode)
+	}
+
+	errorJSON, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    errorType,
+			"param":   nil,
+			"code":    nil,
+		},
+	})
+	return errorJSON
+}
+
+// AnthropicToOpenAIStream translates Anthropic SSE events into OpenAI chat.completion.chunk events
+type AnthropicToOpenAIStream struct {
+	w            io.Writer
+	includeUsage bool
+	id           string
+	model        string
+	created      int64
+	toolIndexes  map[int]int // Anthropic content block index -> OpenAI tool_calls index
+	usage        map[string]interface{}
+	finishReason string
+	done         bool
+}
+
+func NewAnthropicToOpenAIStream(w io.Writer, includeUsage bool) *AnthropicToOpenAIStream {
+	return &AnthropicToOpenAIStream{
+		w:            w,
+		includeUsage: includeUsage,
+		created:      time.Now().Unix(),
+		toolIndexes:  make(map[int]int),
+		usage:        map[string]interface{}{},
+	}
+}
+
+func (s *AnthropicToOpenAIStream) emitChunk(delta map[string]interface{}, finishReason interface{}) {
+	s.emit(map[string]interface{}{
+		"id":      s.id,
+		"object":  "chat.completion.chunk",
+		"created": s.created,
+		"model":   s.model,
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"delta":         delta,
+			"finish_reason": finishReason,
+		}},
+	})
+}
+
+func (s *AnthropicToOpenAIStream) emit(payload map[string]interface{}) {
+	payloadJSON, _ := json.Marshal(payload)
+	fmt.Fprintf(s.w, "data: %s\n\n", payloadJSON)
+}
+
+// HandleEvent processes the JSON payload of a single Anthropic "data:" line
+func (s *AnthropicToOpenAIStream) HandleEvent(data []byte) {
+	if s.done {
+		return
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return
+	}
+
+	index := -1
+	if idx, ok := toInt(event["index"]); ok {
+		index = idx
+	}
+
+	switch event["type"] {
+	case "message_start":
+		if message, ok := event["message"].(map[string]interface{}); ok {
+			s.id, _ = message["id"].(string)
+			s.model, _ = message["model"].(string)
+			if usage, ok := message["usage"].(map[string]interface{}); ok {
+				for k, v := range usage {
+					s.usage[k] = v
+				}
+			}
+		}
+		s.emitChunk(map[string]interface{}{"role": "assistant", "content": ""}, nil)
+	case "content_block_start":
+		block, _ := event["content_block"].(map[string]interface{})
+		if block["type"] != "tool_use" {
+			return
+		}
+		toolIndex := len(s.toolIndexes)
+		s.toolIndexes[index] = toolIndex
+		s.emitChunk(map[string]interface{}{
+			"tool_calls": []map[string]interface{}{{
+				"index": toolIndex,
+				"id":    block["id"],
+				"type":  "function",
+				"function": map[string]interface{}{
+					"name":      block["name"],
+					"arguments": "",
+				},
+			}},
+		}, nil)
+	case "content_block_delta":
+		delta, _ := event["delta"].(map[string]interface{})
+		switch delta["type"] {
+		case "text_delta":
+			if text, ok := delta["text"].(string); ok && text != "" {
+				s.emitChunk(map[string]interface{}{"content": text}, nil)
+			}
+		case "input_json_delta":
+			toolIndex, ok := s.toolIndexes[index]
+			partial, _ := delta["partial_json"].(string)
+			if !ok || partial == "" {
+				return
+			}
+			s.emitChunk(map[string]interface{}{
+				"tool_calls": []map[string]interface{}{{
+					"index":    toolIndex,
+					"function": map[string]interface{}{"arguments": partial},
+				}},
+			}, nil)
+		}
+	case "message_delta":
+		if delta, ok := event["delta"].(map[string]interface{}); ok {
+			if stopReason, ok := delta["stop_reason"].(string); ok && stopReason != "" {
+				s.finishReason = mapAnthropicStopReason(stopReason)
+			}
+		}
+		if usage, ok := event["usage"].(map[string]interface{}); ok {
+			for k, v := range usage {
+				s.usage[k] = v
+			}
+		}
+	case "message_stop":
+		s.Finish()
+	case "error":
+		errorJSON, _ := json.Marshal(event)
+		fmt.Fprintf(s.w, "data: %s\n\n", OpenAIErrorBody(errorJSON, 0))
+	}
+}
+
+// HandleError reports an error in the middle of the stream and terminates it
+func (s *AnthropicToOpenAIStream) HandleError(body []byte, statusCode int) {
+	if s.done {
+		return
+	}
+	fmt.Fprintf(s.w, "data: %s\n\n", OpenAIErrorBody(body, statusCode))
+	fmt.Fprint(s.w, "data: [DONE]\n\n")
+	s.done = true
+}
+
+// Finish sends the final chunk with the finish_reason, the optional usage chunk and [DONE]
+func (s *AnthropicToOpenAIStream) Finish() {
+	if s.done {
+		return
+	}
+	s.done = true
+
+	if s.id != "" {
+		finishReason := s.finishReason
+		if finishReason == "" {
+			finishReason = "stop"
+		}
+		s.emitChunk(map[string]interface{}{}, finishReason)
+
+		if s.includeUsage {
+			s.emit(map[string]interface{}{
+				"id":      s.id,
+				"object":  "chat.completion.chunk",
+				"created": s.created,
+				"model":   s.model,
+				"choices": []interface{}{},
+				"usage":   anthropicUsageToOpenAI(s.usage),
+			})
+		}
+	}
+	fmt.Fprint(s.w, "data: [DONE]\n\n")
+}
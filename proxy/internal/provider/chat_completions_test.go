@@ -0,0 +1,249 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestConvertOpenAIRequestToAnthropic(t *testing.T) {
+	body := `{
+		"model": "claude-sonnet-4-5",
+		"stream": true,
+		"stream_options": {"include_usage": true},
+		"max_tokens": 1000,
+		"temperature": 1.5,
+		"stop": "END",
+		"messages": [
+			{"role": "system", "content": "Be brief."},
+			{"role": "user", "content": [
+				{"type": "text", "text": "What is in /tmp?"},
+				{"type": "image_url", "image_url": {"url": "data:image/png;base64,aGVsbG8="}}
+			]},
+			{"role": "assistant", "content": null, "tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "Bash", "arguments": "{\"command\":\"ls /tmp\"}"}}
+			]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "a.txt"},
+			{"role": "user", "content": "Thanks"}
+		],
+		"tools": [
+			{"type": "function", "function": {"name": "Bash", "description": "Run a command", "parameters": {"type": "object", "properties": {"command": {"type": "string"}}}}}
+		],
+		"tool_choice": "required"
+	}`
+
+	anthropicBody, opts, err := ConvertOpenAIRequestToAnthropic([]byte(body))
+	if err != nil {
+		t.Fatalf("ConvertOpenAIRequestToAnthropic returned error: %v", err)
+	}
+	if !opts.Stream || !opts.IncludeUsage {
+		t.Errorf("opts = %+v, want stream with usage", opts)
+	}
+
+	var req map[string]interface{}
+	if err := json.Unmarshal(anthropicBody, &req); err != nil {
+		t.Fatalf("invalid anthropic body: %v", err)
+	}
+
+	if req["model"] != "claude-sonnet-4-5" || req["max_tokens"].(float64) != 1000 || req["stream"] != true {
+		t.Errorf("unexpected top-level fields: %v", req)
+	}
+	if req["temperature"].(float64) != 1 {
+		t.Errorf("temperature = %v, want clamped to 1", req["temperature"])
+	}
+	if stops := req["stop_sequences"].([]interface{}); len(stops) != 1 || stops[0] != "END" {
+		t.Errorf("stop_sequences = %v", stops)
+	}
+
+	system := req["system"].([]interface{})
+	if len(system) != 1 || system[0].(map[string]interface{})["text"] != "Be brief." {
+		t.Errorf("system = %v", system)
+	}
+
+	messages := req["messages"].([]interface{})
+	if len(messages) != 3 {
+		t.Fatalf("got %d messages, want 3 (tool result merged with the following user turn): %v", len(messages), messages)
+	}
+
+	first := messages[0].(map[string]interface{})["content"].([]interface{})
+	image := first[1].(map[string]interface{})
+	source := image["source"].(map[string]interface{})
+	if image["type"] != "image" || source["type"] != "base64" || source["media_type"] != "image/png" || source["data"] != "aGVsbG8=" {
+		t.Errorf("unexpected image block: %v", image)
+	}
+
+	assistant := messages[1].(map[string]interface{})
+	toolUse := assistant["content"].([]interface{})[0].(map[string]interface{})
+	if assistant["role"] != "assistant" || toolUse["type"] != "tool_use" || toolUse["id"] != "call_1" || toolUse["name"] != "Bash" {
+		t.Errorf("unexpected assistant message: %v", assistant)
+	}
+	if toolUse["input"].(map[string]interface{})["command"] != "ls /tmp" {
+		t.Errorf("tool input = %v", toolUse["input"])
+	}
+
+	last := messages[2].(map[string]interface{})
+	lastContent := last["content"].([]interface{})
+	toolResult := lastContent[0].(map[string]interface{})
+	if last["role"] != "user" || len(lastContent) != 2 || toolResult["type"] != "tool_result" || toolResult["tool_use_id"] != "call_1" || toolResult["content"] != "a.txt" {
+		t.Errorf("unexpected final user message: %v", last)
+	}
+
+	tool := req["tools"].([]interface{})[0].(map[string]interface{})
+	if tool["name"] != "Bash" || tool["description"] != "Run a command" || tool["input_schema"] == nil {
+		t.Errorf("unexpected tool: %v", tool)
+	}
+	if choice := req["tool_choice"].(map[string]interface{}); choice["type"] != "any" {
+		t.Errorf("tool_choice = %v, want any", choice)
+	}
+}
+
+func TestConvertOpenAIRequestToAnthropic_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid json", `{`},
+		{"missing model", `{"messages":[{"role":"user","content":"hi"}]}`},
+		{"missing messages", `{"model":"claude-sonnet-4-5"}`},
+		{"multiple choices", `{"model":"claude-sonnet-4-5","n":2,"messages":[{"role":"user","content":"hi"}]}`},
+		{"unknown role", `{"model":"claude-sonnet-4-5","messages":[{"role":"function","content":"hi"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ConvertOpenAIRequestToAnthropic([]byte(tt.body)); err == nil {
+				t.Errorf("expected an error for %s", tt.body)
+			}
+		})
+	}
+}
+
+func TestTransformAnthropicResponseToOpenAI(t *testing.T) {
+	body := `{
+		"id": "msg_1",
+		"type": "message",
+		"model": "claude-sonnet-4-5",
+		"content": [
+			{"type": "thinking", "thinking": "hmm"},
+			{"type": "text", "text": "Listing."},
+			{"type": "tool_use", "id": "toolu_1", "name": "Bash", "input": {"command": "ls"}}
+		],
+		"stop_reason": "tool_use",
+		"usage": {"input_tokens": 10, "cache_read_input_tokens": 90, "output_tokens": 5}
+	}`
+
+	out, err := TransformAnthropicResponseToOpenAI([]byte(body))
+	if err != nil {
+		t.Fatalf("TransformAnthropicResponseToOpenAI returned error: %v", err)
+	}
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("invalid response JSON: %v", err)
+	}
+
+	choice := resp["choices"].([]interface{})[0].(map[string]interface{})
+	message := choice["message"].(map[string]interface{})
+	if resp["object"] != "chat.completion" || choice["finish_reason"] != "tool_calls" || message["content"] != "Listing." {
+		t.Errorf("unexpected response: %s", out)
+	}
+
+	toolCall := message["tool_calls"].([]interface{})[0].(map[string]interface{})
+	function := toolCall["function"].(map[string]interface{})
+	if toolCall["id"] != "toolu_1" || function["name"] != "Bash" || function["arguments"] != `{"command":"ls"}` {
+		t.Errorf("unexpected tool call: %v", toolCall)
+	}
+
+	usage := resp["usage"].(map[string]interface{})
+	cached := usage["prompt_tokens_details"].(map[string]interface{})["cached_tokens"]
+	if usage["prompt_tokens"].(float64) != 100 || usage["completion_tokens"].(float64) != 5 || usage["total_tokens"].(float64) != 105 || cached.(float64) != 90 {
+		t.Errorf("unexpected usage: %v", usage)
+	}
+}
+
+func TestAnthropicToOpenAIStream(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-5","usage":{"input_tokens":7}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"Read","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"file_path\":\"/a\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":3}}`,
+		`{"type":"message_stop"}`,
+	}
+
+	var out bytes.Buffer
+	stream := NewAnthropicToOpenAIStream(&out, true)
+	for _, event := range events {
+		stream.HandleEvent([]byte(event))
+	}
+	stream.Finish()
+
+	if !strings.HasSuffix(out.String(), "data: [DONE]\n\n") || strings.Count(out.String(), "[DONE]") != 1 {
+		t.Fatalf("stream should end with a single [DONE]: %q", out.String())
+	}
+
+	chunks := parseAnthropicEvents(t, strings.TrimSuffix(out.String(), "data: [DONE]\n\n"))
+	if len(chunks) != 6 {
+		t.Fatalf("got %d chunks, want 6: %s", len(chunks), out.String())
+	}
+
+	delta := func(i int) map[string]interface{} {
+		return chunks[i]["choices"].([]interface{})[0].(map[string]interface{})["delta"].(map[string]interface{})
+	}
+
+	if delta(0)["role"] != "assistant" || delta(1)["content"] != "Hi" {
+		t.Errorf("unexpected text chunks: %v %v", chunks[0], chunks[1])
+	}
+
+	start := delta(2)["tool_calls"].([]interface{})[0].(map[string]interface{})
+	if start["index"].(float64) != 0 || start["id"] != "toolu_1" || start["function"].(map[string]interface{})["name"] != "Read" {
+		t.Errorf("unexpected tool call start: %v", start)
+	}
+	args := delta(3)["tool_calls"].([]interface{})[0].(map[string]interface{})["function"].(map[string]interface{})["arguments"]
+	if args != `{"file_path":"/a"}` {
+		t.Errorf("tool arguments = %v", args)
+	}
+
+	if finish := chunks[4]["choices"].([]interface{})[0].(map[string]interface{})["finish_reason"]; finish != "tool_calls" {
+		t.Errorf("finish_reason = %v, want tool_calls", finish)
+	}
+
+	usage := chunks[5]["usage"].(map[string]interface{})
+	if usage["prompt_tokens"].(float64) != 7 || usage["completion_tokens"].(float64) != 3 {
+		t.Errorf("unexpected usage chunk: %v", chunks[5])
+	}
+}
+
+func TestOpenAIErrorBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantType    string
+		wantMessage string
+	}{
+		{"anthropic error", `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, "overloaded_error", "Overloaded"},
+		{"proxy error", `{"error":"Failed to route request"}`, "api_error", "Failed to route request"},
+		{"plain text", `bad gateway`, "api_error", "bad gateway"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got struct {
+				Error struct {
+					Type    string `json:"type"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(OpenAIErrorBody([]byte(tt.body), 502), &got); err != nil {
+				t.Fatalf("invalid error JSON: %v", err)
+			}
+			if got.Error.Type != tt.wantType || got.Error.Message != tt.wantMessage {
+				t.Errorf("got %+v, want type=%s message=%s", got.Error, tt.wantType, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+)
+
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// ErrCircuitOpen is returned without contacting the upstream while its circuit is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// HealthReporter is implemented by providers that track the health of their upstream
+type HealthReporter interface {
+	Health() model.ProviderHealth
+}
+
+// CircuitBreaker wraps a provider and stops sending it requests after repeated upstream
+// failures. After the cooldown a single probe request is let through (half-open); its
+// outcome closes the circuit again or restarts the cooldown.
+type CircuitBreaker struct {
+	Provider
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	lastError string
+}
+
+// NewCircuitBreaker wraps p in a circuit breaker, or returns p unchanged when the breaker is disabled
+func NewCircuitBreaker(p Provider, cfg *config.CircuitBreakerConfig) Provider {
+	if cfg.FailureThreshold <= 0 {
+		return p
+	}
+	return &CircuitBreaker{
+		Provider:  p,
+		threshold: cfg.FailureThreshold,
+		cooldown:  cfg.CooldownDuration,
+		now:       time.Now,
+		state:     CircuitClosed,
+	}
+}
+
+func (b *CircuitBreaker) ForwardRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if isShadowTraffic(ctx) {
+		// Shadow outcomes say nothing about how the primary traffic is served, and they must
+		// not take the half-open probe
+		b.mu.Lock()
+		state := b.state
+		b.mu.Unlock()
+		if state != CircuitClosed {
+			return nil, fmt.Errorf("%s: %w", b.Name(), ErrCircuitOpen)
+		}
+		return b.Provider.ForwardRequest(ctx, req)
+	}
+
+	if !b.allow() {
+		notifyAttempt(ctx, model.RequestAttempt{
+			Provider:  b.Name(),
+			Error:     ErrCircuitOpen.Error(),
+			StartedAt: b.now().Format(time.RFC3339Nano),
+		})
+		return nil, fmt.Errorf("%s: %w", b.Name(), ErrCircuitOpen)
+	}
+
+	resp, err := b.Provider.ForwardRequest(ctx, req)
+
+	switch {
+	case ctx.Err() != nil:
+		// The client went away, that says nothing about the upstream
+		b.release()
+	case err != nil:
+		b.recordFailure(err.Error())
+	case resp.StatusCode >= http.StatusInternalServerError:
+		b.recordFailure(fmt.Sprintf("status %d", resp.StatusCode))
+	default:
+		b.recordSuccess()
+	}
+
+	return resp, err
+}
+
+// allow reports whether a request may go to the upstream, moving an open circuit to half-open
+// once the cooldown has passed
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		log.Printf("🔌 [CIRCUIT] provider=%s state=%s", b.Name(), b.state)
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *CircuitBreaker) recordFailure(reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.lastError = reason
+	b.probing = false
+
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		if b.state != CircuitOpen {
+			log.Printf("🔌 [CIRCUIT] provider=%s state=%s failures=%d last_error=%q", b.Name(), CircuitOpen, b.failures, reason)
+		}
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+func (b *CircuitBreaker) recordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != CircuitClosed {
+		log.Printf("🔌 [CIRCUIT] provider=%s state=%s", b.Name(), CircuitClosed)
+	}
+	b.state = CircuitClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// release gives up a half-open probe without judging the upstream
+func (b *CircuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *CircuitBreaker) Health() model.ProviderHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	health := model.ProviderHealth{
+		Name:                b.Name(),
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if b.state != CircuitClosed {
+		openedAt := b.openedAt
+		health.OpenedAt = &openedAt
+		retryAt := b.openedAt.Add(b.cooldown)
+		health.RetryAt = &retryAt
+	}
+	return health
+}
@@ -0,0 +1,142 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+)
+
+type fakeProvider struct {
+	calls  int
+	status int
+	err    error
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) ForwardRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &http.Response{StatusCode: p.status, Body: http.NoBody}, nil
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	upstream := &fakeProvider{err: errors.New("connection refused")}
+	b := NewCircuitBreaker(upstream, &config.CircuitBreakerConfig{FailureThreshold: 2, CooldownDuration: time.Minute}).(*CircuitBreaker)
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+
+	forward := func() error {
+		_, err := b.ForwardRequest(context.Background(), &http.Request{})
+		return err
+	}
+
+	forward()
+	if state := b.Health().State; state != CircuitClosed {
+		t.Fatalf("state after one failure = %s, want closed", state)
+	}
+	forward()
+	if state := b.Health().State; state != CircuitOpen {
+		t.Fatalf("state after threshold = %s, want open", state)
+	}
+
+	// While open, requests fail fast without reaching the upstream
+	if err := forward(); !errors.Is(err, ErrCircuitOpen) || upstream.calls != 2 {
+		t.Fatalf("got err %v after %d upstream calls, want ErrCircuitOpen after 2", err, upstream.calls)
+	}
+
+	// After the cooldown a failing probe reopens the circuit
+	now = now.Add(time.Minute)
+	if err := forward(); errors.Is(err, ErrCircuitOpen) || upstream.calls != 3 {
+		t.Fatalf("expected a probe after the cooldown, got err %v after %d calls", err, upstream.calls)
+	}
+	if state := b.Health().State; state != CircuitOpen {
+		t.Fatalf("state after failed probe = %s, want open", state)
+	}
+
+	// A successful probe closes it
+	now = now.Add(time.Minute)
+	upstream.err = nil
+	upstream.status = http.StatusOK
+	if err := forward(); err != nil {
+		t.Fatalf("probe returned error: %v", err)
+	}
+	health := b.Health()
+	if health.State != CircuitClosed || health.ConsecutiveFailures != 0 || health.OpenedAt != nil {
+		t.Errorf("unexpected health after recovery: %+v", health)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenAllowsSingleProbe(t *testing.T) {
+	upstream := &fakeProvider{status: http.StatusOK}
+	b := NewCircuitBreaker(upstream, &config.CircuitBreakerConfig{FailureThreshold: 1, CooldownDuration: time.Second}).(*CircuitBreaker)
+
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	b.recordFailure("status 529")
+
+	now = now.Add(time.Second)
+	if !b.allow() {
+		t.Fatal("the first request after the cooldown should be let through")
+	}
+	if b.allow() {
+		t.Error("only one probe should be in flight while half-open")
+	}
+}
+
+func TestCircuitBreaker_CountsServerErrorsOnly(t *testing.T) {
+	tests := []struct {
+		status   int
+		wantOpen bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusBadRequest, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, true},
+		{529, true},
+	}
+
+	for _, tt := range tests {
+		b := NewCircuitBreaker(&fakeProvider{status: tt.status}, &config.CircuitBreakerConfig{FailureThreshold: 1, CooldownDuration: time.Minute}).(*CircuitBreaker)
+		b.ForwardRequest(context.Background(), &http.Request{})
+		if open := b.Health().State == CircuitOpen; open != tt.wantOpen {
+			t.Errorf("status %d: open = %v, want %v", tt.status, open, tt.wantOpen)
+		}
+	}
+}
+
+func TestCircuitBreaker_IgnoresShadowTraffic(t *testing.T) {
+	upstream := &fakeProvider{status: http.StatusInternalServerError}
+	b := NewCircuitBreaker(upstream, &config.CircuitBreakerConfig{FailureThreshold: 1, CooldownDuration: time.Minute}).(*CircuitBreaker)
+	shadowCtx := WithShadowTraffic(context.Background())
+
+	for i := 0; i < 3; i++ {
+		b.ForwardRequest(shadowCtx, &http.Request{})
+	}
+	upstream.err = errors.New("timeout")
+	b.ForwardRequest(shadowCtx, &http.Request{})
+	if health := b.Health(); health.State != CircuitClosed || health.ConsecutiveFailures != 0 {
+		t.Fatalf("shadow failures changed the circuit: %+v", health)
+	}
+
+	// While the circuit is open shadow requests are skipped and don't take the probe
+	b.ForwardRequest(context.Background(), &http.Request{})
+	calls := upstream.calls
+	if _, err := b.ForwardRequest(shadowCtx, &http.Request{}); !errors.Is(err, ErrCircuitOpen) || upstream.calls != calls {
+		t.Errorf("shadow request reached an open circuit's upstream: %v", err)
+	}
+}
+
+func TestNewCircuitBreaker_Disabled(t *testing.T) {
+	upstream := &fakeProvider{}
+	if p := NewCircuitBreaker(upstream, &config.CircuitBreakerConfig{}); p != Provider(upstream) {
+		t.Errorf("a zero threshold should return the provider unwrapped, got %T", p)
+	}
+}
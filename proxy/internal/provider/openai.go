@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"strings"
@@ -20,18 +21,23 @@ import (
 type OpenAIProvider struct {
 	client *http.Client
 	config *config.OpenAIProviderConfig
+	models config.ModelCatalog
 }
 
-func NewOpenAIProvider(cfg *config.OpenAIProviderConfig) Provider {
+func NewOpenAIProvider(cfg *config.OpenAIProviderConfig, models config.ModelCatalog) Provider {
 	return &OpenAIProvider{
 		client: &http.Client{
 			Timeout: 300 * time.Second, // 5 minutes timeout
 		},
 		config: cfg,
+		models: models,
 	}
 }
 
 func (p *OpenAIProvider) Name() string {
+	if p.config.Name != "" {
+		return p.config.Name
+	}
 	return "openai"
 }
 
@@ -49,7 +55,13 @@ func (p *OpenAIProvider) ForwardRequest(ctx context.Context, originalReq *http.R
 	}
 
 	// Convert to OpenAI format
-	openAIReq := convertAnthropicToOpenAI(&anthropicReq)
+	// Limits and capabilities of the target model come from the catalog when it's listed
+	spec, _ := p.models.Lookup(anthropicReq.Model)
+	openAIReq, err := convertAnthropicToOpenAI(&anthropicReq, spec)
+	if err != nil {
+		// Content the OpenAI API can't accept is reported the same way Anthropic rejects a bad request
+		return newAnthropicErrorResponse(originalReq, http.StatusBadRequest, "invalid_request_error", err.Error()), nil
+	}
 	newBodyBytes, err := json.Marshal(openAIReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal openai request: %w", err)
@@ -69,7 +81,7 @@ func (p *OpenAIProvider) ForwardRequest(ctx context.Context, originalReq *http.R
 	// Update the destination URL for OpenAI
 	proxyReq.URL.Scheme = baseURL.Scheme
 	proxyReq.URL.Host = baseURL.Host
-	proxyReq.URL.Path = "/v1/chat/completions" // OpenAI endpoint
+	proxyReq.URL.Path = chatCompletionsPath(baseURL.Path)
 
 	// Update request headers
 	proxyReq.RequestURI = ""
@@ -79,14 +91,22 @@ func (p *OpenAIProvider) ForwardRequest(ctx context.Context, originalReq *http.R
 	proxyReq.Header.Del("anthropic-version")
 	proxyReq.Header.Del("x-api-key")
 
-	// Add OpenAI headers
+	// Add OpenAI headers. The client's Authorization is meant for Anthropic and never forwarded.
+	proxyReq.Header.Del("Authorization")
 	if p.config.APIKey != "" {
 		proxyReq.Header.Set("Authorization", "Bearer "+p.config.APIKey)
 	}
 	proxyReq.Header.Set("Content-Type", "application/json")
+	for name, value := range p.config.Headers {
+		proxyReq.Header.Set(name, value)
+	}
 
 	// Forward the request
-	resp, err := p.client.Do(proxyReq)
+	resp, err := newRetryPolicy(p.config.MaxRetries).do(ctx, p.Name(), func() (*http.Response, error) {
+		attemptReq := proxyReq.Clone(ctx)
+		attemptReq.Body = io.NopCloser(bytes.NewReader(newBodyBytes))
+		return p.client.Do(attemptReq)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to forward request: %w", err)
 	}
@@ -101,14 +121,7 @@ func (p *OpenAIProvider) ForwardRequest(ctx context.Context, originalReq *http.R
 		// OpenAI API error - will be returned to client
 
 		// Create an error response in Anthropic format
-		errorResp := map[string]interface{}{
-			"type": "error",
-			"error": map[string]interface{}{
-				"type":    "api_error",
-				"message": fmt.Sprintf("OpenAI API error: %s", string(errorBody)),
-			},
-		}
-		errorJSON, _ := json.Marshal(errorResp)
+		errorJSON := anthropicErrorBody("api_error", fmt.Sprintf("OpenAI API error: %s", string(errorBody)))
 
 		// Create a new response with the error
 		resp.Body = io.NopCloser(bytes.NewReader(errorJSON))
@@ -164,7 +177,52 @@ func (p *OpenAIProvider) ForwardRequest(ctx context.Context, originalReq *http.R
 	return resp, nil
 }
 
-func convertAnthropicToOpenAI(req *model.AnthropicRequest) map[string]interface{} {
+// chatCompletionsPath appends the chat completions endpoint to a base URL path. Base URLs of
+// OpenAI-compatible servers are given both with and without the /v1 suffix.
+func chatCompletionsPath(basePath string) string {
+	basePath = strings.TrimSuffix(basePath, "/")
+	if strings.HasSuffix(basePath, "/v1") {
+		return basePath + "/chat/completions"
+	}
+	return basePath + "/v1/chat/completions"
+}
+
+// anthropicErrorBody builds an error payload in the format Anthropic clients expect
+func anthropicErrorBody(errorType, message string) []byte {
+	errorResp := map[string]interface{}{
+		"type": "error",
+		"error": map[string]interface{}{
+			"type":    errorType,
+			"message": message,
+		},
+	}
+	errorJSON, _ := json.Marshal(errorResp)
+	return errorJSON
+}
+
+// newAnthropicErrorResponse creates a local error response for requests that are rejected before reaching the upstream
+func newAnthropicErrorResponse(req *http.Request, statusCode int, errorType, message string) *http.Response {
+	body := anthropicErrorBody(errorType, message)
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	header.Set("Content-Length", fmt.Sprintf("%d", len(body)))
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// convertAnthropicToOpenAI builds the OpenAI request. spec is the catalog entry of the target
+// model, nil when the model isn't in the catalog.
+func convertAnthropicToOpenAI(req *model.AnthropicRequest, spec *config.ModelConfig) (map[string]interface{}, error) {
 	messages := []map[string]interface{}{}
 
 	// Combine all system messages into a single system message for OpenAI
@@ -183,160 +241,16 @@ func convertAnthropicToOpenAI(req *model.AnthropicRequest) map[string]interface{
 	}
 
 	// Add conversation messages
-	for _, msg := range req.Messages {
-		// Handle messages with raw content that may contain tool results
-		if contentArray, ok := msg.Content.([]interface{}); ok {
-			// Check if this message contains tool results
-			hasToolResults := false
-			for _, item := range contentArray {
-				if block, ok := item.(map[string]interface{}); ok {
-					if blockType, hasType := block["type"].(string); hasType && blockType == "tool_result" {
-						hasToolResults = true
-						break
-					}
-				}
-			}
-
-			if hasToolResults {
-				textContent := ""
-
-				for _, item := range contentArray {
-					if block, ok := item.(map[string]interface{}); ok {
-						if blockType, hasType := block["type"].(string); hasType {
-							if blockType == "text" {
-								if text, hasText := block["text"].(string); hasText {
-									textContent += text + "\n"
-								}
-							} else if blockType == "tool_result" {
-								// Extract tool ID
-								toolID := ""
-								if id, hasID := block["tool_use_id"].(string); hasID {
-									toolID = id
-								}
-
-								// Handle different formats of tool result content
-								resultContent := ""
-								if content, hasContent := block["content"]; hasContent {
-									if contentStr, ok := content.(string); ok {
-										resultContent = contentStr
-									} else if contentList, ok := content.([]interface{}); ok {
-										// If content is a list of blocks, extract text from each
-										for _, c := range contentList {
-											if contentMap, ok := c.(map[string]interface{}); ok {
-												if contentMap["type"] == "text" {
-													if text, ok := contentMap["text"].(string); ok {
-														resultContent += text + "\n"
-													}
-												} else if text, hasText := contentMap["text"]; hasText {
-													// Handle any dict by trying to extract text
-													resultContent += fmt.Sprintf("%v\n", text)
-												} else {
-													// Try to JSON serialize
-													if jsonBytes, err := json.Marshal(contentMap); err == nil {
-														resultContent += string(jsonBytes) + "\n"
-													} else {
-														resultContent += fmt.Sprintf("%v\n", contentMap)
-													}
-												}
-											}
-										}
-									} else if contentDict, ok := content.(map[string]interface{}); ok {
-										// Handle dictionary content
-										if contentDict["type"] == "text" {
-											if text, ok := contentDict["text"].(string); ok {
-												resultContent = text
-											}
-										} else {
-											// Try to JSON serialize
-											if jsonBytes, err := json.Marshal(contentDict); err == nil {
-												resultContent = string(jsonBytes)
-											} else {
-												resultContent = fmt.Sprintf("%v", contentDict)
-											}
-										}
-									} else {
-										// Handle any other type by converting to string
-										if jsonBytes, err := json.Marshal(content); err == nil {
-											resultContent = string(jsonBytes)
-										} else {
-											resultContent = fmt.Sprintf("%v", content)
-										}
-									}
-								}
-
-								// In OpenAI format, tool results come from the user (matching Python behavior)
-								textContent += fmt.Sprintf("Tool result for %s:\n%s\n", toolID, resultContent)
-							}
-						}
-					}
-				}
-
-				// Add as a single user message with all the content
-				if textContent == "" {
-					textContent = "..."
-				}
-				messages = append(messages, map[string]interface{}{
-					"role":    msg.Role,
-					"content": strings.TrimSpace(textContent),
-				})
-			} else {
-				// Handle regular messages with content blocks
-				content := ""
-
-				for _, item := range contentArray {
-					if block, ok := item.(map[string]interface{}); ok {
-						if blockType, hasType := block["type"].(string); hasType && blockType == "text" {
-							if text, hasText := block["text"].(string); hasText {
-								if content != "" {
-									content += "\n"
-								}
-								content += text
-							}
-						}
-					}
-				}
-
-				// Ensure content is never empty
-				if content == "" {
-					content = "..."
-				}
-
-				messages = append(messages, map[string]interface{}{
-					"role":    msg.Role,
-					"content": content,
-				})
-			}
-		} else {
-			// Handle simple string content
-			contentBlocks := msg.GetContentBlocks()
-			content := ""
-
-			// Concatenate all text blocks
-			for _, block := range contentBlocks {
-				if block.Type == "text" {
-					if content != "" {
-						content += "\n"
-					}
-					content += block.Text
-				}
-			}
-
-			// Ensure content is never empty
-			if content == "" {
-				content = "..."
-			}
-
-			messages = append(messages, map[string]interface{}{
-				"role":    msg.Role,
-				"content": content,
-			})
+	for i, msg := range req.Messages {
+		converted, err := convertMessageToOpenAI(msg)
+		if err != nil {
+			return nil, fmt.Errorf("messages.%d: %w", i, err)
 		}
+		messages = append(messages, converted...)
 	}
-	// Check if max_tokens exceeds the model's limit and cap it if necessary
-	maxTokensLimit := 16384 // Assuming this is the limit for the model
-	if req.MaxTokens > maxTokensLimit {
-		// Capping max_tokens to model limit
-		req.MaxTokens = maxTokensLimit
+	// Claude Code asks for more output than most OpenAI models allow, cap it to the model's limit
+	if spec != nil && spec.MaxOutputTokens > 0 && req.MaxTokens > spec.MaxOutputTokens {
+		req.MaxTokens = spec.MaxOutputTokens
 	}
 
 	// All OpenAI models now use max_completion_tokens instead of deprecated max_tokens
@@ -354,11 +268,16 @@ func convertAnthropicToOpenAI(req *model.AnthropicRequest) map[string]interface{
 		}
 	}
 
-	// Check if this is an o-series model (they don't support temperature)
-	isOSeriesModel := strings.HasPrefix(req.Model, "o1") || strings.HasPrefix(req.Model, "o3")
-
-	// Only include temperature for non-o-series models
-	if !isOSeriesModel {
+	// Reasoning models (o-series, gpt-5) don't support temperature but accept a reasoning effort
+	reasoning := isOpenAIReasoningModel(req.Model)
+	if spec != nil {
+		reasoning = spec.Supports(config.CapabilityThinking)
+	}
+	if reasoning {
+		if effort := reasoningEffortForThinking(req.Thinking); effort != "" {
+			openAIReq["reasoning_effort"] = effort
+		}
+	} else {
 		openAIReq["temperature"] = req.Temperature
 	}
 	// Convert Anthropic tools to OpenAI format
@@ -455,7 +374,344 @@ func convertAnthropicToOpenAI(req *model.AnthropicRequest) map[string]interface{
 		}
 	}
 
-	return openAIReq
+	return openAIReq, nil
+}
+
+// convertMessageToOpenAI converts a single Anthropic message into one or more OpenAI messages.
+// Assistant tool_use blocks become tool_calls, and user tool_result blocks become separate
+// "tool" role messages so the upstream model keeps the full tool history. Image and document
+// blocks turn the user message into a multi-part content array.
+func convertMessageToOpenAI(msg model.AnthropicMessage) ([]map[string]interface{}, error) {
+	contentArray, ok := msg.Content.([]interface{})
+	if !ok {
+		// Handle simple string content
+		content := ""
+		for _, block := range msg.GetContentBlocks() {
+			if block.Type == "text" {
+				if content != "" {
+					content += "\n"
+				}
+				content += block.Text
+			}
+		}
+
+		// Ensure content is never empty
+		if content == "" {
+			content = "..."
+		}
+		return []map[string]interface{}{{
+			"role":    msg.Role,
+			"content": content,
+		}}, nil
+	}
+
+	var textParts []string
+	var contentParts []map[string]interface{} // ordered text and media parts
+	hasMedia := false
+	var toolCalls []map[string]interface{}
+	var toolMessages []map[string]interface{}
+	var toolResultMedia []map[string]interface{}
+
+	for i, item := range contentArray {
+		block, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		blockType, _ := block["type"].(string)
+
+		switch blockType {
+		case "text":
+			if text, hasText := block["text"].(string); hasText && text != "" {
+				textParts = append(textParts, text)
+				contentParts = append(contentParts, openAITextPart(text))
+			}
+		case "image", "document":
+			parts, err := convertMediaBlockToOpenAI(block)
+			if err != nil {
+				return nil, fmt.Errorf("content.%d: %w", i, err)
+			}
+			for _, part := range parts {
+				if part["type"] == "text" {
+					textParts = append(textParts, part["text"].(string))
+				} else {
+					hasMedia = true
+				}
+				contentParts = append(contentParts, part)
+			}
+		case "tool_use":
+			toolCalls = append(toolCalls, convertToolUseToOpenAI(block))
+		case "tool_result":
+			toolID, _ := block["tool_use_id"].(string)
+			resultContent := stringifyToolResultContent(block["content"])
+			if isError, _ := block["is_error"].(bool); isError {
+				resultContent = "Error: " + resultContent
+			}
+			toolMessages = append(toolMessages, map[string]interface{}{
+				"role":         "tool",
+				"tool_call_id": toolID,
+				"content":      strings.TrimSpace(resultContent),
+			})
+
+			// OpenAI tool messages are text-only, so screenshots and other media returned by a
+			// tool are forwarded in the user message that follows the tool results
+			media, err := toolResultMediaParts(block["content"])
+			if err != nil {
+				return nil, fmt.Errorf("content.%d: %w", i, err)
+			}
+			if len(media) > 0 {
+				toolResultMedia = append(toolResultMedia, openAITextPart(fmt.Sprintf("Attachments from tool result %s:", toolID)))
+				toolResultMedia = append(toolResultMedia, media...)
+			}
+		}
+	}
+
+	content := strings.Join(textParts, "\n")
+
+	if msg.Role == "assistant" {
+		assistantMsg := map[string]interface{}{
+			"role": "assistant",
+		}
+		if len(toolCalls) > 0 {
+			assistantMsg["tool_calls"] = toolCalls
+			// OpenAI accepts null content when the assistant only calls tools
+			if content != "" {
+				assistantMsg["content"] = content
+			} else {
+				assistantMsg["content"] = nil
+			}
+		} else {
+			if content == "" {
+				content = "..."
+			}
+			assistantMsg["content"] = content
+		}
+		return []map[string]interface{}{assistantMsg}, nil
+	}
+
+	// Tool results must directly follow the assistant message that issued the tool calls,
+	// so they go first and any remaining user content follows as a regular message
+	messages := toolMessages
+	if len(toolResultMedia) > 0 {
+		contentParts = append(toolResultMedia, contentParts...)
+		hasMedia = true
+	}
+
+	if hasMedia {
+		messages = append(messages, map[string]interface{}{
+			"role":    msg.Role,
+			"content": contentParts,
+		})
+	} else if content != "" || len(toolMessages) == 0 {
+		if content == "" {
+			content = "..."
+		}
+		messages = append(messages, map[string]interface{}{
+			"role":    msg.Role,
+			"content": content,
+		})
+	}
+	return messages, nil
+}
+
+func openAITextPart(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "text",
+		"text": text,
+	}
+}
+
+// convertMediaBlockToOpenAI converts an Anthropic image or document block into OpenAI content parts
+func convertMediaBlockToOpenAI(block map[string]interface{}) ([]map[string]interface{}, error) {
+	blockType, _ := block["type"].(string)
+	source, ok := block["source"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s block is missing a source", blockType)
+	}
+	sourceType, _ := source["type"].(string)
+	mediaType, _ := source["media_type"].(string)
+
+	if blockType == "image" {
+		switch sourceType {
+		case "base64":
+			data, _ := source["data"].(string)
+			return []map[string]interface{}{openAIImagePart(fmt.Sprintf("data:%s;base64,%s", mediaType, data))}, nil
+		case "url":
+			imageURL, _ := source["url"].(string)
+			return []map[string]interface{}{openAIImagePart(imageURL)}, nil
+		default:
+			return nil, fmt.Errorf("image source type %q is not supported by OpenAI models", sourceType)
+		}
+	}
+
+	title, _ := block["title"].(string)
+	switch sourceType {
+	case "text":
+		data, _ := source["data"].(string)
+		if title != "" {
+			data = title + "\n\n" + data
+		}
+		return []map[string]interface{}{openAITextPart(data)}, nil
+	case "content":
+		// Custom content documents are a list of text and image blocks
+		var parts []map[string]interface{}
+		if title != "" {
+			parts = append(parts, openAITextPart(title))
+		}
+		if contentList, ok := source["content"].([]interface{}); ok {
+			for _, c := range contentList {
+				contentBlock, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				switch contentBlock["type"] {
+				case "text":
+					if text, ok := contentBlock["text"].(string); ok && text != "" {
+						parts = append(parts, openAITextPart(text))
+					}
+				case "image":
+					imageParts, err := convertMediaBlockToOpenAI(contentBlock)
+					if err != nil {
+						return nil, err
+					}
+					parts = append(parts, imageParts...)
+				}
+			}
+		} else if text, ok := source["content"].(string); ok && text != "" {
+			parts = append(parts, openAITextPart(text))
+		}
+		return parts, nil
+	case "base64":
+		if mediaType != "application/pdf" {
+			return nil, fmt.Errorf("document media type %q is not supported by OpenAI models", mediaType)
+		}
+		data, _ := source["data"].(string)
+		filename := title
+		if filename == "" {
+			filename = "document.pdf"
+		}
+		return []map[string]interface{}{{
+			"type": "file",
+			"file": map[string]interface{}{
+				"filename":  filename,
+				"file_data": fmt.Sprintf("data:%s;base64,%s", mediaType, data),
+			},
+		}}, nil
+	default:
+		return nil, fmt.Errorf("document source type %q is not supported by OpenAI models", sourceType)
+	}
+}
+
+func openAIImagePart(imageURL string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "image_url",
+		"image_url": map[string]interface{}{
+			"url": imageURL,
+		},
+	}
+}
+
+// toolResultMediaParts extracts the image and document blocks nested in tool_result content
+func toolResultMediaParts(content interface{}) ([]map[string]interface{}, error) {
+	contentList, ok := content.([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	var parts []map[string]interface{}
+	for _, c := range contentList {
+		block, ok := c.(map[string]interface{})
+		if !ok || (block["type"] != "image" && block["type"] != "document") {
+			continue
+		}
+		converted, err := convertMediaBlockToOpenAI(block)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, converted...)
+	}
+	return parts, nil
+}
+
+// convertToolUseToOpenAI converts an Anthropic tool_use block into an OpenAI tool call
+func convertToolUseToOpenAI(block map[string]interface{}) map[string]interface{} {
+	id, _ := block["id"].(string)
+	name, _ := block["name"].(string)
+
+	// OpenAI expects arguments as a JSON-encoded string
+	arguments := "{}"
+	if input, hasInput := block["input"]; hasInput && input != nil {
+		if inputBytes, err := json.Marshal(input); err == nil {
+			arguments = string(inputBytes)
+		}
+	}
+
+	return map[string]interface{}{
+		"id":   id,
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":      name,
+			"arguments": arguments,
+		},
+	}
+}
+
+// stringifyToolResultContent flattens the different formats of tool_result content into text
+func stringifyToolResultContent(content interface{}) string {
+	resultContent := ""
+	if content == nil {
+		return resultContent
+	}
+
+	if contentStr, ok := content.(string); ok {
+		resultContent = contentStr
+	} else if contentList, ok := content.([]interface{}); ok {
+		// If content is a list of blocks, extract text from each
+		for _, c := range contentList {
+			if contentMap, ok := c.(map[string]interface{}); ok {
+				if contentMap["type"] == "text" {
+					if text, ok := contentMap["text"].(string); ok {
+						resultContent += text + "\n"
+					}
+				} else if contentMap["type"] == "image" || contentMap["type"] == "document" {
+					// Media is forwarded separately, see toolResultMediaParts
+					resultContent += fmt.Sprintf("[%s attached]\n", contentMap["type"])
+				} else if text, hasText := contentMap["text"]; hasText {
+					// Handle any dict by trying to extract text
+					resultContent += fmt.Sprintf("%v\n", text)
+				} else {
+					// Try to JSON serialize
+					if jsonBytes, err := json.Marshal(contentMap); err == nil {
+						resultContent += string(jsonBytes) + "\n"
+					} else {
+						resultContent += fmt.Sprintf("%v\n", contentMap)
+					}
+				}
+			}
+		}
+	} else if contentDict, ok := content.(map[string]interface{}); ok {
+		// Handle dictionary content
+		if contentDict["type"] == "text" {
+			if text, ok := contentDict["text"].(string); ok {
+				resultContent = text
+			}
+		} else {
+			// Try to JSON serialize
+			if jsonBytes, err := json.Marshal(contentDict); err == nil {
+				resultContent = string(jsonBytes)
+			} else {
+				resultContent = fmt.Sprintf("%v", contentDict)
+			}
+		}
+	} else {
+		// Handle any other type by converting to string
+		if jsonBytes, err := json.Marshal(content); err == nil {
+			resultContent = string(jsonBytes)
+		} else {
+			resultContent = fmt.Sprintf("%v", content)
+		}
+	}
+
+	return resultContent
 }
 
 func getMapKeys(m map[string]interface{}) []string {
@@ -483,9 +739,13 @@ func transformOpenAIResponseToAnthropic(respBody []byte) []byte {
 
 	// Extract the assistant's message
 	var contentBlocks []map[string]interface{}
+	stopReason := "end_turn"
 
 	if choices, ok := openAIResp["choices"].([]interface{}); ok && len(choices) > 0 {
 		if choice, ok := choices[0].(map[string]interface{}); ok {
+			if finishReason, ok := choice["finish_reason"].(string); ok {
+				stopReason = mapOpenAIFinishReason(finishReason)
+			}
 			if msg, ok := choice["message"].(map[string]interface{}); ok {
 				// Handle regular text content
 				if content, ok := msg["content"].(string); ok && content != "" {
@@ -546,30 +806,22 @@ func transformOpenAIResponseToAnthropic(respBody []byte) []byte {
 
 	// Build Anthropic-style response
 	anthropicResp := map[string]interface{}{
-		"id":      openAIResp["id"],
-		"type":    "message",
-		"role":    "assistant",
-		"content": contentBlocks,
-		"model":   openAIResp["model"],
+		"id":            openAIResp["id"],
+		"type":          "message",
+		"role":          "assistant",
+		"content":       contentBlocks,
+		"model":         openAIResp["model"],
+		"stop_reason":   stopReason,
+		"stop_sequence": nil,
 	}
 
 	// Convert OpenAI usage format to Anthropic format
 	if usage, ok := openAIResp["usage"].(map[string]interface{}); ok {
-		anthropicUsage := map[string]interface{}{}
-
-		// Map prompt_tokens to input_tokens
-		if promptTokens, ok := usage["prompt_tokens"].(float64); ok {
-			anthropicUsage["input_tokens"] = int(promptTokens)
-		}
-
-		// Map completion_tokens to output_tokens
-		if completionTokens, ok := usage["completion_tokens"].(float64); ok {
-			anthropicUsage["output_tokens"] = int(completionTokens)
-		}
+		anthropicUsage := convertOpenAIUsage(usage)
 
 		// Include total_tokens if needed (though Anthropic format doesn't typically use it)
-		if totalTokens, ok := usage["total_tokens"].(float64); ok {
-			anthropicUsage["total_tokens"] = int(totalTokens)
+		if totalTokens, ok := toInt(usage["total_tokens"]); ok {
+			anthropicUsage["total_tokens"] = totalTokens
 		}
 
 		anthropicResp["usage"] = anthropicUsage
@@ -579,12 +831,293 @@ func transformOpenAIResponseToAnthropic(respBody []byte) []byte {
 	return result
 }
 
+// mapOpenAIFinishReason converts an OpenAI finish_reason into the equivalent Anthropic stop_reason
+func mapOpenAIFinishReason(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "content_filter":
+		return "refusal"
+	default:
+		return "end_turn"
+	}
+}
+
+// convertOpenAIUsage maps OpenAI token usage onto Anthropic usage fields. OpenAI counts cached
+// input in prompt_tokens, Anthropic reports it separately as cache reads, so it is moved out of
+// input_tokens. Reasoning tokens are already counted in completion_tokens, they are reported
+// separately for analytics.
+func convertOpenAIUsage(usage map[string]interface{}) map[string]interface{} {
+	anthropicUsage := map[string]interface{}{}
+
+	cachedTokens := 0
+	if details, ok := usage["prompt_tokens_details"].(map[string]interface{}); ok {
+		cachedTokens, _ = toInt(details["cached_tokens"])
+	}
+	if cachedTokens > 0 {
+		anthropicUsage["cache_read_input_tokens"] = cachedTokens
+	}
+
+	// Map prompt_tokens to input_tokens
+	if promptTokens, ok := toInt(usage["prompt_tokens"]); ok {
+		anthropicUsage["input_tokens"] = max(promptTokens-cachedTokens, 0)
+	}
+
+	// Map completion_tokens to output_tokens
+	if completionTokens, ok := toInt(usage["completion_tokens"]); ok {
+		anthropicUsage["output_tokens"] = completionTokens
+	}
+
+	if details, ok := usage["completion_tokens_details"].(map[string]interface{}); ok {
+		if reasoningTokens, ok := toInt(details["reasoning_tokens"]); ok && reasoningTokens > 0 {
+			anthropicUsage["reasoning_tokens"] = reasoningTokens
+		}
+	}
+
+	return anthropicUsage
+}
+
+// toInt handles both float64 (decoded JSON) and int token counts
+func toInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// isOpenAIReasoningModel reports whether the model only supports reasoning-style parameters,
+// for models that aren't in the catalog
+func isOpenAIReasoningModel(modelName string) bool {
+	for _, prefix := range []string{"o1", "o3", "o4", "gpt-5"} {
+		if strings.HasPrefix(modelName, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// reasoningEffortForThinking maps an Anthropic extended thinking budget to an OpenAI reasoning_effort
+func reasoningEffortForThinking(thinking *model.ThinkingConfig) string {
+	if thinking == nil || thinking.Type != "enabled" {
+		return ""
+	}
+
+	switch {
+	case thinking.BudgetTokens < 4096:
+		return "low"
+	case thinking.BudgetTokens < 16384:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+// openAIStreamState tracks the Anthropic content blocks opened while translating an OpenAI stream
+type openAIStreamState struct {
+	w              io.Writer
+	messageStarted bool
+	nextIndex      int         // index assigned to the next content block
+	openIndex      int         // index of the currently open block, -1 if none
+	textIndex      int         // index of the open text block, -1 if none
+	toolIndexes    map[int]int // OpenAI tool_call index -> Anthropic block index
+	toolOpen       bool        // whether the open block is a tool_use block
+	pendingTools   []*pendingToolCall
+	stopReason     string
+	usage          map[string]interface{}
+}
+
+// pendingToolCall is a tool call that started while another tool_use block was streaming. Its
+// arguments are buffered and sent once the model finished, so late arguments of the open block
+// still reach it.
+type pendingToolCall struct {
+	openAIIndex int
+	id          string
+	name        string
+	arguments   strings.Builder
+}
+
+func newOpenAIStreamState(w io.Writer) *openAIStreamState {
+	return &openAIStreamState{
+		w:           w,
+		openIndex:   -1,
+		textIndex:   -1,
+		toolIndexes: make(map[int]int),
+	}
+}
+
+func (s *openAIStreamState) emit(event map[string]interface{}) {
+	eventJSON, _ := json.Marshal(event)
+	fmt.Fprintf(s.w, "data: %s\n\n", eventJSON)
+}
+
+// closeOpenBlock sends content_block_stop for the currently open block, if any.
+// Anthropic streams blocks sequentially, so a block must be closed before the next one starts.
+func (s *openAIStreamState) closeOpenBlock() {
+	if s.openIndex < 0 {
+		return
+	}
+	s.emit(map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": s.openIndex,
+	})
+	s.openIndex = -1
+	s.textIndex = -1
+	s.toolOpen = false
+}
+
+func (s *openAIStreamState) startBlock(contentBlock map[string]interface{}) int {
+	s.closeOpenBlock()
+	index := s.nextIndex
+	s.nextIndex++
+	s.openIndex = index
+	s.emit(map[string]interface{}{
+		"type":          "content_block_start",
+		"index":         index,
+		"content_block": contentBlock,
+	})
+	return index
+}
+
+func (s *openAIStreamState) handleText(text string) {
+	if s.textIndex < 0 {
+		s.flushPendingTools()
+		s.textIndex = s.startBlock(map[string]interface{}{
+			"type": "text",
+			"text": "",
+		})
+	}
+	s.emit(map[string]interface{}{
+		"type":  "content_block_delta",
+		"index": s.textIndex,
+		"delta": map[string]interface{}{
+			"type": "text_delta",
+			"text": text,
+		},
+	})
+}
+
+func (s *openAIStreamState) handleToolCall(toolCall map[string]interface{}) {
+	openAIIndex := 0
+	if idx, ok := toolCall["index"].(float64); ok {
+		openAIIndex = int(idx)
+	}
+	function, _ := toolCall["function"].(map[string]interface{})
+	args := ""
+	if function != nil {
+		args, _ = function["arguments"].(string)
+	}
+
+	if blockIndex, exists := s.toolIndexes[openAIIndex]; exists {
+		if blockIndex != s.openIndex {
+			// Only happens when text came in between, the block was closed to start it
+			if args != "" {
+				log.Printf("⚠️  Dropped %d bytes of arguments for OpenAI tool call %d, its tool_use block is already closed", len(args), openAIIndex)
+			}
+			return
+		}
+		s.emitToolArguments(blockIndex, args)
+		return
+	}
+	for _, pending := range s.pendingTools {
+		if pending.openAIIndex == openAIIndex {
+			pending.arguments.WriteString(args)
+			return
+		}
+	}
+
+	// First delta for this tool call carries the id and name
+	id, _ := toolCall["id"].(string)
+	name := ""
+	if function != nil {
+		name, _ = function["name"].(string)
+	}
+	if s.toolOpen || len(s.pendingTools) > 0 {
+		// Anthropic streams one block at a time, keep the open tool_use block open for its
+		// remaining arguments and send this one after it
+		pending := &pendingToolCall{openAIIndex: openAIIndex, id: id, name: name}
+		pending.arguments.WriteString(args)
+		s.pendingTools = append(s.pendingTools, pending)
+		return
+	}
+	blockIndex := s.startToolBlock(openAIIndex, id, name)
+	s.emitToolArguments(blockIndex, args)
+}
+
+func (s *openAIStreamState) startToolBlock(openAIIndex int, id, name string) int {
+	blockIndex := s.startBlock(map[string]interface{}{
+		"type":  "tool_use",
+		"id":    id,
+		"name":  name,
+		"input": map[string]interface{}{},
+	})
+	s.toolOpen = true
+	s.toolIndexes[openAIIndex] = blockIndex
+	return blockIndex
+}
+
+func (s *openAIStreamState) emitToolArguments(blockIndex int, args string) {
+	if args == "" {
+		return
+	}
+	s.emit(map[string]interface{}{
+		"type":  "content_block_delta",
+		"index": blockIndex,
+		"delta": map[string]interface{}{
+			"type":         "input_json_delta",
+			"partial_json": args,
+		},
+	})
+}
+
+// flushPendingTools sends the buffered tool calls as tool_use blocks, in the order they started
+func (s *openAIStreamState) flushPendingTools() {
+	for _, pending := range s.pendingTools {
+		blockIndex := s.startToolBlock(pending.openAIIndex, pending.id, pending.name)
+		s.emitToolArguments(blockIndex, pending.arguments.String())
+	}
+	s.pendingTools = nil
+}
+
+// finish closes any open block and sends the final message_delta and message_stop events
+func (s *openAIStreamState) finish() {
+	if !s.messageStarted {
+		return
+	}
+	s.flushPendingTools()
+	s.closeOpenBlock()
+
+	stopReason := s.stopReason
+	if stopReason == "" {
+		stopReason = "end_turn"
+	}
+	messageDelta := map[string]interface{}{
+		"type": "message_delta",
+		"delta": map[string]interface{}{
+			"stop_reason":   stopReason,
+			"stop_sequence": nil,
+		},
+	}
+	if len(s.usage) > 0 {
+		messageDelta["usage"] = s.usage
+	}
+	s.emit(messageDelta)
+	s.emit(map[string]interface{}{"type": "message_stop"})
+	s.messageStarted = false
+}
+
 func transformOpenAIStreamToAnthropic(openAIStream io.ReadCloser, anthropicStream io.Writer) {
 	defer openAIStream.Close()
 
 	scanner := bufio.NewScanner(openAIStream)
-	var messageStarted bool
-	var contentStarted bool
+	// Tool call argument chunks can be large, allow lines up to 10MB
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	state := newOpenAIStreamState(anthropicStream)
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -595,128 +1128,82 @@ func transformOpenAIStreamToAnthropic(openAIStream io.ReadCloser, anthropicStrea
 		}
 
 		// Handle SSE data lines
-		if strings.HasPrefix(line, "data: ") {
-			data := strings.TrimPrefix(line, "data: ")
-
-			// Handle end of stream
-			if data == "[DONE]" {
-				// Send Anthropic-style completion
-				if contentStarted {
-					fmt.Fprintf(anthropicStream, "data: {\"type\":\"content_block_stop\",\"index\":0}\n\n")
-				}
-				if messageStarted {
-					fmt.Fprintf(anthropicStream, "data: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\",\"stop_sequence\":null}}\n\n")
-					fmt.Fprintf(anthropicStream, "data: {\"type\":\"message_stop\"}\n\n")
-				}
-				break
-			}
-
-			// Parse OpenAI response
-			var openAIChunk map[string]interface{}
-			if err := json.Unmarshal([]byte(data), &openAIChunk); err != nil {
-				continue
-			}
-
-			// Check for usage data BEFORE processing choices
-			// According to OpenAI docs, usage is sent in the final chunk with empty choices array
-			if usage, hasUsage := openAIChunk["usage"].(map[string]interface{}); hasUsage {
-				// Convert OpenAI usage to Anthropic format
-				anthropicUsage := map[string]interface{}{}
-
-				// Handle both float64 and int types
-				if promptTokens, ok := usage["prompt_tokens"].(float64); ok {
-					anthropicUsage["input_tokens"] = int(promptTokens)
-				} else if promptTokens, ok := usage["prompt_tokens"].(int); ok {
-					anthropicUsage["input_tokens"] = promptTokens
-				}
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		data := strings.TrimPrefix(line, "data: ")
 
-				if completionTokens, ok := usage["completion_tokens"].(float64); ok {
-					anthropicUsage["output_tokens"] = int(completionTokens)
-				} else if completionTokens, ok := usage["completion_tokens"].(int); ok {
-					anthropicUsage["output_tokens"] = completionTokens
-				}
+		// Handle end of stream
+		if data == "[DONE]" {
+			break
+		}
 
-				if len(anthropicUsage) > 0 {
-					// Send usage data in a message_delta event
-					usageDelta := map[string]interface{}{
-						"type":  "message_delta",
-						"delta": map[string]interface{}{},
-						"usage": anthropicUsage,
-					}
-					usageJSON, _ := json.Marshal(usageDelta)
-					fmt.Fprintf(anthropicStream, "data: %s\n\n", usageJSON)
-				}
-			}
+		// Parse OpenAI response
+		var openAIChunk map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &openAIChunk); err != nil {
+			continue
+		}
 
-			// Extract choices array
-			choices, ok := openAIChunk["choices"].([]interface{})
-			if !ok || len(choices) == 0 {
-				// Skip further processing if no choices, but we already handled usage above
-				continue
+		// According to OpenAI docs, usage is sent in the final chunk with empty choices array.
+		// Hold on to it so it can be reported together with the stop_reason.
+		if usage, hasUsage := openAIChunk["usage"].(map[string]interface{}); hasUsage {
+			if anthropicUsage := convertOpenAIUsage(usage); len(anthropicUsage) > 0 {
+				state.usage = anthropicUsage
 			}
+		}
 
-			choice, ok := choices[0].(map[string]interface{})
-			if !ok {
-				continue
-			}
+		// Extract choices array
+		choices, ok := openAIChunk["choices"].([]interface{})
+		if !ok || len(choices) == 0 {
+			continue
+		}
 
-			delta, ok := choice["delta"].(map[string]interface{})
-			if !ok {
-				continue
-			}
+		choice, ok := choices[0].(map[string]interface{})
+		if !ok {
+			continue
+		}
 
-			// Handle first chunk - send message_start
-			if !messageStarted {
-				messageStarted = true
-				messageStart := map[string]interface{}{
-					"type": "message_start",
-					"message": map[string]interface{}{
-						"id":            openAIChunk["id"],
-						"type":          "message",
-						"role":          "assistant",
-						"model":         openAIChunk["model"],
-						"content":       []interface{}{},
-						"stop_reason":   nil,
-						"stop_sequence": nil,
-						"usage":         map[string]interface{}{
-							// Empty usage - will be updated in final chunk
-						},
+		// Handle first chunk - send message_start
+		if !state.messageStarted {
+			state.messageStarted = true
+			state.emit(map[string]interface{}{
+				"type": "message_start",
+				"message": map[string]interface{}{
+					"id":            openAIChunk["id"],
+					"type":          "message",
+					"role":          "assistant",
+					"model":         openAIChunk["model"],
+					"content":       []interface{}{},
+					"stop_reason":   nil,
+					"stop_sequence": nil,
+					"usage":         map[string]interface{}{
+						// Empty usage - will be updated in final chunk
 					},
-				}
-				startJSON, _ := json.Marshal(messageStart)
-				fmt.Fprintf(anthropicStream, "data: %s\n\n", startJSON)
-			}
+				},
+			})
+		}
 
-			// Handle content
+		if delta, ok := choice["delta"].(map[string]interface{}); ok {
+			// Handle text content
 			if content, hasContent := delta["content"].(string); hasContent && content != "" {
-				if !contentStarted {
-					contentStarted = true
-					// Send content_block_start
-					blockStart := map[string]interface{}{
-						"type":  "content_block_start",
-						"index": 0,
-						"content_block": map[string]interface{}{
-							"type": "text",
-							"text": "",
-						},
-					}
-					blockStartJSON, _ := json.Marshal(blockStart)
-					fmt.Fprintf(anthropicStream, "data: %s\n\n", blockStartJSON)
-				}
+				state.handleText(content)
+			}
 
-				// Send content_block_delta
-				contentDelta := map[string]interface{}{
-					"type":  "content_block_delta",
-					"index": 0,
-					"delta": map[string]interface{}{
-						"type": "text_delta",
-						"text": content,
-					},
+			// Handle tool calls, each OpenAI tool call index becomes its own tool_use block
+			if toolCalls, ok := delta["tool_calls"].([]interface{}); ok {
+				for _, tc := range toolCalls {
+					if toolCall, ok := tc.(map[string]interface{}); ok {
+						state.handleToolCall(toolCall)
+					}
 				}
-				deltaJSON, _ := json.Marshal(contentDelta)
-				fmt.Fprintf(anthropicStream, "data: %s\n\n", deltaJSON)
 			}
+		}
 
+		if finishReason, ok := choice["finish_reason"].(string); ok && finishReason != "" {
+			state.stopReason = mapOpenAIFinishReason(finishReason)
+			state.flushPendingTools()
 		}
 	}
+
+	state.finish()
 }
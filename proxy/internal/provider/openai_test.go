@@ -0,0 +1,588 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+)
+
+// parseAnthropicEvents decodes the data: lines written by transformOpenAIStreamToAnthropic
+func parseAnthropicEvents(t *testing.T, stream string) []map[string]interface{} {
+	t.Helper()
+
+	var events []map[string]interface{}
+	for _, line := range strings.Split(stream, "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			t.Fatalf("invalid event JSON %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func openAIStream(chunks ...string) io.ReadCloser {
+	var sb strings.Builder
+	for _, chunk := range chunks {
+		sb.WriteString("data: " + chunk + "\n\n")
+	}
+	sb.WriteString("data: [DONE]\n\n")
+	return io.NopCloser(strings.NewReader(sb.String()))
+}
+
+func TestTransformOpenAIStreamToAnthropic_ToolCalls(t *testing.T) {
+	stream := openAIStream(
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Let me check."}}]}`,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"Read","arguments":""}}]}}]}`,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"file_path\":"}}]}}]}`,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"/tmp/a\"}"}}]}}]}`,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"Bash","arguments":"{\"command\":\"ls\"}"}}]}}]}`,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":34}}`,
+	)
+
+	var out bytes.Buffer
+	transformOpenAIStreamToAnthropic(stream, &out)
+	events := parseAnthropicEvents(t, out.String())
+
+	var types []string
+	for _, e := range events {
+		types = append(types, e["type"].(string))
+	}
+	expectedTypes := []string{
+		"message_start",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"message_delta", "message_stop",
+	}
+	if strings.Join(types, ",") != strings.Join(expectedTypes, ",") {
+		t.Fatalf("event types = %v, want %v", types, expectedTypes)
+	}
+
+	firstTool := events[4]["content_block"].(map[string]interface{})
+	if events[4]["index"].(float64) != 1 || firstTool["type"] != "tool_use" || firstTool["id"] != "call_a" || firstTool["name"] != "Read" {
+		t.Errorf("unexpected first tool_use start: %v", events[4])
+	}
+
+	var args strings.Builder
+	for _, e := range events[5:7] {
+		delta := e["delta"].(map[string]interface{})
+		if delta["type"] != "input_json_delta" {
+			t.Errorf("delta type = %v, want input_json_delta", delta["type"])
+		}
+		args.WriteString(delta["partial_json"].(string))
+	}
+	if args.String() != `{"file_path":"/tmp/a"}` {
+		t.Errorf("accumulated arguments = %q", args.String())
+	}
+
+	secondTool := events[8]["content_block"].(map[string]interface{})
+	if events[8]["index"].(float64) != 2 || secondTool["id"] != "call_b" || secondTool["name"] != "Bash" {
+		t.Errorf("unexpected second tool_use start: %v", events[8])
+	}
+
+	messageDelta := events[11]
+	if stopReason := messageDelta["delta"].(map[string]interface{})["stop_reason"]; stopReason != "tool_use" {
+		t.Errorf("stop_reason = %v, want tool_use", stopReason)
+	}
+	usage := messageDelta["usage"].(map[string]interface{})
+	if usage["input_tokens"].(float64) != 12 || usage["output_tokens"].(float64) != 34 {
+		t.Errorf("unexpected usage: %v", usage)
+	}
+}
+
+func TestTransformOpenAIStreamToAnthropic_InterleavedToolCalls(t *testing.T) {
+	stream := openAIStream(
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"Read","arguments":"{\"file_path\":"}}]}}]}`,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"Bash","arguments":"{\"command\":"}}]}}]}`,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"/tmp/a\"}"}}]}}]}`,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":2,"id":"call_c","type":"function","function":{"name":"Grep","arguments":"{}"}}]}}]}`,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"\"ls\"}"}}]}}]}`,
+		`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	)
+
+	var out bytes.Buffer
+	transformOpenAIStreamToAnthropic(stream, &out)
+	events := parseAnthropicEvents(t, out.String())
+
+	// Every block's arguments must be complete, and blocks must not overlap
+	type toolBlock struct {
+		id, name string
+		args     strings.Builder
+	}
+	blocks := map[float64]*toolBlock{}
+	var order []string
+	open := -1.0
+	for _, e := range events {
+		switch e["type"] {
+		case "content_block_start":
+			if open >= 0 {
+				t.Fatalf("block %v started while block %v is open", e["index"], open)
+			}
+			open = e["index"].(float64)
+			cb := e["content_block"].(map[string]interface{})
+			blocks[open] = &toolBlock{id: cb["id"].(string), name: cb["name"].(string)}
+			order = append(order, cb["id"].(string))
+		case "content_block_delta":
+			if e["index"].(float64) != open {
+				t.Fatalf("delta for block %v while block %v is open", e["index"], open)
+			}
+			blocks[open].args.WriteString(e["delta"].(map[string]interface{})["partial_json"].(string))
+		case "content_block_stop":
+			open = -1
+		}
+	}
+
+	if strings.Join(order, ",") != "call_a,call_b,call_c" {
+		t.Errorf("tool_use blocks in order %v, want call_a,call_b,call_c", order)
+	}
+	want := map[string]string{
+		"call_a": `{"file_path":"/tmp/a"}`,
+		"call_b": `{"command":"ls"}`,
+		"call_c": `{}`,
+	}
+	for _, block := range blocks {
+		if block.args.String() != want[block.id] {
+			t.Errorf("%s (%s) arguments = %q, want %q", block.id, block.name, block.args.String(), want[block.id])
+		}
+	}
+}
+
+func TestMapOpenAIFinishReason(t *testing.T) {
+	tests := []struct {
+		finishReason string
+		expected     string
+	}{
+		{"stop", "end_turn"},
+		{"length", "max_tokens"},
+		{"tool_calls", "tool_use"},
+		{"function_call", "tool_use"},
+		{"content_filter", "refusal"},
+		{"", "end_turn"},
+	}
+
+	for _, tt := range tests {
+		if got := mapOpenAIFinishReason(tt.finishReason); got != tt.expected {
+			t.Errorf("mapOpenAIFinishReason(%q) = %q, want %q", tt.finishReason, got, tt.expected)
+		}
+	}
+}
+
+func decodeAnthropicRequest(t *testing.T, body string) *model.AnthropicRequest {
+	t.Helper()
+
+	var req model.AnthropicRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("invalid anthropic request: %v", err)
+	}
+	return &req
+}
+
+func TestConvertAnthropicToOpenAI_ToolHistory(t *testing.T) {
+	req := decodeAnthropicRequest(t, `{
+		"model": "gpt-4o",
+		"max_tokens": 1024,
+		"messages": [
+			{"role": "user", "content": "List the files and read main.go"},
+			{"role": "assistant", "content": [
+				{"type": "text", "text": "I'll look around."},
+				{"type": "tool_use", "id": "toolu_1", "name": "Bash", "input": {"command": "ls"}},
+				{"type": "tool_use", "id": "toolu_2", "name": "Read", "input": {"file_path": "main.go"}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": "main.go\ngo.mod"},
+				{"type": "tool_result", "tool_use_id": "toolu_2", "content": [{"type": "text", "text": "package main"}]},
+				{"type": "text", "text": "Keep it short."}
+			]},
+			{"role": "assistant", "content": [
+				{"type": "tool_use", "id": "toolu_3", "name": "Bash", "input": {"command": "false"}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_3", "content": "exit status 1", "is_error": true}
+			]}
+		]
+	}`)
+
+	openAIReq, err := convertAnthropicToOpenAI(req, nil)
+	if err != nil {
+		t.Fatalf("convertAnthropicToOpenAI() error: %v", err)
+	}
+	messages := openAIReq["messages"].([]map[string]interface{})
+
+	expectedRoles := []string{"user", "assistant", "tool", "tool", "user", "assistant", "tool"}
+	if len(messages) != len(expectedRoles) {
+		t.Fatalf("got %d messages, want %d: %v", len(messages), len(expectedRoles), messages)
+	}
+	for i, role := range expectedRoles {
+		if messages[i]["role"] != role {
+			t.Errorf("messages[%d].role = %v, want %s", i, messages[i]["role"], role)
+		}
+	}
+
+	assistant := messages[1]
+	if assistant["content"] != "I'll look around." {
+		t.Errorf("assistant content = %v", assistant["content"])
+	}
+	toolCalls := assistant["tool_calls"].([]map[string]interface{})
+	if len(toolCalls) != 2 {
+		t.Fatalf("got %d tool calls, want 2", len(toolCalls))
+	}
+	function := toolCalls[1]["function"].(map[string]interface{})
+	if toolCalls[1]["id"] != "toolu_2" || function["name"] != "Read" || function["arguments"] != `{"file_path":"main.go"}` {
+		t.Errorf("unexpected tool call: %v", toolCalls[1])
+	}
+
+	if messages[2]["tool_call_id"] != "toolu_1" || messages[2]["content"] != "main.go\ngo.mod" {
+		t.Errorf("unexpected first tool message: %v", messages[2])
+	}
+	if messages[3]["tool_call_id"] != "toolu_2" || messages[3]["content"] != "package main" {
+		t.Errorf("unexpected second tool message: %v", messages[3])
+	}
+	if messages[4]["content"] != "Keep it short." {
+		t.Errorf("trailing user text = %v", messages[4]["content"])
+	}
+
+	if messages[5]["content"] != nil {
+		t.Errorf("tool-only assistant content = %v, want nil", messages[5]["content"])
+	}
+	if messages[6]["content"] != "Error: exit status 1" {
+		t.Errorf("error tool result content = %v", messages[6]["content"])
+	}
+}
+
+func TestToolCallRoundTrip(t *testing.T) {
+	openAIResp := `{
+		"id": "chatcmpl-2",
+		"model": "gpt-4o",
+		"choices": [{
+			"index": 0,
+			"finish_reason": "tool_calls",
+			"message": {
+				"role": "assistant",
+				"content": "Checking.",
+				"tool_calls": [{"id": "call_9", "type": "function", "function": {"name": "Grep", "arguments": "{\"pattern\":\"TODO\",\"path\":\".\"}"}}]
+			}
+		}],
+		"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+	}`
+
+	// OpenAI response -> Anthropic response, as seen by Claude Code
+	var anthropicResp struct {
+		StopReason string        `json:"stop_reason"`
+		Content    []interface{} `json:"content"`
+	}
+	if err := json.Unmarshal(transformOpenAIResponseToAnthropic([]byte(openAIResp)), &anthropicResp); err != nil {
+		t.Fatalf("invalid anthropic response: %v", err)
+	}
+	if anthropicResp.StopReason != "tool_use" {
+		t.Errorf("stop_reason = %q, want tool_use", anthropicResp.StopReason)
+	}
+
+	// Claude Code sends the assistant turn back together with the tool result
+	req := &model.AnthropicRequest{
+		Model: "gpt-4o",
+		Messages: []model.AnthropicMessage{
+			{Role: "user", Content: "Find TODOs"},
+			{Role: "assistant", Content: anthropicResp.Content},
+			{Role: "user", Content: []interface{}{
+				map[string]interface{}{"type": "tool_result", "tool_use_id": "call_9", "content": "main.go:3: TODO"},
+			}},
+		},
+	}
+
+	openAIReq, err := convertAnthropicToOpenAI(req, nil)
+	if err != nil {
+		t.Fatalf("convertAnthropicToOpenAI() error: %v", err)
+	}
+	messages := openAIReq["messages"].([]map[string]interface{})
+	if len(messages) != 3 {
+		t.Fatalf("got %d messages, want 3: %v", len(messages), messages)
+	}
+
+	toolCalls := messages[1]["tool_calls"].([]map[string]interface{})
+	function := toolCalls[0]["function"].(map[string]interface{})
+	if toolCalls[0]["id"] != "call_9" || function["name"] != "Grep" {
+		t.Errorf("unexpected tool call after round trip: %v", toolCalls[0])
+	}
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(function["arguments"].(string)), &args); err != nil {
+		t.Fatalf("arguments are not valid JSON: %v", err)
+	}
+	if args["pattern"] != "TODO" || args["path"] != "." {
+		t.Errorf("arguments after round trip = %v", args)
+	}
+	if messages[1]["content"] != "Checking." {
+		t.Errorf("assistant content after round trip = %v", messages[1]["content"])
+	}
+
+	if messages[2]["role"] != "tool" || messages[2]["tool_call_id"] != "call_9" {
+		t.Errorf("unexpected tool message after round trip: %v", messages[2])
+	}
+}
+
+func TestConvertAnthropicToOpenAI_MediaBlocks(t *testing.T) {
+	req := decodeAnthropicRequest(t, `{
+		"model": "gpt-4o",
+		"max_tokens": 1024,
+		"messages": [
+			{"role": "user", "content": [
+				{"type": "text", "text": "What is in this screenshot?"},
+				{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo="}},
+				{"type": "image", "source": {"type": "url", "url": "https://example.com/cat.jpg"}},
+				{"type": "document", "title": "spec.pdf", "source": {"type": "base64", "media_type": "application/pdf", "data": "JVBERi0x"}},
+				{"type": "document", "title": "notes", "source": {"type": "text", "media_type": "text/plain", "data": "remember the milk"}}
+			]},
+			{"role": "assistant", "content": [
+				{"type": "tool_use", "id": "toolu_1", "name": "Screenshot", "input": {}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": [
+					{"type": "text", "text": "captured"},
+					{"type": "image", "source": {"type": "base64", "media_type": "image/jpeg", "data": "/9j/4AAQ"}}
+				]}
+			]}
+		]
+	}`)
+
+	openAIReq, err := convertAnthropicToOpenAI(req, nil)
+	if err != nil {
+		t.Fatalf("convertAnthropicToOpenAI() error: %v", err)
+	}
+	messages := openAIReq["messages"].([]map[string]interface{})
+	if len(messages) != 4 {
+		t.Fatalf("got %d messages, want 4: %v", len(messages), messages)
+	}
+
+	parts := messages[0]["content"].([]map[string]interface{})
+	expectedTypes := []string{"text", "image_url", "image_url", "file", "text"}
+	if len(parts) != len(expectedTypes) {
+		t.Fatalf("got %d parts, want %d: %v", len(parts), len(expectedTypes), parts)
+	}
+	for i, partType := range expectedTypes {
+		if parts[i]["type"] != partType {
+			t.Errorf("parts[%d].type = %v, want %s", i, parts[i]["type"], partType)
+		}
+	}
+	if url := parts[1]["image_url"].(map[string]interface{})["url"]; url != "data:image/png;base64,iVBORw0KGgo=" {
+		t.Errorf("base64 image url = %v", url)
+	}
+	if url := parts[2]["image_url"].(map[string]interface{})["url"]; url != "https://example.com/cat.jpg" {
+		t.Errorf("image url = %v", url)
+	}
+	file := parts[3]["file"].(map[string]interface{})
+	if file["filename"] != "spec.pdf" || file["file_data"] != "data:application/pdf;base64,JVBERi0x" {
+		t.Errorf("unexpected file part: %v", file)
+	}
+	if parts[4]["text"] != "notes\n\nremember the milk" {
+		t.Errorf("text document part = %v", parts[4]["text"])
+	}
+
+	if messages[2]["role"] != "tool" || messages[2]["content"] != "captured\n[image attached]" {
+		t.Errorf("unexpected tool message: %v", messages[2])
+	}
+	followUp := messages[3]["content"].([]map[string]interface{})
+	if messages[3]["role"] != "user" || len(followUp) != 2 || followUp[1]["type"] != "image_url" {
+		t.Errorf("unexpected tool result attachments: %v", messages[3])
+	}
+}
+
+func TestConvertAnthropicToOpenAI_UnsupportedDocument(t *testing.T) {
+	req := decodeAnthropicRequest(t, `{
+		"model": "gpt-4o",
+		"max_tokens": 1024,
+		"messages": [
+			{"role": "user", "content": [
+				{"type": "document", "source": {"type": "url", "url": "https://example.com/report.pdf"}}
+			]}
+		]
+	}`)
+
+	if _, err := convertAnthropicToOpenAI(req, nil); err == nil || !strings.Contains(err.Error(), `"url"`) {
+		t.Fatalf("expected unsupported source error, got %v", err)
+	}
+
+	resp := newAnthropicErrorResponse(nil, 400, "invalid_request_error", "bad document")
+	var body struct {
+		Type  string `json:"type"`
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("invalid error body: %v", err)
+	}
+	if body.Type != "error" || body.Error.Type != "invalid_request_error" || body.Error.Message != "bad document" {
+		t.Errorf("unexpected error body: %+v", body)
+	}
+}
+
+func TestConvertAnthropicToOpenAI_Thinking(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		expectedEffort  string
+		wantTemperature bool
+	}{
+		{
+			name:           "small budget on reasoning model",
+			body:           `{"model": "o3", "max_tokens": 8000, "thinking": {"type": "enabled", "budget_tokens": 2048}, "messages": [{"role": "user", "content": "hi"}]}`,
+			expectedEffort: "low",
+		},
+		{
+			name:           "medium budget on gpt-5",
+			body:           `{"model": "gpt-5", "max_tokens": 16000, "temperature": 1, "thinking": {"type": "enabled", "budget_tokens": 10000}, "messages": [{"role": "user", "content": "hi"}]}`,
+			expectedEffort: "medium",
+		},
+		{
+			name:           "large budget",
+			body:           `{"model": "o4-mini", "max_tokens": 16000, "thinking": {"type": "enabled", "budget_tokens": 31999}, "messages": [{"role": "user", "content": "hi"}]}`,
+			expectedEffort: "high",
+		},
+		{
+			name: "thinking disabled",
+			body: `{"model": "o3", "max_tokens": 1000, "thinking": {"type": "disabled"}, "messages": [{"role": "user", "content": "hi"}]}`,
+		},
+		{
+			name:            "non-reasoning model keeps temperature",
+			body:            `{"model": "gpt-4o", "max_tokens": 1000, "temperature": 0.2, "thinking": {"type": "enabled", "budget_tokens": 4096}, "messages": [{"role": "user", "content": "hi"}]}`,
+			wantTemperature: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openAIReq, err := convertAnthropicToOpenAI(decodeAnthropicRequest(t, tt.body), nil)
+			if err != nil {
+				t.Fatalf("convertAnthropicToOpenAI() error: %v", err)
+			}
+
+			effort, hasEffort := openAIReq["reasoning_effort"]
+			if tt.expectedEffort == "" && hasEffort {
+				t.Errorf("unexpected reasoning_effort %v", effort)
+			}
+			if tt.expectedEffort != "" && effort != tt.expectedEffort {
+				t.Errorf("reasoning_effort = %v, want %s", effort, tt.expectedEffort)
+			}
+			if _, hasTemperature := openAIReq["temperature"]; hasTemperature != tt.wantTemperature {
+				t.Errorf("temperature present = %v, want %v", hasTemperature, tt.wantTemperature)
+			}
+		})
+	}
+}
+
+func TestTransformOpenAIResponseToAnthropic_ReasoningUsage(t *testing.T) {
+	openAIResp := `{
+		"id": "chatcmpl-3",
+		"model": "o3",
+		"choices": [{"index": 0, "finish_reason": "length", "message": {"role": "assistant", "content": "partial"}}],
+		"usage": {"prompt_tokens": 100, "completion_tokens": 900, "total_tokens": 1000, "completion_tokens_details": {"reasoning_tokens": 640}}
+	}`
+
+	var anthropicResp struct {
+		StopReason string               `json:"stop_reason"`
+		Usage      model.AnthropicUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(transformOpenAIResponseToAnthropic([]byte(openAIResp)), &anthropicResp); err != nil {
+		t.Fatalf("invalid anthropic response: %v", err)
+	}
+
+	if anthropicResp.StopReason != "max_tokens" {
+		t.Errorf("stop_reason = %q, want max_tokens", anthropicResp.StopReason)
+	}
+	if anthropicResp.Usage.InputTokens != 100 || anthropicResp.Usage.OutputTokens != 900 || anthropicResp.Usage.ReasoningTokens != 640 {
+		t.Errorf("unexpected usage: %+v", anthropicResp.Usage)
+	}
+}
+
+func TestConvertOpenAIUsage(t *testing.T) {
+	tests := []struct {
+		name  string
+		usage string
+		want  map[string]interface{}
+	}{
+		{
+			name:  "plain",
+			usage: `{"prompt_tokens": 12, "completion_tokens": 34, "total_tokens": 46}`,
+			want:  map[string]interface{}{"input_tokens": 12, "output_tokens": 34},
+		},
+		{
+			name:  "cached prompt tokens",
+			usage: `{"prompt_tokens": 2000, "completion_tokens": 50, "prompt_tokens_details": {"cached_tokens": 1536, "audio_tokens": 0}}`,
+			want:  map[string]interface{}{"input_tokens": 464, "cache_read_input_tokens": 1536, "output_tokens": 50},
+		},
+		{
+			name:  "no cache hit",
+			usage: `{"prompt_tokens": 2000, "completion_tokens": 50, "prompt_tokens_details": {"cached_tokens": 0}}`,
+			want:  map[string]interface{}{"input_tokens": 2000, "output_tokens": 50},
+		},
+		{
+			name:  "reasoning tokens",
+			usage: `{"prompt_tokens": 100, "completion_tokens": 900, "completion_tokens_details": {"reasoning_tokens": 640}}`,
+			want:  map[string]interface{}{"input_tokens": 100, "output_tokens": 900, "reasoning_tokens": 640},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var usage map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.usage), &usage); err != nil {
+				t.Fatal(err)
+			}
+			if got := convertOpenAIUsage(usage); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertOpenAIUsage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertAnthropicToOpenAI_CatalogLimits(t *testing.T) {
+	catalog := config.DefaultModels()
+
+	tests := []struct {
+		model         string
+		maxTokens     int
+		wantMaxTokens int
+		wantReasoning bool
+	}{
+		{"gpt-4o", 32000, 16384, false},
+		{"gpt-4.1", 32000, 32000, false},
+		{"gpt-5", 200000, 128000, true},
+		{"o3-mini", 1000, 1000, true},
+		// Not in the catalog: no cap, reasoning detection by name
+		{"qwen2.5-coder", 50000, 50000, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			spec, _ := catalog.Lookup(tt.model)
+			req := &model.AnthropicRequest{
+				Model:     tt.model,
+				MaxTokens: tt.maxTokens,
+				Thinking:  &model.ThinkingConfig{Type: "enabled", BudgetTokens: 8000},
+				Messages:  []model.AnthropicMessage{{Role: "user", Content: "hi"}},
+			}
+
+			openAIReq, err := convertAnthropicToOpenAI(req, spec)
+			if err != nil {
+				t.Fatalf("convertAnthropicToOpenAI() error: %v", err)
+			}
+			if got := openAIReq["max_completion_tokens"]; got != tt.wantMaxTokens {
+				t.Errorf("max_completion_tokens = %v, want %d", got, tt.wantMaxTokens)
+			}
+			if _, reasoning := openAIReq["reasoning_effort"]; reasoning != tt.wantReasoning {
+				t.Errorf("reasoning_effort present = %v, want %v", reasoning, tt.wantReasoning)
+			}
+		})
+	}
+}
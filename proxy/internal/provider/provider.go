@@ -2,7 +2,14 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
 )
 
 // Provider is the interface that all LLM providers must implement
@@ -13,3 +20,68 @@ type Provider interface {
 	// ForwardRequest forwards a request to the provider's API
 	ForwardRequest(ctx context.Context, req *http.Request) (*http.Response, error)
 }
+
+// NewProviders builds the built-in anthropic and openai providers plus every named provider from the config
+func NewProviders(cfg *config.ProvidersConfig, models config.ModelCatalog) (map[string]Provider, error) {
+	providers := map[string]Provider{
+		"anthropic": NewAnthropicProvider(&cfg.Anthropic),
+		"openai":    NewOpenAIProvider(&cfg.OpenAI, models),
+	}
+
+	for name, pc := range cfg.Named {
+		p, err := newNamedProvider(name, pc, models)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", name, err)
+		}
+		providers[name] = p
+	}
+
+	return providers, nil
+}
+
+func newNamedProvider(name string, pc config.ProviderConfig, models config.ModelCatalog) (Provider, error) {
+	if strings.Contains(name, "/") {
+		return nil, fmt.Errorf("name must not contain '/', it separates the provider from the model")
+	}
+
+	baseURL, err := url.Parse(pc.BaseURL)
+	if err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+		return nil, fmt.Errorf("invalid base_url %q", pc.BaseURL)
+	}
+
+	var apiKey string
+	if pc.APIKeyEnv != "" {
+		apiKey = os.Getenv(pc.APIKeyEnv)
+		if apiKey == "" {
+			log.Printf("⚠️  Provider %s: environment variable %s is not set", name, pc.APIKeyEnv)
+		}
+	} else if pc.Type == "anthropic" {
+		log.Printf("⚠️  Provider %s has no api_key_env, its requests are sent without credentials", name)
+	}
+
+	switch pc.Type {
+	case "anthropic":
+		version := pc.Version
+		if version == "" {
+			version = "2023-06-01"
+		}
+		return NewAnthropicProvider(&config.AnthropicProviderConfig{
+			Name:       name,
+			BaseURL:    pc.BaseURL,
+			Version:    version,
+			MaxRetries: pc.MaxRetries,
+			APIKey:     apiKey,
+			Headers:    pc.Headers,
+		}), nil
+	case "openai":
+		return NewOpenAIProvider(&config.OpenAIProviderConfig{
+			Name:       name,
+			BaseURL:    pc.BaseURL,
+			APIKey:     apiKey,
+			MaxRetries: pc.MaxRetries,
+			Headers:    pc.Headers,
+		}, models), nil
+	default:
+		return nil, fmt.Errorf("unknown type %q, expected anthropic or openai", pc.Type)
+	}
+}
@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+)
+
+func TestNewProviders(t *testing.T) {
+	t.Setenv("GATEWAY_KEY", "gw-secret")
+
+	var gotHeader http.Header
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Clone()
+		gotPath = r.URL.Path
+		w.Write([]byte(`{"id":"msg_1"}`))
+	}))
+	defer server.Close()
+
+	providers, err := NewProviders(&config.ProvidersConfig{
+		Named: map[string]config.ProviderConfig{
+			"gateway": {
+				Type:      "anthropic",
+				BaseURL:   server.URL + "/anthropic",
+				APIKeyEnv: "GATEWAY_KEY",
+				Headers:   map[string]string{"X-Team": "platform"},
+			},
+			"local-llama": {Type: "openai", BaseURL: "http://localhost:8080/v1"},
+		},
+	}, config.DefaultModels())
+	if err != nil {
+		t.Fatalf("NewProviders returned error: %v", err)
+	}
+
+	for name, wantName := range map[string]string{"anthropic": "anthropic", "openai": "openai", "gateway": "gateway", "local-llama": "local-llama"} {
+		if p, ok := providers[name]; !ok || p.Name() != wantName {
+			t.Errorf("provider %s missing or misnamed", name)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer client-oauth-token")
+	resp, err := providers["gateway"].ForwardRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ForwardRequest returned error: %v", err)
+	}
+	resp.Body.Close()
+
+	if gotPath != "/anthropic/v1/messages" {
+		t.Errorf("path = %s, want /anthropic/v1/messages", gotPath)
+	}
+	if gotHeader.Get("x-api-key") != "gw-secret" || gotHeader.Get("Authorization") != "" || gotHeader.Get("X-Team") != "platform" {
+		t.Errorf("unexpected upstream headers: %v", gotHeader)
+	}
+}
+
+func TestNamedAnthropicProvider_DropsClientCredentials(t *testing.T) {
+	var gotHeader http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Clone()
+		w.Write([]byte(`{"id":"msg_1"}`))
+	}))
+	defer server.Close()
+
+	providers, err := NewProviders(&config.ProvidersConfig{
+		Named: map[string]config.ProviderConfig{"third-party": {Type: "anthropic", BaseURL: server.URL}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewProviders returned error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{}`))
+	req.Header.Set("x-api-key", "sk-ant-client")
+	req.Header.Set("Authorization", "Bearer client-oauth-token")
+	resp, err := providers["third-party"].ForwardRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ForwardRequest returned error: %v", err)
+	}
+	resp.Body.Close()
+
+	if gotHeader.Get("x-api-key") != "" || gotHeader.Get("Authorization") != "" {
+		t.Errorf("client credentials reached a named provider: %v", gotHeader)
+	}
+}
+
+func TestNewProviders_Invalid(t *testing.T) {
+	tests := map[string]config.ProviderConfig{
+		"unknown type":     {Type: "gemini", BaseURL: "http://localhost:1234"},
+		"missing base url": {Type: "openai"},
+		"bad/name":         {Type: "openai", BaseURL: "http://localhost:1234"},
+	}
+
+	for name, pc := range tests {
+		if _, err := NewProviders(&config.ProvidersConfig{Named: map[string]config.ProviderConfig{name: pc}}, nil); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestChatCompletionsPath(t *testing.T) {
+	tests := map[string]string{
+		"":            "/v1/chat/completions",
+		"/":           "/v1/chat/completions",
+		"/v1":         "/v1/chat/completions",
+		"/v1/":        "/v1/chat/completions",
+		"/openai":     "/openai/v1/chat/completions",
+		"/gateway/v1": "/gateway/v1/chat/completions",
+	}
+
+	for basePath, want := range tests {
+		if got := chatCompletionsPath(basePath); got != want {
+			t.Errorf("chatCompletionsPath(%q) = %q, want %q", basePath, got, want)
+		}
+	}
+}
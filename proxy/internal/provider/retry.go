@@ -0,0 +1,176 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+)
+
+const (
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay  = 30 * time.Second
+
+	// A retry-after longer than this is left to the client instead of holding the connection open
+	maxRetryAfter = 60 * time.Second
+)
+
+// AttemptObserver is called after every upstream attempt a provider makes for a request
+type AttemptObserver func(attempt model.RequestAttempt)
+
+type attemptObserverKey struct{}
+
+// WithAttemptObserver returns a context that reports upstream attempts to observer
+func WithAttemptObserver(ctx context.Context, observer AttemptObserver) context.Context {
+	return context.WithValue(ctx, attemptObserverKey{}, observer)
+}
+
+func notifyAttempt(ctx context.Context, attempt model.RequestAttempt) {
+	if observer, ok := ctx.Value(attemptObserverKey{}).(AttemptObserver); ok && observer != nil {
+		observer(attempt)
+	}
+}
+
+type shadowTrafficKey struct{}
+
+// WithShadowTraffic marks a request as a shadow copy that must not affect primary traffic: it
+// isn't retried, circuit breakers don't count it and skip it while their circuit isn't closed
+func WithShadowTraffic(ctx context.Context) context.Context {
+	return context.WithValue(ctx, shadowTrafficKey{}, true)
+}
+
+func isShadowTraffic(ctx context.Context) bool {
+	shadow, _ := ctx.Value(shadowTrafficKey{}).(bool)
+	return shadow
+}
+
+// retryPolicy retries an upstream call with jittered exponential backoff. Retries only happen
+// before the response is returned, so nothing has been sent to the client yet.
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+func newRetryPolicy(maxRetries int) retryPolicy {
+	return retryPolicy{
+		maxRetries: maxRetries,
+		baseDelay:  defaultRetryBaseDelay,
+		maxDelay:   defaultRetryMaxDelay,
+	}
+}
+
+// IsRetryableStatus reports whether an upstream status means "try again later"
+func IsRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable, 529: // 529 is Anthropic's overloaded_error
+		return true
+	default:
+		return false
+	}
+}
+
+// do calls send until it succeeds, fails with a non-retryable status or the retries are used up.
+// send must build a fresh request each time since the previous body has been consumed.
+func (p retryPolicy) do(ctx context.Context, providerName string, send func() (*http.Response, error)) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		startedAt := time.Now()
+		resp, err := send()
+
+		attempt := model.RequestAttempt{
+			Provider:   providerName,
+			DurationMs: time.Since(startedAt).Milliseconds(),
+			StartedAt:  startedAt.Format(time.RFC3339Nano),
+		}
+		if err != nil {
+			attempt.Error = err.Error()
+		} else {
+			attempt.StatusCode = resp.StatusCode
+		}
+
+		var delay time.Duration
+		retryable := retry < p.maxRetries && ctx.Err() == nil && !isShadowTraffic(ctx)
+		if retryable {
+			switch {
+			case err != nil:
+				delay = p.backoff(retry)
+			case IsRetryableStatus(resp.StatusCode):
+				var ok bool
+				delay, ok = retryAfter(resp.Header)
+				if !ok {
+					delay = p.backoff(retry)
+				}
+				retryable = delay <= maxRetryAfter
+			default:
+				retryable = false
+			}
+		}
+
+		if !retryable {
+			notifyAttempt(ctx, attempt)
+			return resp, err
+		}
+
+		attempt.BackoffMs = delay.Milliseconds()
+		notifyAttempt(ctx, attempt)
+
+		if resp != nil {
+			// Drain so the connection can be reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			log.Printf("🔁 [RETRY] provider=%s attempt=%d/%d status=%d delay_ms=%d",
+				providerName, retry+1, p.maxRetries, resp.StatusCode, delay.Milliseconds())
+		} else {
+			log.Printf("🔁 [RETRY] provider=%s attempt=%d/%d error=%q delay_ms=%d",
+				providerName, retry+1, p.maxRetries, err, delay.Milliseconds())
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay before the given retry: exponential growth with the upper half jittered
+func (p retryPolicy) backoff(retry int) time.Duration {
+	delay := p.baseDelay << retry
+	if delay <= 0 || delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+// retryAfter reads the delay requested by the upstream, in retry-after-ms or retry-after
+// (seconds or an HTTP date)
+func retryAfter(header http.Header) (time.Duration, bool) {
+	if ms := header.Get("retry-after-ms"); ms != "" {
+		if v, err := strconv.ParseFloat(ms, 64); err == nil && v >= 0 {
+			return time.Duration(v * float64(time.Millisecond)), true
+		}
+	}
+
+	value := header.Get("retry-after")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
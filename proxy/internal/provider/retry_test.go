@@ -0,0 +1,181 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+)
+
+func TestAnthropicProvider_RetriesOverloaded(t *testing.T) {
+	const body = `{"model":"claude-sonnet-4-5","messages":[]}`
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		got, _ := io.ReadAll(r.Body)
+		if string(got) != body {
+			t.Errorf("attempt %d got body %q, want the original body replayed", calls, got)
+		}
+		if calls < 3 {
+			w.Header().Set("retry-after", "0")
+			w.WriteHeader(529)
+			w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+			return
+		}
+		w.Write([]byte(`{"id":"msg_1"}`))
+	}))
+	defer server.Close()
+
+	p := NewAnthropicProvider(&config.AnthropicProviderConfig{BaseURL: server.URL, Version: "2023-06-01", MaxRetries: 3})
+
+	var attempts []model.RequestAttempt
+	ctx := WithAttemptObserver(context.Background(), func(a model.RequestAttempt) {
+		attempts = append(attempts, a)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	resp, err := p.ForwardRequest(ctx, req)
+	if err != nil {
+		t.Fatalf("ForwardRequest returned error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || calls != 3 {
+		t.Fatalf("status = %d after %d calls, want 200 after 3", resp.StatusCode, calls)
+	}
+	if len(attempts) != 3 {
+		t.Fatalf("observed %d attempts, want 3", len(attempts))
+	}
+	for i, want := range []int{529, 529, 200} {
+		if attempts[i].StatusCode != want || attempts[i].Provider != "anthropic" {
+			t.Errorf("attempt %d = %+v, want status %d", i, attempts[i], want)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxRetries   int
+		wantStatus   int
+		wantAttempts int
+	}{
+		{"success", []int{200}, 3, 200, 1},
+		{"not retryable", []int{400, 200}, 3, 400, 1},
+		{"rate limited then ok", []int{429, 200}, 3, 200, 2},
+		{"retries exhausted", []int{529, 529, 529}, 2, 529, 3},
+		{"retries disabled", []int{503, 200}, 0, 503, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := retryPolicy{maxRetries: tt.maxRetries, baseDelay: time.Millisecond, maxDelay: time.Millisecond}
+
+			attempts := 0
+			ctx := WithAttemptObserver(context.Background(), func(model.RequestAttempt) { attempts++ })
+
+			call := 0
+			resp, err := policy.do(ctx, "test", func() (*http.Response, error) {
+				status := tt.statuses[call]
+				call++
+				return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+			if err != nil {
+				t.Fatalf("do returned error: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus || attempts != tt.wantAttempts {
+				t.Errorf("got status %d after %d attempts, want %d after %d", resp.StatusCode, attempts, tt.wantStatus, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_TransportErrors(t *testing.T) {
+	policy := retryPolicy{maxRetries: 2, baseDelay: time.Millisecond, maxDelay: time.Millisecond}
+
+	var attempts []model.RequestAttempt
+	ctx := WithAttemptObserver(context.Background(), func(a model.RequestAttempt) { attempts = append(attempts, a) })
+
+	_, err := policy.do(ctx, "test", func() (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	if err == nil {
+		t.Fatal("expected the last transport error to be returned")
+	}
+	if len(attempts) != 3 {
+		t.Fatalf("observed %d attempts, want 3", len(attempts))
+	}
+	for _, a := range attempts {
+		if a.Error != "connection refused" || a.StatusCode != 0 {
+			t.Errorf("unexpected attempt: %+v", a)
+		}
+	}
+	if attempts[2].BackoffMs != 0 {
+		t.Errorf("the final attempt should not report a backoff: %+v", attempts[2])
+	}
+}
+
+func TestRetryPolicy_LongRetryAfterIsNotRetried(t *testing.T) {
+	policy := retryPolicy{maxRetries: 3, baseDelay: time.Millisecond, maxDelay: time.Millisecond}
+
+	calls := 0
+	resp, err := policy.do(context.Background(), "test", func() (*http.Response, error) {
+		calls++
+		header := http.Header{}
+		header.Set("retry-after", "3600")
+		return &http.Response{StatusCode: 429, Header: header, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+	if err != nil || resp.StatusCode != 429 || calls != 1 {
+		t.Errorf("got status %v err %v after %d calls, want the 429 returned immediately", resp.StatusCode, err, calls)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"none", nil, 0, false},
+		{"seconds", map[string]string{"retry-after": "2"}, 2 * time.Second, true},
+		{"milliseconds win", map[string]string{"retry-after": "2", "retry-after-ms": "150"}, 150 * time.Millisecond, true},
+		{"past date", map[string]string{"retry-after": "Mon, 02 Jan 2006 15:04:05 GMT"}, 0, true},
+		{"garbage", map[string]string{"retry-after": "soon"}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tt.header {
+				header.Set(k, v)
+			}
+			got, ok := retryAfter(header)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("retryAfter() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := retryPolicy{baseDelay: 100 * time.Millisecond, maxDelay: time.Second}
+
+	for retry, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			if d := policy.backoff(retry); d < max/2 || d > max {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v]", retry, d, max/2, max)
+			}
+		}
+	}
+}
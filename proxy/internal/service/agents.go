@@ -0,0 +1,266 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+)
+
+// Values of an agent's model: field that Claude Code resolves itself before sending the request
+var claudeCodeAgentModels = map[string]bool{"inherit": true, "sonnet": true, "opus": true, "haiku": true}
+
+// Claude Code's built-in subagents have no agent file, they are recognized by the start of
+// their prompt, which stays the same across versions while the rest changes
+var builtinAgentPrompts = []struct {
+	name   string
+	prefix string
+}{
+	{"general-purpose", "You are an agent for Claude Code"},
+	{"Explore", "You are a file search specialist for Claude Code"},
+	{"Plan", "You are a software architect and planning specialist for Claude Code"},
+	{"statusline-setup", "You are a status line setup agent for Claude Code"},
+	{"output-style-setup", "You are an output style setup agent for Claude Code"},
+}
+
+// The main agent's prompt starts with this, whatever the output style
+const mainAgentPromptPrefix = "You are an interactive"
+
+// Claude Code puts the session's working directory in the environment section of its system prompt
+var workingDirPattern = regexp.MustCompile(`(?m)^\s*Working directory:\s*(\S.*?)\s*$`)
+
+// agentFile is a parsed Claude Code agent definition (.claude/agents/<name>.md)
+type agentFile struct {
+	Path        string
+	Name        string
+	Description string
+	Model       string
+	Tools       []string
+	Prompt      string
+}
+
+type agentFrontmatter struct {
+	Name        string     `yaml:"name"`
+	Description string     `yaml:"description"`
+	Model       string     `yaml:"model"`
+	Tools       agentTools `yaml:"tools"`
+}
+
+// agentTools accepts both forms Claude Code writes: "Read, Grep, Glob" and a YAML list
+type agentTools []string
+
+func (t *agentTools) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		*t = nil
+		for _, tool := range strings.Split(value.Value, ",") {
+			if tool = strings.TrimSpace(tool); tool != "" {
+				*t = append(*t, tool)
+			}
+		}
+		return nil
+	case yaml.SequenceNode:
+		var tools []string
+		if err := value.Decode(&tools); err != nil {
+			return err
+		}
+		*t = tools
+		return nil
+	default:
+		return fmt.Errorf("tools must be a comma-separated string or a list")
+	}
+}
+
+// parseAgentFile reads an agent definition: YAML frontmatter between --- lines, then the system
+// prompt. The name defaults to the file name.
+func parseAgentFile(path string) (*agentFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+
+	rest, ok := strings.CutPrefix(text, "---\n")
+	if !ok {
+		return nil, fmt.Errorf("%s: missing frontmatter", path)
+	}
+	frontmatter, prompt, ok := strings.Cut(rest, "\n---\n")
+	if !ok {
+		// Frontmatter closed at the very end of the file, the agent has no prompt
+		frontmatter, ok = strings.CutSuffix(strings.TrimRight(rest, "\n"), "\n---")
+		if !ok {
+			return nil, fmt.Errorf("%s: unterminated frontmatter", path)
+		}
+		prompt = ""
+	}
+
+	var fm agentFrontmatter
+	if err := yaml.Unmarshal([]byte(frontmatter), &fm); err != nil {
+		return nil, fmt.Errorf("%s: invalid frontmatter: %w", path, err)
+	}
+
+	agent := &agentFile{
+		Path:        path,
+		Name:        fm.Name,
+		Description: fm.Description,
+		Model:       strings.TrimSpace(fm.Model),
+		Tools:       fm.Tools,
+		Prompt:      strings.TrimSpace(prompt),
+	}
+	if agent.Name == "" {
+		agent.Name = strings.TrimSuffix(filepath.Base(path), ".md")
+	}
+	return agent, nil
+}
+
+// agentSearchDirs lists the directories whose .claude/agents are loaded at startup: the proxy's
+// working directory, the user's home and every project Claude Code has been used in
+func agentSearchDirs() []string {
+	dirs := []string{"."}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, home)
+		dirs = append(dirs, claudeProjectDirs(claudeProjectsDir())...)
+	}
+	return dirs
+}
+
+// claudeProjectsDir returns ~/.claude/projects, "" when there is no home directory
+func claudeProjectsDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".claude", "projects")
+}
+
+// claudeProjectDirs returns the working directories of the projects under ~/.claude/projects.
+// The directory names are lossy encodings of the paths, so the path is read from the cwd field
+// of the session logs instead.
+func claudeProjectDirs(projectsDir string) []string {
+	entries, err := os.ReadDir(projectsDir)
+	if err != nil {
+		return nil
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		sessions, _ := filepath.Glob(filepath.Join(projectsDir, entry.Name(), "*.jsonl"))
+		for _, session := range sessions {
+			if cwd := sessionWorkingDir(session); cwd != "" {
+				dirs = append(dirs, cwd)
+				break
+			}
+		}
+	}
+	return dirs
+}
+
+// sessionWorkingDir returns the first cwd recorded in a Claude Code session log
+func sessionWorkingDir(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lines := 0; scanner.Scan() && lines < 50; lines++ {
+		var entry struct {
+			Cwd string `json:"cwd"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.Cwd != "" {
+			return entry.Cwd
+		}
+	}
+	return ""
+}
+
+// Claude Code names a project's directory under ~/.claude/projects after its path, with every
+// character other than a letter or digit replaced by "-"
+var claudeProjectNameChars = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// claudeProjectRoot returns the Claude Code project dir is in: dir itself or its closest parent
+// that has session logs under projectsDir. It returns "" for directories outside every project.
+// The directory names are lossy, so a candidate only counts when its sessions were recorded in it.
+func claudeProjectRoot(projectsDir, dir string) string {
+	if projectsDir == "" || !filepath.IsAbs(dir) {
+		return ""
+	}
+	dir = filepath.Clean(dir)
+	for {
+		sessions, _ := filepath.Glob(filepath.Join(projectsDir, claudeProjectNameChars.ReplaceAllString(dir, "-"), "*.jsonl"))
+		for _, session := range sessions {
+			if cwd := sessionWorkingDir(session); cwd != "" {
+				if filepath.Clean(cwd) == dir {
+					return dir
+				}
+				break
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// workingDirFromPrompt extracts the working directory Claude Code announces in its system prompt
+func workingDirFromPrompt(system []model.AnthropicSystemMessage) string {
+	for _, sys := range system {
+		if match := workingDirPattern.FindStringSubmatch(sys.Text); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// agentParameters turns a subagent mapping's overrides into the top-level request parameters
+// they set, nil when there are none
+func agentParameters(overrides config.AgentOverrides) map[string]interface{} {
+	params := map[string]interface{}{}
+	if overrides.Temperature != nil {
+		params["temperature"] = *overrides.Temperature
+	}
+	if overrides.MaxTokens != nil {
+		params["max_tokens"] = *overrides.MaxTokens
+	}
+	if overrides.ThinkingBudget != nil {
+		if budget := *overrides.ThinkingBudget; budget > 0 {
+			params["thinking"] = map[string]interface{}{"type": "enabled", "budget_tokens": budget}
+		} else {
+			params["thinking"] = map[string]interface{}{"type": "disabled"}
+		}
+	}
+	if len(params) == 0 {
+		return nil
+	}
+	return params
+}
+
+// builtinAgent names the agent a prompt without an agent file belongs to: the main agent, a
+// built-in subagent or model.AgentUnknown
+func builtinAgent(prompt string) string {
+	if strings.HasPrefix(prompt, mainAgentPromptPrefix) {
+		return model.AgentMain
+	}
+	for _, builtin := range builtinAgentPrompts {
+		if strings.HasPrefix(prompt, builtin.prefix) {
+			return builtin.name
+		}
+	}
+	return model.AgentUnknown
+}
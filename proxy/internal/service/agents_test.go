@@ -0,0 +1,275 @@
+package service
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+	"github.com/seifghazi/claude-code-monitor/internal/provider"
+)
+
+func writeAgentFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	agentsDir := filepath.Join(dir, ".claude", "agents")
+	if err := os.MkdirAll(agentsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(agentsDir, name+".md")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeSessionLog records a Claude Code session in dir under home/.claude/projects, the way
+// Claude Code names the project directory
+func writeSessionLog(t *testing.T, home, dir string) {
+	t.Helper()
+	sessions := filepath.Join(home, ".claude", "projects", claudeProjectNameChars.ReplaceAllString(dir, "-"))
+	if err := os.MkdirAll(sessions, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	session := `{"type":"summary"}` + "\n" + `{"type":"user","cwd":"` + dir + `"}` + "\n"
+	if err := os.WriteFile(filepath.Join(sessions, "session.jsonl"), []byte(session), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseAgentFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		want    agentFile
+		wantErr bool
+	}{
+		{
+			name:    "comma separated tools",
+			content: "---\nname: code-reviewer\ndescription: Reviews code\ntools: Read, Grep, Glob\nmodel: gpt-4o\n---\nYou review code.\n\n---\n\nKeep it short.\n",
+			want:    agentFile{Name: "code-reviewer", Description: "Reviews code", Model: "gpt-4o", Tools: []string{"Read", "Grep", "Glob"}, Prompt: "You review code.\n\n---\n\nKeep it short."},
+		},
+		{
+			name:    "tool list and name from file",
+			content: "---\ntools:\n  - Bash\n  - Read\n---\r\nYou run commands.\r\n",
+			want:    agentFile{Name: "tool list and name from file", Tools: []string{"Bash", "Read"}, Prompt: "You run commands."},
+		},
+		{
+			name:    "no prompt",
+			content: "---\nname: empty\n---\n",
+			want:    agentFile{Name: "empty"},
+		},
+		{name: "no frontmatter", content: "You are an agent.\n", wantErr: true},
+		{name: "unterminated frontmatter", content: "---\nname: broken\nYou are an agent.\n", wantErr: true},
+		{name: "invalid yaml", content: "---\nname: [broken\n---\nYou are an agent.\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeAgentFile(t, dir, tt.name, tt.content)
+			got, err := parseAgentFile(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseAgentFile returned error: %v", err)
+			}
+			tt.want.Path = path
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModelRouter_ProjectAgents(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	// A project Claude Code was used in, found through its session log
+	known := t.TempDir()
+	writeAgentFile(t, known, "reviewer", "---\nname: reviewer\nmodel: gpt-4o\n---\nYou review code.\n")
+	sessions := filepath.Join(home, ".claude", "projects", "-tmp-known")
+	if err := os.MkdirAll(sessions, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	session := `{"type":"summary"}` + "\n" + `{"type":"user","cwd":"` + known + `"}` + "\n"
+	if err := os.WriteFile(filepath.Join(sessions, "session.jsonl"), []byte(session), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A project started after the proxy, found from the working directory in the system prompt
+	other := t.TempDir()
+	writeAgentFile(t, other, "writer", "---\nname: writer\nmodel: sonnet\n---\nYou write docs.\n")
+	writeAgentFile(t, other, "tester", "---\nname: tester\nmodel: inherit\n---\nYou write tests.\n")
+	writeAgentFile(t, other, "linter", "---\nname: linter\n---\nYou lint code.\n")
+
+	// A subdirectory of a project has the project's agents, a directory outside every project
+	// is never read
+	nested := filepath.Join(t.TempDir(), "service")
+	writeAgentFile(t, nested, "deployer", "---\nname: deployer\nmodel: gpt-4o\n---\nYou deploy.\n")
+	outside := t.TempDir()
+	writeAgentFile(t, outside, "intruder", "---\nname: intruder\nmodel: gpt-4o\n---\nYou intrude.\n")
+
+	maxTokens := 4096
+	cfg := &config.Config{
+		Subagents: config.SubagentsConfig{
+			Enable: true,
+			Mappings: map[string]config.SubagentMapping{
+				"writer": {Model: "claude-haiku-4-5"},
+				"linter": {Overrides: config.AgentOverrides{MaxTokens: &maxTokens, SystemAppend: "Only report errors."}},
+			},
+		},
+	}
+	providers := map[string]provider.Provider{
+		"anthropic": &stubProvider{name: "anthropic"},
+		"openai":    &stubProvider{name: "openai"},
+	}
+	router := NewModelRouter(cfg, providers, log.New(os.Stdout, "test: ", log.LstdFlags))
+	writeSessionLog(t, home, other)
+	writeSessionLog(t, home, filepath.Dir(nested))
+
+	request := func(prompt, dir string) *model.AnthropicRequest {
+		return &model.AnthropicRequest{
+			Model: "claude-sonnet-4-5-20250929",
+			System: []model.AnthropicSystemMessage{
+				{Text: "You are Claude Code, Anthropic's official CLI for Claude."},
+				{Text: prompt + "\n\nNotes:\n- Be concise.\n\n<env>\nWorking directory: " + dir + "\n</env>"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		request      *model.AnthropicRequest
+		wantAgent    string
+		wantModel    string
+		wantReason   string
+		wantProvider string
+	}{
+		{"model from frontmatter", request("You review code.", "/elsewhere"), "reviewer", "gpt-4o", model.RoutingReasonSubagent, "openai"},
+		{"config mapping wins", request("You write docs.", other), "writer", "claude-haiku-4-5-20251001", model.RoutingReasonSubagent, "anthropic"},
+		{"no mapping", request("You write tests.", other), "tester", "claude-sonnet-4-5-20250929", model.RoutingReasonDefault, "anthropic"},
+		{"overrides only", request("You lint code.", other), "linter", "claude-sonnet-4-5-20250929", model.RoutingReasonSubagent, "anthropic"},
+		{"unknown agent", request("You do something else.", other), model.AgentUnknown, "claude-sonnet-4-5-20250929", model.RoutingReasonDefault, "anthropic"},
+		{"project subdirectory", request("You deploy.", nested), "deployer", "gpt-4o", model.RoutingReasonSubagent, "openai"},
+		{"outside every project", request("You intrude.", outside), model.AgentUnknown, "claude-sonnet-4-5-20250929", model.RoutingReasonDefault, "anthropic"},
+		{"relative directory", request("You intrude.", "."), model.AgentUnknown, "claude-sonnet-4-5-20250929", model.RoutingReasonDefault, "anthropic"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := router.DetermineRoute(tt.request, nil)
+			if err != nil {
+				t.Fatalf("DetermineRoute returned error: %v", err)
+			}
+			if decision.Agent != tt.wantAgent || decision.TargetModel != tt.wantModel ||
+				decision.Reason != tt.wantReason || decision.Provider.Name() != tt.wantProvider {
+				t.Errorf("got agent=%q model=%q reason=%q provider=%q, want %q %q %q %q",
+					decision.Agent, decision.TargetModel, decision.Reason, decision.Provider.Name(),
+					tt.wantAgent, tt.wantModel, tt.wantReason, tt.wantProvider)
+			}
+		})
+	}
+
+	decision, err := router.DetermineRoute(request("You lint code.", other), nil)
+	if err != nil {
+		t.Fatalf("DetermineRoute returned error: %v", err)
+	}
+	if decision.Overrides["max_tokens"] != 4096 || decision.SystemAppend != "Only report errors." {
+		t.Errorf("linter overrides = %v, system append = %q", decision.Overrides, decision.SystemAppend)
+	}
+
+	for _, dir := range router.AgentDirs() {
+		if dir == outside {
+			t.Errorf("agents were loaded from %s, which is outside every project", outside)
+		}
+	}
+	if _, rejected := router.rejectedAgentDirs[outside]; !rejected {
+		t.Errorf("%s should be remembered as outside every project", outside)
+	}
+}
+
+func TestModelRouter_AgentAttribution(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	project := t.TempDir()
+	writeAgentFile(t, project, "reviewer", "---\nname: reviewer\nmodel: gpt-4o\n---\nYou review code.\n")
+	writeSessionLog(t, home, project)
+
+	// Routing is off, requests are still attributed but keep their model
+	cfg := &config.Config{}
+	providers := map[string]provider.Provider{
+		"anthropic": &stubProvider{name: "anthropic"},
+		"openai":    &stubProvider{name: "openai"},
+	}
+	router := NewModelRouter(cfg, providers, log.New(os.Stdout, "test: ", log.LstdFlags))
+
+	claudeCode := func(prompt string) []model.AnthropicSystemMessage {
+		return []model.AnthropicSystemMessage{
+			{Text: "You are Claude Code, Anthropic's official CLI for Claude."},
+			{Text: prompt + "\n\nNotes:\n- Be concise.\n\n<env>\nWorking directory: " + project + "\n</env>"},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		system    []model.AnthropicSystemMessage
+		wantAgent string
+	}{
+		{"main agent", claudeCode("You are an interactive CLI tool that helps users with software engineering tasks."), model.AgentMain},
+		{"agent file", claudeCode("You review code."), "reviewer"},
+		{"built-in subagent", claudeCode("You are a file search specialist for Claude Code, Anthropic's official CLI for Claude."), "Explore"},
+		{"unknown subagent", claudeCode("You translate documents."), model.AgentUnknown},
+		{"other client", []model.AnthropicSystemMessage{{Text: "You are a helpful assistant."}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := router.DetermineRoute(&model.AnthropicRequest{Model: "claude-sonnet-4-5-20250929", System: tt.system}, nil)
+			if err != nil {
+				t.Fatalf("DetermineRoute returned error: %v", err)
+			}
+			if decision.Agent != tt.wantAgent {
+				t.Errorf("agent = %q, want %q", decision.Agent, tt.wantAgent)
+			}
+			if decision.TargetModel != "claude-sonnet-4-5-20250929" || decision.Reason != model.RoutingReasonDefault {
+				t.Errorf("routing is disabled but got model=%s reason=%s", decision.TargetModel, decision.Reason)
+			}
+		})
+	}
+}
+
+func TestClaudeProjectRoot(t *testing.T) {
+	home := t.TempDir()
+	projectsDir := filepath.Join(home, ".claude", "projects")
+	base := t.TempDir()
+	project := filepath.Join(base, "my-app")
+	writeSessionLog(t, home, project)
+
+	tests := []struct {
+		dir  string
+		want string
+	}{
+		{project, project},
+		{filepath.Join(project, "cmd", "server"), project},
+		{project + "/", project},
+		// Claude Code gives /my/app the same directory name as /my-app, its sessions were not recorded there
+		{filepath.Join(base, "my", "app"), ""},
+		{base, ""},
+		{"my-app", ""},
+	}
+
+	for _, tt := range tests {
+		if got := claudeProjectRoot(projectsDir, tt.dir); got != tt.want {
+			t.Errorf("claudeProjectRoot(%q) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+)
+
+// budgetSpendTTL is how long a budget's spend is reused before it is summed again. Older spend is
+// still used while it is summed again in the background, requests never wait for a refresh. A
+// request's cost is only known once it completed anyway.
+const budgetSpendTTL = 5 * time.Second
+
+// BudgetExceededError is returned for a request that a hard budget limit blocks
+type BudgetExceededError struct {
+	Budget model.BudgetStatus
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("Spending budget %q is exhausted: $%.2f spent of the $%.2f hard limit %s. "+
+		"Requests are rejected until it resets at %s or the limit is raised in config.yaml.",
+		e.Budget.Name, e.Budget.Spent, e.Budget.Hard, budgetPeriodName(e.Budget.Period), e.Budget.ResetsAt)
+}
+
+// BudgetTracker checks requests against the budget limits in the config, with spend summed from
+// the priced usage in storage
+type BudgetTracker struct {
+	storage StorageService
+	logger  *log.Logger
+	now     func() time.Time
+
+	mu       sync.Mutex
+	spend    map[budgetKey]cachedSpend
+	inflight map[budgetKey]*spendCall // spend being summed, at most one query per key
+	warned   map[string]time.Time     // budget name -> start of the period its soft limit was logged for
+}
+
+// budgetKey identifies the requests a limit sums in a period, limits that only differ in their
+// name or amounts share it
+type budgetKey struct {
+	since      time.Time
+	model      string
+	apiKeyHash string
+}
+
+type cachedSpend struct {
+	usd        float64
+	computedAt time.Time
+}
+
+// spendCall is a spend query in flight, done is closed once usd and err are set
+type spendCall struct {
+	done chan struct{}
+	usd  float64
+	err  error
+}
+
+func NewBudgetTracker(storage StorageService, logger *log.Logger) *BudgetTracker {
+	return &BudgetTracker{
+		storage:  storage,
+		logger:   logger,
+		now:      time.Now,
+		spend:    make(map[budgetKey]cachedSpend),
+		inflight: make(map[budgetKey]*spendCall),
+		warned:   make(map[string]time.Time),
+	}
+}
+
+// Check returns a *BudgetExceededError when a hard limit that applies to a request for the
+// routed model, sent with the API key hash, has been reached. Soft limits that were passed are
+// logged once per period.
+func (t *BudgetTracker) Check(budgets config.BudgetsConfig, modelName, keyHash string) error {
+	if !budgets.Enable {
+		return nil
+	}
+
+	var exceeded *BudgetExceededError
+	for _, limit := range budgets.Limits {
+		if !budgetApplies(limit, modelName, keyHash) {
+			continue
+		}
+		status, err := t.status(limit)
+		if err != nil {
+			return err
+		}
+		if status.SoftExceeded {
+			t.warnSoftLimit(status)
+		}
+		if status.HardExceeded && exceeded == nil {
+			exceeded = &BudgetExceededError{Budget: status}
+		}
+	}
+	if exceeded != nil {
+		return exceeded
+	}
+	return nil
+}
+
+// Status returns every configured limit with its spend in the current period
+func (t *BudgetTracker) Status(budgets config.BudgetsConfig) ([]model.BudgetStatus, error) {
+	statuses := make([]model.BudgetStatus, 0, len(budgets.Limits))
+	for _, limit := range budgets.Limits {
+		status, err := t.status(limit)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (t *BudgetTracker) status(limit config.BudgetLimit) (model.BudgetStatus, error) {
+	now := t.now()
+	start, end := budgetPeriod(limit.Period, now)
+
+	spent, err := t.spent(budgetKey{since: start, model: limit.Model, apiKeyHash: limit.APIKeyHash}, now)
+	if err != nil {
+		return model.BudgetStatus{}, err
+	}
+
+	return model.BudgetStatus{
+		Name:         limit.Name,
+		Period:       limit.Period,
+		Model:        limit.Model,
+		APIKeyHash:   limit.APIKeyHash,
+		PeriodStart:  start.Format(time.RFC3339),
+		ResetsAt:     end.Format(time.RFC3339),
+		Spent:        spent,
+		Soft:         limit.Soft,
+		Hard:         limit.Hard,
+		SoftExceeded: limit.Soft > 0 && spent >= limit.Soft,
+		HardExceeded: limit.Hard > 0 && spent >= limit.Hard,
+	}, nil
+}
+
+// spent returns the cost in USD of the requests a key covers. Cached spend is returned right
+// away, and summed again in the background once it is older than budgetSpendTTL. Only the first
+// check of a period waits for the query, concurrent ones share it.
+func (t *BudgetTracker) spent(key budgetKey, now time.Time) (float64, error) {
+	t.mu.Lock()
+	cached, ok := t.spend[key]
+	call, summing := t.inflight[key]
+	if !summing && (!ok || now.Sub(cached.computedAt) >= budgetSpendTTL) {
+		call = &spendCall{done: make(chan struct{})}
+		t.inflight[key] = call
+		go t.sum(key, call)
+	}
+	t.mu.Unlock()
+
+	if ok {
+		return cached.usd, nil
+	}
+	<-call.done
+	return call.usd, call.err
+}
+
+// sum queries the spend of a key and caches it
+func (t *BudgetTracker) sum(key budgetKey, call *spendCall) {
+	cost, err := t.storage.GetCostSince(key.since, key.model, key.apiKeyHash)
+	call.usd, call.err = cost/1_000_000, err
+	if err != nil {
+		t.logger.Printf("❌ Failed to sum budget spend: %v", err)
+	}
+
+	t.mu.Lock()
+	delete(t.inflight, key)
+	if err == nil {
+		// Drop the spend of periods that are over
+		for k := range t.spend {
+			if k.since.Before(key.since) && k.model == key.model && k.apiKeyHash == key.apiKeyHash {
+				delete(t.spend, k)
+			}
+		}
+		t.spend[key] = cachedSpend{usd: call.usd, computedAt: t.now()}
+	}
+	t.mu.Unlock()
+	close(call.done)
+}
+
+func (t *BudgetTracker) warnSoftLimit(status model.BudgetStatus) {
+	start, _ := time.Parse(time.RFC3339, status.PeriodStart)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if warnedFor, ok := t.warned[status.Name]; ok && warnedFor.Equal(start) {
+		return
+	}
+	t.warned[status.Name] = start
+	t.logger.Printf("⚠️  Budget %q passed its soft limit: $%.2f spent of $%.2f %s",
+		status.Name, status.Spent, status.Soft, budgetPeriodName(status.Period))
+}
+
+// budgetApplies reports whether a request for the routed model, sent with the API key hash,
+// counts against a limit
+func budgetApplies(limit config.BudgetLimit, modelName, keyHash string) bool {
+	if limit.Model != "" {
+		if ok, _ := path.Match(limit.Model, modelName); !ok {
+			return false
+		}
+	}
+	return limit.APIKeyHash == "" || limit.APIKeyHash == keyHash
+}
+
+// budgetPeriod returns the start of the day or month now is in, and the start of the next one
+func budgetPeriod(period string, now time.Time) (time.Time, time.Time) {
+	year, month, day := now.Date()
+	if period == "month" {
+		start := time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 1, 0)
+	}
+	start := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+func budgetPeriodName(period string) string {
+	if period == "month" {
+		return "this month"
+	}
+	return "today"
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+)
+
+// costStorage answers GetCostSince with a fixed cost per model pattern
+type costStorage struct {
+	StorageService
+	costs map[string]float64
+	calls atomic.Int32
+}
+
+func (s *costStorage) GetCostSince(since time.Time, modelPattern, apiKeyHash string) (float64, error) {
+	s.calls.Add(1)
+	return s.costs[modelPattern], nil
+}
+
+func TestBudgetTracker_Check(t *testing.T) {
+	storage := &costStorage{costs: map[string]float64{
+		"":              30_000_000, // $30
+		"claude-opus-*": 12_000_000, // $12
+	}}
+	now := time.Date(2025, 10, 15, 14, 30, 0, 0, time.Local)
+	tracker := NewBudgetTracker(storage, log.New(io.Discard, "", 0))
+	tracker.now = func() time.Time { return now }
+
+	budgets := config.BudgetsConfig{
+		Enable: true,
+		Limits: []config.BudgetLimit{
+			{Name: "daily", Period: "day", Soft: 25, Hard: 100},
+			{Name: "opus", Period: "month", Model: "claude-opus-*", Hard: 10},
+			{Name: "team key", Period: "day", APIKeyHash: "sha256:abc", Hard: 1},
+		},
+	}
+
+	if err := tracker.Check(budgets, "claude-sonnet-4-5", "sha256:def"); err != nil {
+		t.Errorf("sonnet with another key should pass, got %v", err)
+	}
+
+	err := tracker.Check(budgets, "claude-opus-4-1", "sha256:def")
+	var exceeded *BudgetExceededError
+	if !errors.As(err, &exceeded) {
+		t.Fatalf("opus should be blocked, got %v", err)
+	}
+	if b := exceeded.Budget; b.Name != "opus" || b.Spent != 12 || !b.HardExceeded ||
+		b.PeriodStart != time.Date(2025, 10, 1, 0, 0, 0, 0, time.Local).Format(time.RFC3339) ||
+		b.ResetsAt != time.Date(2025, 11, 1, 0, 0, 0, 0, time.Local).Format(time.RFC3339) {
+		t.Errorf("unexpected exceeded budget: %+v", b)
+	}
+
+	if err := tracker.Check(budgets, "claude-sonnet-4-5", "sha256:abc"); !errors.As(err, &exceeded) || exceeded.Budget.Name != "team key" {
+		t.Errorf("the team key should be blocked, got %v", err)
+	}
+
+	// Spend is reused until it is stale, then summed again in the background
+	calls := storage.calls.Load()
+	tracker.Check(budgets, "claude-sonnet-4-5", "sha256:def")
+	if storage.calls.Load() != calls {
+		t.Errorf("spend was summed again within the TTL")
+	}
+	storage.costs[""] = 40_000_000
+	now = now.Add(budgetSpendTTL)
+	statuses, _ := tracker.Status(budgets)
+	if statuses[0].Spent != 30 {
+		t.Errorf("stale spend should be returned while it is refreshed, got %v", statuses[0].Spent)
+	}
+	waitForRefresh(t, tracker)
+	if statuses, _ := tracker.Status(budgets); statuses[0].Spent != 40 {
+		t.Errorf("spend after the refresh = %v, want 40", statuses[0].Spent)
+	}
+	storage.costs[""] = 30_000_000
+	now = now.Add(budgetSpendTTL)
+	tracker.Status(budgets)
+	waitForRefresh(t, tracker)
+
+	budgets.Enable = false
+	if err := tracker.Check(budgets, "claude-opus-4-1", "sha256:abc"); err != nil {
+		t.Errorf("disabled budgets should not block, got %v", err)
+	}
+
+	statuses, err = tracker.Status(budgets)
+	if err != nil {
+		t.Fatalf("Status returned error: %v", err)
+	}
+	if len(statuses) != 3 || !statuses[0].SoftExceeded || statuses[0].HardExceeded || statuses[0].Spent != 30 {
+		t.Errorf("unexpected statuses: %+v", statuses)
+	}
+}
+
+// waitForRefresh waits until no spend is being summed in the background
+func waitForRefresh(t *testing.T, tracker *BudgetTracker) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		tracker.mu.Lock()
+		pending := len(tracker.inflight)
+		tracker.mu.Unlock()
+		if pending == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("spend refresh did not finish")
+}
+
+func TestBudgetTracker_SumsOncePerKey(t *testing.T) {
+	storage := &costStorage{costs: map[string]float64{"": 1_000_000}}
+	tracker := NewBudgetTracker(storage, log.New(io.Discard, "", 0))
+	budgets := config.BudgetsConfig{Enable: true, Limits: []config.BudgetLimit{
+		{Name: "daily", Period: "day", Hard: 10},
+		{Name: "daily soft", Period: "day", Soft: 5},
+	}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := tracker.Check(budgets, "claude-sonnet-4-5", ""); err != nil {
+				t.Errorf("Check returned error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls := storage.calls.Load(); calls != 1 {
+		t.Errorf("spend was summed %d times for concurrent checks of one period, want 1", calls)
+	}
+}
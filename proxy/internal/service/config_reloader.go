@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+	"github.com/seifghazi/claude-code-monitor/internal/provider"
+)
+
+// RouterBuilder creates the providers and model router for a config. Providers in reuse are
+// kept from the previous router, with their circuit breaker state, instead of being created anew.
+type RouterBuilder func(cfg *config.Config, reuse map[string]provider.Provider) (*ModelRouter, error)
+
+// ConfigReloader holds the active config and the model router built from it. A reload builds
+// both anew and swaps them in at once, so requests that were already routed keep the old ones.
+type ConfigReloader struct {
+	build  RouterBuilder
+	logger *log.Logger
+
+	active atomic.Pointer[activeConfig]
+
+	mu     sync.Mutex        // serializes reloads and polls
+	stamps map[string]string // watched file or agent directory -> modification stamp
+}
+
+type activeConfig struct {
+	config   *config.Config
+	router   *ModelRouter
+	loadedAt time.Time
+}
+
+func NewConfigReloader(cfg *config.Config, build RouterBuilder, logger *log.Logger) (*ConfigReloader, error) {
+	router, err := build(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ConfigReloader{build: build, logger: logger}
+	r.active.Store(&activeConfig{config: cfg, router: router, loadedAt: time.Now()})
+	r.stamps = r.currentStamps()
+	return r, nil
+}
+
+// Router returns the active model router
+func (r *ConfigReloader) Router() *ModelRouter {
+	return r.active.Load().router
+}
+
+// Config returns the active config and when it was loaded
+func (r *ConfigReloader) Config() (*config.Config, time.Time) {
+	active := r.active.Load()
+	return active.config, active.loadedAt
+}
+
+// Reload re-reads the config file and the agent definitions. When either fails to load the
+// active config stays in place.
+func (r *ConfigReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reload()
+}
+
+func (r *ConfigReloader) reload() error {
+	old := r.active.Load()
+
+	// Unlike at startup, a config file that can't be parsed must not fall back to the defaults
+	cfg, err := config.LoadFile(old.config.Path)
+	if err != nil {
+		return err
+	}
+	router, err := r.build(cfg, reusableProviders(old.config, cfg, old.router.providers))
+	if err != nil {
+		return fmt.Errorf("failed to build router: %w", err)
+	}
+
+	// Keep the agents of the projects that were discovered from requests
+	for _, dir := range old.router.AgentDirs() {
+		router.loadAgentsFromDir(dir)
+	}
+
+	for _, setting := range restartRequired(old.config, cfg) {
+		r.logger.Printf("⚠️  %s changed, restart the proxy to apply it", setting)
+	}
+
+	r.active.Store(&activeConfig{config: cfg, router: router, loadedAt: time.Now()})
+	r.stamps = r.currentStamps()
+	return nil
+}
+
+// Watch polls the config file and the agent directories and reloads when one of them changed,
+// until ctx is done
+func (r *ConfigReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if changed := r.poll(); changed != "" {
+				r.logger.Printf("🔄 %s changed, reloading configuration", changed)
+				if err := r.Reload(); err != nil {
+					r.logger.Printf("❌ Failed to reload configuration, keeping the active one: %v", err)
+				} else {
+					r.logger.Println("✅ Configuration reloaded")
+				}
+			}
+		}
+	}
+}
+
+// poll returns the first watched path whose stamp changed since the last poll, or "". Paths
+// that weren't watched before, such as projects discovered since, are only recorded. A change
+// is reported once, so a broken config file isn't reloaded again until it is edited.
+func (r *ConfigReloader) poll() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, current := r.stamps, r.currentStamps()
+	r.stamps = current
+
+	paths := make([]string, 0, len(current))
+	for path := range current {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if stamp, ok := previous[path]; ok && stamp != current[path] {
+			return path
+		}
+	}
+	return ""
+}
+
+// currentStamps stamps the config file and every agent directory the router loaded from
+func (r *ConfigReloader) currentStamps() map[string]string {
+	active := r.active.Load()
+	stamps := map[string]string{active.config.Path: fileStamp(active.config.Path)}
+	for _, dir := range active.router.AgentDirs() {
+		files, _ := filepath.Glob(filepath.Join(dir, ".claude", "agents", "*.md"))
+		fileStamps := make([]string, 0, len(files))
+		for _, file := range files {
+			fileStamps = append(fileStamps, file+"@"+fileStamp(file))
+		}
+		stamps[dir] = strings.Join(fileStamps, ",")
+	}
+	return stamps
+}
+
+func fileStamp(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "missing"
+	}
+	return fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size())
+}
+
+// reusableProviders returns the providers whose settings are the same in both configs. A change
+// to the model catalog or the circuit breaker settings affects every provider.
+func reusableProviders(old, cfg *config.Config, providers map[string]provider.Provider) map[string]provider.Provider {
+	if !reflect.DeepEqual(old.Models, cfg.Models) || old.CircuitBreaker != cfg.CircuitBreaker {
+		return nil
+	}
+
+	reuse := make(map[string]provider.Provider)
+	for name, p := range providers {
+		var unchanged bool
+		switch name {
+		case "anthropic":
+			unchanged = reflect.DeepEqual(old.Providers.Anthropic, cfg.Providers.Anthropic)
+		case "openai":
+			unchanged = reflect.DeepEqual(old.Providers.OpenAI, cfg.Providers.OpenAI)
+		default:
+			oldNamed, wasNamed := old.Providers.Named[name]
+			named, isNamed := cfg.Providers.Named[name]
+			unchanged = wasNamed && isNamed && reflect.DeepEqual(oldNamed, named)
+		}
+		if unchanged {
+			reuse[name] = p
+		}
+	}
+	return reuse
+}
+
+// restartRequired lists the settings that only take effect when the proxy starts
+func restartRequired(old, cfg *config.Config) []string {
+	var settings []string
+	if old.Server.Port != cfg.Server.Port {
+		settings = append(settings, "server.port")
+	}
+	if old.Server.ReadTimeout != cfg.Server.ReadTimeout || old.Server.WriteTimeout != cfg.Server.WriteTimeout || old.Server.IdleTimeout != cfg.Server.IdleTimeout {
+		settings = append(settings, "server.timeouts")
+	}
+	if old.Storage != cfg.Storage {
+		settings = append(settings, "storage")
+	}
+	if old.Reload.PollIntervalDuration != cfg.Reload.PollIntervalDuration {
+		settings = append(settings, "reload.poll_interval")
+	}
+	return settings
+}
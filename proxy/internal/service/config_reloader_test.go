@@ -0,0 +1,106 @@
+package service
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+	"github.com/seifghazi/claude-code-monitor/internal/provider"
+)
+
+func TestConfigReloader(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// Make the change visible to polling even on coarse file system timestamps
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rule := func(target string) string {
+		return "routing:\n  rules:\n    - name: all\n      target: " + target + "\n"
+	}
+
+	writeConfig(rule("gpt-4o"), time.Now().Add(-time.Hour))
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile returned error: %v", err)
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	reloader, err := NewConfigReloader(cfg, func(cfg *config.Config, reuse map[string]provider.Provider) (*ModelRouter, error) {
+		providers := map[string]provider.Provider{
+			"anthropic": &stubProvider{name: "anthropic"},
+			"openai":    &stubProvider{name: "openai"},
+		}
+		for name, p := range reuse {
+			providers[name] = p
+		}
+		return NewModelRouter(cfg, providers, logger), nil
+	}, logger)
+	if err != nil {
+		t.Fatalf("NewConfigReloader returned error: %v", err)
+	}
+
+	target := func(router *ModelRouter) string {
+		t.Helper()
+		decision, err := router.DetermineRoute(&model.AnthropicRequest{Model: "claude-sonnet-4-5-20250929"}, nil)
+		if err != nil {
+			t.Fatalf("DetermineRoute returned error: %v", err)
+		}
+		return decision.TargetModel
+	}
+
+	inFlight := reloader.Router()
+	if changed := reloader.poll(); changed != "" {
+		t.Fatalf("poll reported %s before anything changed", changed)
+	}
+
+	writeConfig(rule("gpt-4.1"), time.Now())
+	if changed := reloader.poll(); changed != path {
+		t.Fatalf("poll reported %q, want %q", changed, path)
+	}
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+	if got := target(reloader.Router()); got != "gpt-4.1" {
+		t.Errorf("after reload routed to %s, want gpt-4.1", got)
+	}
+	if got := target(inFlight); got != "gpt-4o" {
+		t.Errorf("router taken before the reload routed to %s, want gpt-4o", got)
+	}
+
+	// Providers whose settings didn't change are kept, with their circuit breaker state
+	if reloader.Router().providers["anthropic"] != inFlight.providers["anthropic"] {
+		t.Error("reloading routing rules replaced the anthropic provider")
+	}
+	beforeChange := reloader.Router()
+	writeConfig(rule("gpt-4.1")+"providers:\n  anthropic:\n    max_retries: 7\n", time.Now().Add(time.Second))
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+	if reloader.Router().providers["anthropic"] == beforeChange.providers["anthropic"] {
+		t.Error("changed anthropic settings should rebuild its provider")
+	}
+	if reloader.Router().providers["openai"] != beforeChange.providers["openai"] {
+		t.Error("unchanged openai provider should be kept")
+	}
+
+	// A broken file keeps the active config
+	writeConfig("routing: [", time.Now().Add(time.Minute))
+	if err := reloader.Reload(); err == nil {
+		t.Fatal("Reload of an invalid config should fail")
+	}
+	if got := target(reloader.Router()); got != "gpt-4.1" {
+		t.Errorf("after failed reload routed to %s, want gpt-4.1", got)
+	}
+}
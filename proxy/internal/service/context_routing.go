@@ -0,0 +1,77 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+)
+
+const defaultContextThreshold = 0.9
+
+// ContextLengthError is returned by DetermineRoute for requests that won't fit their model's
+// context window and have nowhere else to go. The message follows Anthropic's, which Claude Code
+// recognizes and answers by compacting the conversation.
+type ContextLengthError struct {
+	Model           string
+	EstimatedTokens int
+	Limit           int
+}
+
+func (e *ContextLengthError) Error() string {
+	return fmt.Sprintf("prompt is too long: %d tokens > %d maximum", e.EstimatedTokens, e.Limit)
+}
+
+// applyContextLimit moves the decision to the configured long-context model when the estimated
+// input would not fit the target's context window
+func (r *ModelRouter) applyContextLimit(decision *RoutingDecision, input *routeInput) error {
+	limit := r.inputTokenLimit(decision.TargetModel)
+	if limit == 0 {
+		return nil
+	}
+
+	estimated := input.inputTokens()
+	decision.EstimatedInputTokens = estimated
+	if estimated <= limit {
+		return nil
+	}
+
+	if target := lookupModelTarget(r.config.ContextRouting.Targets, decision.TargetModel); target != "" {
+		providerName, modelName := r.resolveModel(target)
+		p := r.providers[providerName]
+		if p == nil {
+			return fmt.Errorf("provider %s not found for long-context model %s", providerName, target)
+		}
+
+		if targetLimit := r.inputTokenLimit(modelName); targetLimit == 0 || estimated <= targetLimit {
+			r.logger.Printf("📐 ~%d input tokens > %d for %s → \033[32m%s\033[0m", estimated, limit, decision.TargetModel, modelName)
+			decision.Reason = model.RoutingReasonLongContext
+			decision.Detail = fmt.Sprintf("~%d input tokens exceed the %d token limit of %s", estimated, limit, decision.TargetModel)
+			decision.TargetModel = modelName
+			decision.Provider = p
+			return nil
+		}
+		limit = r.inputTokenLimit(modelName)
+	}
+
+	if r.config.ContextRouting.Reject {
+		return &ContextLengthError{Model: decision.TargetModel, EstimatedTokens: estimated, Limit: limit}
+	}
+
+	r.logger.Printf("⚠️  ~%d input tokens > %d for %s and no long-context model configured, forwarding anyway", estimated, limit, decision.TargetModel)
+	return nil
+}
+
+// inputTokenLimit is the estimate above which a request counts as too large for a model, 0 when
+// the model's context window is unknown
+func (r *ModelRouter) inputTokenLimit(modelName string) int {
+	entry, ok := r.models.Lookup(modelName)
+	if !ok || entry.ContextWindow == 0 {
+		return 0
+	}
+
+	threshold := r.config.ContextRouting.Threshold
+	if threshold <= 0 {
+		threshold = defaultContextThreshold
+	}
+	return int(float64(entry.ContextWindow) * threshold)
+}
@@ -20,12 +20,9 @@ type Indexer struct {
 
 // NewIndexer creates a new Indexer with the given database connection
 func NewIndexer(db *sql.DB) *Indexer {
-	enc, err := tokenizer.Get(tokenizer.Cl100kBase)
-	if err != nil {
-		// Log once at startup rather than silently failing on every estimate
-		fmt.Printf("WARNING: Failed to initialize tokenizer, token estimates will be 0: %v\n", err)
-	}
-	return &Indexer{db: db, enc: enc}
+	// Shares the router's codec. The counting below is the indexer's own and stays as is, so
+	// stored estimates keep matching the rows indexed before.
+	return &Indexer{db: db, enc: cl100k()}
 }
 
 // responseMetadata holds parsed response data for indexing
@@ -5,8 +5,14 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log"
-	"os"
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/seifghazi/claude-code-monitor/internal/config"
 	"github.com/seifghazi/claude-code-monitor/internal/model"
@@ -18,38 +24,122 @@ type RoutingDecision struct {
 	Provider      provider.Provider
 	OriginalModel string
 	TargetModel   string
+	// Fallbacks are tried in order when the target's upstream fails before responding
+	Fallbacks []RouteCandidate
+	// Reason says why the target was picked, one of the model.RoutingReason constants
+	Reason string
+	// Detail is a human readable explanation of the reason
+	Detail string
+	// Rule is the name of the routing rule that picked the target, if any
+	Rule string
+	// Agent is the subagent the system prompt matched, PromptHash the hash it was looked up by
+	Agent      string
+	PromptHash string
+	// Overrides are request parameters set by the rule or the subagent mapping
+	Overrides map[string]interface{}
+	// SystemAppend is added to the end of the system prompt
+	SystemAppend string
+	// EstimatedInputTokens is set when the input size was needed for the decision
+	EstimatedInputTokens int
+	// Shadow gets a copy of the request for comparison, nil when the request isn't mirrored
+	Shadow *RouteCandidate
 }
 
+// Trace returns the explanation of the decision that is stored with the request
+func (d *RoutingDecision) Trace() *model.RoutingTrace {
+	trace := &model.RoutingTrace{
+		Reason:               d.Reason,
+		Detail:               d.Detail,
+		Rule:                 d.Rule,
+		Agent:                d.Agent,
+		PromptHash:           d.PromptHash,
+		Provider:             d.Provider.Name(),
+		EstimatedInputTokens: d.EstimatedInputTokens,
+		Candidates:           []model.RouteCandidate{{Model: d.TargetModel, Provider: d.Provider.Name()}},
+	}
+	for _, fallback := range d.Fallbacks {
+		trace.Candidates = append(trace.Candidates, model.RouteCandidate{Model: fallback.Model, Provider: fallback.Provider.Name()})
+	}
+	if d.Shadow != nil {
+		trace.Shadow = &model.RouteCandidate{Model: d.Shadow.Model, Provider: d.Shadow.Provider.Name()}
+	}
+	return trace
+}
+
+// RouteCandidate is a model together with the provider that serves it
+type RouteCandidate struct {
+	Model    string
+	Provider provider.Provider
+}
+
+const (
+	// maxAgentDirs bounds the directories agents are loaded from, and the ones remembered as
+	// outside every project
+	maxAgentDirs = 1024
+	// agentDirRecheck is how long a directory outside every project isn't looked at again
+	agentDirRecheck = time.Minute
+)
+
 type ModelRouter struct {
 	config             *config.Config
 	providers          map[string]provider.Provider
-	subagentMappings   map[string]string             // agentName -> targetModel
-	customAgentPrompts map[string]SubagentDefinition // promptHash -> definition
-	modelProviderMap   map[string]string             // model -> provider mapping
+	subagentMappings   map[string]config.SubagentMapping // agentName -> target model and overrides
+	customAgentPrompts map[string]SubagentDefinition     // promptHash -> definition
+	agentDirs          map[string]bool                   // directories whose agents were loaded
+	rejectedAgentDirs  map[string]time.Time              // directories named in prompts outside every project, and when they were checked
+	projectsDir        string                            // ~/.claude/projects, directories named in prompts must be in one of its projects
+	agentsMu           sync.RWMutex                      // guards customAgentPrompts, agentDirs and rejectedAgentDirs
+	models             config.ModelCatalog
+	rules              []*routingRule
+	estimator          *TokenEstimator
+	sample             func() float64 // decides which requests are mirrored to a shadow model
 	logger             *log.Logger
 }
 
 type SubagentDefinition struct {
-	Name           string
+	Name string
+	// TargetModel is empty when neither the config nor the agent file maps the agent
 	TargetModel    string
 	TargetProvider string
+	Tools          []string
+	Source         string // path of the agent file
 	FullPrompt     string // Store for debugging
+	// Overrides and SystemAppend adjust the agent's requests, from the mapping's overrides
+	Overrides    map[string]interface{}
+	SystemAppend string
+}
+
+// adjustsRequests reports whether the agent's requests are routed or changed at all
+func (d SubagentDefinition) adjustsRequests() bool {
+	return d.TargetModel != "" || len(d.Overrides) > 0 || d.SystemAppend != ""
 }
 
 func NewModelRouter(cfg *config.Config, providers map[string]provider.Provider, logger *log.Logger) *ModelRouter {
+	models := cfg.Models
+	if len(models) == 0 {
+		models = config.DefaultModels()
+	}
+
 	router := &ModelRouter{
 		config:             cfg,
 		providers:          providers,
 		subagentMappings:   cfg.Subagents.Mappings,
 		customAgentPrompts: make(map[string]SubagentDefinition),
-		modelProviderMap:   initializeModelProviderMap(),
+		agentDirs:          make(map[string]bool),
+		rejectedAgentDirs:  make(map[string]time.Time),
+		projectsDir:        claudeProjectsDir(),
+		models:             models,
+		estimator:          NewTokenEstimator(),
+		sample:             rand.Float64,
 		logger:             logger,
 	}
 
-	// Only load custom agents if subagents are enabled
-	if cfg.Subagents.Enable {
-		router.loadCustomAgents()
-	} else {
+	router.loadRoutingRules()
+
+	// Agent definitions are loaded even when subagent routing is off, every request is
+	// attributed to the agent that sent it
+	router.loadCustomAgents()
+	if !cfg.Subagents.Enable {
 		logger.Println("")
 		logger.Println("ℹ️  Subagent routing is disabled")
 		logger.Println("   Enable it in config.yaml to route Claude Code agents to different LLM providers")
@@ -58,196 +148,426 @@ func NewModelRouter(cfg *config.Config, providers map[string]provider.Provider,
 	return router
 }
 
-// initializeModelProviderMap creates a mapping of model names to their providers
-func initializeModelProviderMap() map[string]string {
-	modelMap := make(map[string]string)
+// extractStaticPrompt extracts the portion before "Notes:" if it exists
+func (r *ModelRouter) extractStaticPrompt(systemPrompt string) string {
+	// Find the "Notes:" section
+	notesIndex := strings.Index(systemPrompt, "\nNotes:")
+	if notesIndex == -1 {
+		notesIndex = strings.Index(systemPrompt, "\n\nNotes:")
+	}
+
+	if notesIndex != -1 {
+		// Return only the part before "Notes:"
+		return strings.TrimSpace(systemPrompt[:notesIndex])
+	}
+
+	// If no "Notes:" section, return the whole prompt
+	return strings.TrimSpace(systemPrompt)
+}
 
-	// OpenAI models
-	openaiModels := []string{
-		// GPT-5 family
-		"gpt-5", "gpt-5-mini", "gpt-5-nano",
+// loadCustomAgents registers the agent definitions found in the startup search directories.
+// Directories named in the system prompt of later requests are searched when they come up.
+func (r *ModelRouter) loadCustomAgents() {
+	dirs := agentSearchDirs()
+	for _, dir := range dirs {
+		r.loadAgentsFromDir(dir)
+	}
 
-		// GPT-4.1 family
-		"gpt-4.1", "gpt-4.1-2025-04-14",
-		"gpt-4.1-mini", "gpt-4.1-mini-2025-04-14",
-		"gpt-4.1-nano", "gpt-4.1-nano-2025-04-14",
+	if !r.config.Subagents.Enable {
+		if len(r.customAgentPrompts) > 0 {
+			r.logger.Printf("🤖 Loaded %d agent definitions for attribution", len(r.customAgentPrompts))
+		}
+		return
+	}
 
-		// GPT-4.5
-		"gpt-4.5-preview", "gpt-4.5-preview-2025-02-27",
+	// Log warning if subagent is mapped but definition not found
+	found := map[string]bool{}
+	for _, def := range r.customAgentPrompts {
+		found[def.Name] = true
+	}
+	for agentName := range r.subagentMappings {
+		if !found[agentName] {
+			r.logger.Printf("⚠️  Subagent '%s' is mapped but no definition file was found in the .claude/agents of %d directories", agentName, len(dirs))
+		}
+	}
 
-		// GPT-4o variants
-		"gpt-4o", "gpt-4o-2024-08-06",
-		"gpt-4o-mini", "gpt-4o-mini-2024-07-18",
+	// Pretty print loaded subagents
+	if len(r.customAgentPrompts) > 0 {
+		defs := make([]SubagentDefinition, 0, len(r.customAgentPrompts))
+		for _, def := range r.customAgentPrompts {
+			defs = append(defs, def)
+		}
+		sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
 
-		// GPT-3.5 variants
-		"gpt-3.5-turbo", "gpt-3.5-turbo-0125", "gpt-3.5-turbo-1106", "gpt-3.5-turbo-instruct",
+		r.logger.Println("")
+		r.logger.Println("🤖 Subagent Model Mappings:")
+		r.logger.Println("──────────────────────────────────────")
 
-		// O1 series
-		"o1", "o1-2024-12-17",
-		"o1-pro", "o1-pro-2025-03-19",
-		"o1-mini", "o1-mini-2024-09-12",
+		for _, def := range defs {
+			target := def.TargetModel
+			if target == "" {
+				target = "(requested model)"
+			}
+			r.logger.Printf("   \033[36m%s\033[0m → \033[32m%s\033[0m", def.Name, target)
+		}
 
-		// O3 series
-		"o3-pro", "o3-pro-2025-06-10",
-		"o3", "o3-2025-04-16",
-		"o3-mini", "o3-mini-2025-01-31",
+		r.logger.Println("──────────────────────────────────────")
+		r.logger.Println("")
 	}
+}
 
-	for _, model := range openaiModels {
-		modelMap[model] = "openai"
+// loadAgentsFromDir registers the agents in dir/.claude/agents, once per directory. It reports
+// whether the directory was new. The files are read before the lock is taken, so routing isn't
+// held up by a slow directory.
+func (r *ModelRouter) loadAgentsFromDir(dir string) bool {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
 	}
 
-	// Anthropic models
-	anthropicModels := []string{
-		"claude-opus-4-1-20250805",
-		"claude-opus-4-20250514",
-		"claude-sonnet-4-20250514",
-		"claude-sonnet-4-5-20250929",
-		"claude-opus-4-5-20251101",
-		"claude-3-7-sonnet-20250219",
-		"claude-3-5-haiku-20241022",
+	r.agentsMu.RLock()
+	loaded, full := r.agentDirs[dir], len(r.agentDirs) >= maxAgentDirs
+	r.agentsMu.RUnlock()
+	if loaded {
+		return false
+	}
+	if full {
+		r.logger.Printf("⚠️  Not loading the agents of %s, agents were already loaded from %d directories", dir, maxAgentDirs)
+		return false
 	}
 
-	for _, model := range anthropicModels {
-		modelMap[model] = "anthropic"
+	type parsedAgent struct {
+		hash string
+		def  SubagentDefinition
 	}
+	var agents []parsedAgent
+	files, _ := filepath.Glob(filepath.Join(dir, ".claude", "agents", "*.md"))
+	for _, file := range files {
+		agent, err := parseAgentFile(file)
+		if err != nil {
+			r.logger.Printf("⚠️  Skipping agent definition: %v", err)
+			continue
+		}
 
-	return modelMap
-}
+		// Extract only the static part (before "Notes:" if it exists)
+		staticPrompt := r.extractStaticPrompt(agent.Prompt)
 
-// extractStaticPrompt extracts the portion before "Notes:" if it exists
-func (r *ModelRouter) extractStaticPrompt(systemPrompt string) string {
-	// Find the "Notes:" section
-	notesIndex := strings.Index(systemPrompt, "\nNotes:")
-	if notesIndex == -1 {
-		notesIndex = strings.Index(systemPrompt, "\n\nNotes:")
-	}
+		// A mapping in the config wins over the agent's own model. The model names Claude
+		// Code resolves itself already arrive as the request's model.
+		mapping := r.subagentMappings[agent.Name]
+		targetModel := mapping.Model
+		if targetModel == "" && !claudeCodeAgentModels[agent.Model] {
+			targetModel = agent.Model
+		}
 
-	if notesIndex != -1 {
-		// Return only the part before "Notes:"
-		return strings.TrimSpace(systemPrompt[:notesIndex])
+		def := SubagentDefinition{
+			Name:         agent.Name,
+			Tools:        agent.Tools,
+			Source:       file,
+			FullPrompt:   staticPrompt,
+			Overrides:    agentParameters(mapping.Overrides),
+			SystemAppend: mapping.Overrides.SystemAppend,
+		}
+		if targetModel != "" {
+			def.TargetProvider, def.TargetModel = r.resolveModel(targetModel)
+		}
+		agents = append(agents, parsedAgent{hash: r.hashString(staticPrompt), def: def})
 	}
 
-	// If no "Notes:" section, return the whole prompt
-	return strings.TrimSpace(systemPrompt)
+	r.agentsMu.Lock()
+	defer r.agentsMu.Unlock()
+
+	// Another request may have loaded the directory meanwhile
+	if r.agentDirs[dir] || len(r.agentDirs) >= maxAgentDirs {
+		return false
+	}
+	r.agentDirs[dir] = true
+	for _, agent := range agents {
+		if _, exists := r.customAgentPrompts[agent.hash]; exists {
+			// The first directory searched wins, project agents come before user agents
+			continue
+		}
+		r.customAgentPrompts[agent.hash] = agent.def
+	}
+	return true
 }
 
-func (r *ModelRouter) loadCustomAgents() {
-	for agentName, targetModel := range r.subagentMappings {
-		// Try loading from project level first, then user level
-		paths := []string{
-			fmt.Sprintf(".claude/agents/%s.md", agentName),
-			fmt.Sprintf("%s/.claude/agents/%s.md", os.Getenv("HOME"), agentName),
+// loadPromptAgentDir loads the agents of a working directory named in a system prompt and reports
+// whether it was new. The directory comes from the client, so it is only read when it is in a
+// Claude Code project. One that isn't is checked again after agentDirRecheck, its project may
+// not have had a session log yet.
+func (r *ModelRouter) loadPromptAgentDir(dir string) bool {
+	if !filepath.IsAbs(dir) {
+		return false
+	}
+	dir = filepath.Clean(dir)
+
+	r.agentsMu.RLock()
+	loaded := r.agentDirs[dir]
+	checkedAt, rejected := r.rejectedAgentDirs[dir]
+	r.agentsMu.RUnlock()
+	if loaded || rejected && time.Since(checkedAt) < agentDirRecheck {
+		return false
+	}
+
+	if claudeProjectRoot(r.projectsDir, dir) == "" {
+		r.agentsMu.Lock()
+		if len(r.rejectedAgentDirs) >= maxAgentDirs {
+			r.rejectedAgentDirs = make(map[string]time.Time)
 		}
+		r.rejectedAgentDirs[dir] = time.Now()
+		r.agentsMu.Unlock()
+		return false
+	}
+	return r.loadAgentsFromDir(dir)
+}
 
-		found := false
-		for _, path := range paths {
-			content, err := os.ReadFile(path)
-			if err != nil {
-				continue
-			}
+// AgentDirs lists the directories whose agent definitions were loaded
+func (r *ModelRouter) AgentDirs() []string {
+	r.agentsMu.RLock()
+	defer r.agentsMu.RUnlock()
 
-			// Parse agent file: metadata\n---\nsystem prompt
-			parts := strings.Split(string(content), "\n---\n")
+	dirs := make([]string, 0, len(r.agentDirs))
+	for dir := range r.agentDirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
 
-			if len(parts) >= 2 {
-				systemPrompt := strings.TrimSpace(parts[1])
+// lookupAgent finds the agent with the given prompt hash. When it isn't known yet, the agents
+// of the working directory named in the system prompt are loaded and the lookup is repeated.
+func (r *ModelRouter) lookupAgent(promptHash string, system []model.AnthropicSystemMessage) (SubagentDefinition, bool) {
+	r.agentsMu.RLock()
+	definition, exists := r.customAgentPrompts[promptHash]
+	r.agentsMu.RUnlock()
+	if exists {
+		return definition, true
+	}
 
-				// Extract only the static part (before "Notes:" if it exists)
-				staticPrompt := r.extractStaticPrompt(systemPrompt)
-				hash := r.hashString(staticPrompt)
+	dir := workingDirFromPrompt(system)
+	if dir == "" || !r.loadPromptAgentDir(dir) {
+		return SubagentDefinition{}, false
+	}
 
-				// Determine provider for the target model
-				providerName := r.getProviderNameForModel(targetModel)
+	r.agentsMu.RLock()
+	defer r.agentsMu.RUnlock()
+	definition, exists = r.customAgentPrompts[promptHash]
+	return definition, exists
+}
 
-				r.customAgentPrompts[hash] = SubagentDefinition{
-					Name:           agentName,
-					TargetModel:    targetModel,
-					TargetProvider: providerName,
-					FullPrompt:     staticPrompt,
-				}
-				found = true
-				break
-			}
+func (r *ModelRouter) loadRoutingRules() {
+	for i, rule := range r.config.Routing.Rules {
+		compiled, err := compileRoutingRule(i, rule)
+		if err != nil {
+			r.logger.Printf("⚠️  Routing rule '%s' is invalid and will be skipped: %v", rule.Name, err)
+			continue
 		}
+		r.rules = append(r.rules, compiled)
+	}
 
-		// Log warning if subagent is mapped but definition not found
-		if !found {
-			r.logger.Printf("⚠️  Subagent '%s' is mapped to '%s' but definition file not found in:\n", agentName, targetModel)
-			for _, path := range paths {
-				r.logger.Printf("      - %s\n", path)
+	if len(r.rules) > 0 {
+		r.logger.Println("")
+		r.logger.Println("📏 Routing Rules:")
+		r.logger.Println("──────────────────────────────────────")
+		for _, rule := range r.rules {
+			target := rule.Target
+			if target == "" {
+				target = "(requested model)"
 			}
+			r.logger.Printf("   \033[36m%s\033[0m → \033[32m%s\033[0m", rule.Name, target)
 		}
+		r.logger.Println("──────────────────────────────────────")
+		r.logger.Println("")
 	}
+}
 
-	// Pretty print loaded subagents
-	if len(r.customAgentPrompts) > 0 {
-		r.logger.Println("")
-		r.logger.Println("🤖 Subagent Model Mappings:")
-		r.logger.Println("──────────────────────────────────────")
+// DetermineRoute analyzes the request and returns routing information without modifying the request.
+// Routing rules are checked first, in order, then subagent prompt matching.
+func (r *ModelRouter) DetermineRoute(req *model.AnthropicRequest, header http.Header) (*RoutingDecision, error) {
+	if header == nil {
+		header = http.Header{}
+	}
+	input := &routeInput{req: req, header: header, estimator: r.estimator}
 
-		for _, def := range r.customAgentPrompts {
-			r.logger.Printf("   \033[36m%s\033[0m → \033[32m%s\033[0m",
-				def.Name, def.TargetModel)
+	agent := r.classifyAgent(req)
+
+	decision, err := r.matchRule(input)
+	if err != nil {
+		return nil, err
+	}
+	if decision == nil {
+		decision, err = r.determineTarget(req, agent)
+		if err != nil {
+			return nil, err
 		}
+	}
+	if agent != nil {
+		decision.Agent = agent.Name
+		decision.PromptHash = agent.PromptHash
+	}
 
-		r.logger.Println("──────────────────────────────────────")
-		r.logger.Println("")
+	if r.config.ContextRouting.Enable {
+		if err := r.applyContextLimit(decision, input); err != nil {
+			return nil, err
+		}
 	}
+
+	decision.Fallbacks = r.fallbacksFor(decision.TargetModel)
+	decision.Shadow = r.shadowFor(decision.TargetModel)
+	return decision, nil
 }
 
-// DetermineRoute analyzes the request and returns routing information without modifying the request
-func (r *ModelRouter) DetermineRoute(req *model.AnthropicRequest) (*RoutingDecision, error) {
-	decision := &RoutingDecision{
-		OriginalModel: req.Model,
-		TargetModel:   req.Model, // default to original
+// shadowFor picks the shadow model for a sample of the requests routed to targetModel
+func (r *ModelRouter) shadowFor(targetModel string) *RouteCandidate {
+	cfg := r.config.Shadow
+	if !cfg.Enable {
+		return nil
 	}
 
-	// Check if subagents are enabled
-	if !r.config.Subagents.Enable {
-		// Subagents disabled, use default provider
-		providerName := r.getProviderNameForModel(decision.TargetModel)
-		decision.Provider = r.providers[providerName]
-		if decision.Provider == nil {
-			return nil, fmt.Errorf("no provider found for model %s", decision.TargetModel)
+	target := lookupModelTarget(cfg.Targets, targetModel)
+	if target == "" || r.sample() >= cfg.SampleRate {
+		return nil
+	}
+
+	providerName, modelName := r.resolveModel(target)
+	p := r.providers[providerName]
+	if p == nil {
+		r.logger.Printf("⚠️  Skipping shadow %s → %s: provider %s not configured", targetModel, target, providerName)
+		return nil
+	}
+	if modelName == targetModel {
+		return nil
+	}
+	return &RouteCandidate{Model: modelName, Provider: p}
+}
+
+// fallbacksFor resolves the configured failover chain of a model
+func (r *ModelRouter) fallbacksFor(targetModel string) []RouteCandidate {
+	var fallbacks []RouteCandidate
+	seen := map[string]bool{targetModel: true}
+
+	for _, fallbackModel := range r.config.Failover[targetModel] {
+		if seen[fallbackModel] {
+			continue
+		}
+		seen[fallbackModel] = true
+
+		providerName, modelName := r.resolveModel(fallbackModel)
+		p := r.providers[providerName]
+		if p == nil {
+			r.logger.Printf("⚠️  Skipping failover %s → %s: provider %s not configured", targetModel, fallbackModel, providerName)
+			continue
 		}
-		return decision, nil
+		fallbacks = append(fallbacks, RouteCandidate{Model: modelName, Provider: p})
 	}
 
-	// Claude Code pattern: Check if we have exactly 2 system messages
-	if len(req.System) == 2 {
+	return fallbacks
+}
+
+// matchRule returns the decision of the first routing rule that matches, or nil
+func (r *ModelRouter) matchRule(input *routeInput) (*RoutingDecision, error) {
+	req := input.req
+	for _, rule := range r.rules {
+		if !rule.matches(input) {
+			continue
+		}
+
+		target := rule.Target
+		if target == "" {
+			target = req.Model
+		}
+		providerName, modelName := r.resolveModel(target)
+		p := r.providers[providerName]
+		if p == nil {
+			return nil, fmt.Errorf("provider %s not found for routing rule %s", providerName, rule.Name)
+		}
+
+		r.logger.Printf("📏 rule \033[36m%s\033[0m: %s → \033[32m%s\033[0m", rule.Name, req.Model, modelName)
+		return &RoutingDecision{
+			Provider:      p,
+			OriginalModel: req.Model,
+			TargetModel:   modelName,
+			Reason:        model.RoutingReasonRule,
+			Detail:        fmt.Sprintf("matched routing rule %s", rule.Name),
+			Rule:          rule.Name,
+			Overrides:     rule.Overrides,
+		}, nil
+	}
+	return nil, nil
+}
+
+// agentMatch is the Claude Code agent a request came from
+type agentMatch struct {
+	// Name is model.AgentMain, the name of a subagent or model.AgentUnknown
+	Name       string
+	PromptHash string
+	// Definition is set for subagents loaded from an agent file
+	Definition *SubagentDefinition
+}
 
-		// First should be "You are Claude Code..."
-		if strings.Contains(req.System[0].Text, "You are Claude Code") {
-			// Second message could be either:
-			// 1. A regular Claude Code prompt (no Notes: section)
-			// 2. A subagent prompt (may have Notes: section)
+// classifyAgent tells which Claude Code agent sent the request: the main agent, a subagent from
+// an agent file or one of the built-in subagents. It returns nil for other clients.
+func (r *ModelRouter) classifyAgent(req *model.AnthropicRequest) *agentMatch {
+	// Claude Code pattern: "You are Claude Code..." followed by the agent's own prompt
+	if len(req.System) != 2 || !strings.Contains(req.System[0].Text, "You are Claude Code") {
+		return nil
+	}
 
-			fullPrompt := req.System[1].Text
+	// Extract static portion (before "Notes:" if it exists)
+	staticPrompt := r.extractStaticPrompt(req.System[1].Text)
+	match := &agentMatch{PromptHash: r.hashString(staticPrompt)}
 
-			// Extract static portion (before "Notes:" if it exists)
-			staticPrompt := r.extractStaticPrompt(fullPrompt)
-			promptHash := r.hashString(staticPrompt)
+	if definition, exists := r.lookupAgent(match.PromptHash, req.System); exists {
+		match.Name = definition.Name
+		match.Definition = &definition
+		return match
+	}
 
-			// Check if this matches a known custom agent
-			if definition, exists := r.customAgentPrompts[promptHash]; exists {
-				r.logger.Printf("\033[36m%s\033[0m → \033[32m%s\033[0m",
-					req.Model, definition.TargetModel)
+	match.Name = builtinAgent(staticPrompt)
+	return match
+}
 
-				decision.TargetModel = definition.TargetModel
-				decision.Provider = r.providers[definition.TargetProvider]
-				if decision.Provider == nil {
-					return nil, fmt.Errorf("provider %s not found for model %s",
-						definition.TargetProvider, definition.TargetModel)
-				}
+func (r *ModelRouter) determineTarget(req *model.AnthropicRequest, agent *agentMatch) (*RoutingDecision, error) {
+	decision := &RoutingDecision{
+		OriginalModel: req.Model,
+		TargetModel:   req.Model, // default to original
+		Reason:        model.RoutingReasonDefault,
+	}
 
-				return decision, nil
+	switch {
+	case !r.config.Subagents.Enable:
+		decision.Detail = "subagent routing is disabled"
+	case agent == nil || agent.Name == model.AgentMain:
+		decision.Detail = "not a subagent request"
+	case agent.Definition == nil:
+		decision.Detail = "system prompt matched no mapped subagent"
+	case !agent.Definition.adjustsRequests():
+		decision.Detail = fmt.Sprintf("subagent %s has no model mapping", agent.Name)
+	default:
+		definition := agent.Definition
+		decision.Reason = model.RoutingReasonSubagent
+		decision.Detail = fmt.Sprintf("system prompt matched subagent %s", definition.Name)
+		decision.Overrides = definition.Overrides
+		decision.SystemAppend = definition.SystemAppend
+
+		// A mapping with only overrides keeps the requested model
+		if definition.TargetModel != "" {
+			decision.TargetModel = definition.TargetModel
+			decision.Provider = r.providers[definition.TargetProvider]
+			if decision.Provider == nil {
+				return nil, fmt.Errorf("provider %s not found for model %s",
+					definition.TargetProvider, definition.TargetModel)
 			}
+
+			r.logger.Printf("\033[36m%s\033[0m → \033[32m%s\033[0m",
+				req.Model, decision.TargetModel)
+			return decision, nil
 		}
 	}
 
 	// Default: use the original model and its provider
-	providerName := r.getProviderNameForModel(decision.TargetModel)
+	providerName, modelName := r.resolveModel(decision.TargetModel)
+	decision.TargetModel = modelName
 	decision.Provider = r.providers[providerName]
 	if decision.Provider == nil {
 		return nil, fmt.Errorf("no provider found for model %s", decision.TargetModel)
@@ -256,6 +576,49 @@ func (r *ModelRouter) DetermineRoute(req *model.AnthropicRequest) (*RoutingDecis
 	return decision, nil
 }
 
+// lookupModelTarget finds the entry for a model in a model -> target map: an exact match first,
+// then the matching glob, longest (most specific) first
+func lookupModelTarget(targets map[string]string, modelName string) string {
+	if target, ok := targets[modelName]; ok {
+		return target
+	}
+
+	patterns := make([]string, 0, len(targets))
+	for pattern := range targets {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, modelName); ok {
+			return targets[pattern]
+		}
+	}
+	return ""
+}
+
+// ProviderHealth reports the state of every provider that tracks its upstream health, sorted by name
+func (r *ModelRouter) ProviderHealth() []model.ProviderHealth {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var health []model.ProviderHealth
+	for _, name := range names {
+		if reporter, ok := r.providers[name].(provider.HealthReporter); ok {
+			health = append(health, reporter.Health())
+		}
+	}
+	return health
+}
+
 func (r *ModelRouter) hashString(s string) string {
 	h := sha256.New()
 	h.Write([]byte(s))
@@ -264,9 +627,27 @@ func (r *ModelRouter) hashString(s string) string {
 	return shortHash
 }
 
+// resolveModel splits a routing target into a provider name and the model sent upstream.
+// "name/model" targets a configured provider by name, anything else goes through the model
+// catalog, which also expands aliases.
+func (r *ModelRouter) resolveModel(target string) (providerName, modelName string) {
+	if name, model, found := strings.Cut(target, "/"); found {
+		if _, ok := r.providers[name]; ok {
+			return name, model
+		}
+	}
+	modelName = r.models.Resolve(target)
+	return r.getProviderNameForModel(modelName), modelName
+}
+
+// Models returns the model catalog the router resolves against
+func (r *ModelRouter) Models() config.ModelCatalog {
+	return r.models
+}
+
 func (r *ModelRouter) getProviderNameForModel(model string) string {
-	if provider, exists := r.modelProviderMap[model]; exists {
-		return provider
+	if entry, exists := r.models.Lookup(model); exists && entry.Provider != "" {
+		return entry.Provider
 	}
 
 	// Default to anthropic
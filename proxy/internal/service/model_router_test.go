@@ -1,8 +1,12 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/seifghazi/claude-code-monitor/internal/config"
@@ -14,8 +18,8 @@ func TestModelRouter_EdgeCases(t *testing.T) {
 	// Setup
 	cfg := &config.Config{
 		Subagents: config.SubagentsConfig{
-			Mappings: map[string]string{
-				"streaming-systems-engineer": "gpt-4o",
+			Mappings: map[string]config.SubagentMapping{
+				"streaming-systems-engineer": {Model: "gpt-4o"},
 			},
 		},
 	}
@@ -130,8 +134,394 @@ func TestModelRouter_ExtractStaticPrompt(t *testing.T) {
 	}
 }
 
+type stubProvider struct{ name string }
+
+func (p *stubProvider) Name() string { return p.name }
+
+func (p *stubProvider) ForwardRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func TestModelRouter_Failover(t *testing.T) {
+	cfg := &config.Config{
+		Failover: map[string][]string{
+			"claude-opus-4-1-20250805": {"claude-sonnet-4-5-20250929", "claude-opus-4-1-20250805", "gpt-4o", "unknown-model"},
+		},
+	}
+
+	providers := map[string]provider.Provider{
+		"anthropic": &stubProvider{name: "anthropic"},
+		"openai":    &stubProvider{name: "openai"},
+	}
+	router := NewModelRouter(cfg, providers, log.New(os.Stdout, "test: ", log.LstdFlags))
+
+	decision, err := router.DetermineRoute(&model.AnthropicRequest{Model: "claude-opus-4-1-20250805"}, nil)
+	if err != nil {
+		t.Fatalf("DetermineRoute returned error: %v", err)
+	}
+
+	// The target itself is skipped and unknown models fall back to the anthropic provider
+	expected := []struct{ model, provider string }{
+		{"claude-sonnet-4-5-20250929", "anthropic"},
+		{"gpt-4o", "openai"},
+		{"unknown-model", "anthropic"},
+	}
+	if len(decision.Fallbacks) != len(expected) {
+		t.Fatalf("got %d fallbacks, want %d: %+v", len(decision.Fallbacks), len(expected), decision.Fallbacks)
+	}
+	for i, want := range expected {
+		got := decision.Fallbacks[i]
+		if got.Model != want.model || got.Provider.Name() != want.provider {
+			t.Errorf("fallback %d = %s/%s, want %s/%s", i, got.Model, got.Provider.Name(), want.model, want.provider)
+		}
+	}
+
+	decision, err = router.DetermineRoute(&model.AnthropicRequest{Model: "claude-sonnet-4-5-20250929"}, nil)
+	if err != nil {
+		t.Fatalf("DetermineRoute returned error: %v", err)
+	}
+	if len(decision.Fallbacks) != 0 {
+		t.Errorf("models without a failover chain should have no fallbacks, got %+v", decision.Fallbacks)
+	}
+}
+
+func TestModelRouter_ResolveModel(t *testing.T) {
+	providers := map[string]provider.Provider{
+		"anthropic":   &stubProvider{name: "anthropic"},
+		"openai":      &stubProvider{name: "openai"},
+		"local-llama": &stubProvider{name: "local-llama"},
+	}
+	router := NewModelRouter(&config.Config{}, providers, log.New(os.Stdout, "test: ", log.LstdFlags))
+
+	tests := []struct {
+		target       string
+		wantProvider string
+		wantModel    string
+	}{
+		{"claude-sonnet-4-5-20250929", "anthropic", "claude-sonnet-4-5-20250929"},
+		{"gpt-4o", "openai", "gpt-4o"},
+		{"local-llama/qwen2.5-coder:32b", "local-llama", "qwen2.5-coder:32b"},
+		{"openai/gpt-4o", "openai", "gpt-4o"},
+		{"claude-sonnet-4-5", "anthropic", "claude-sonnet-4-5-20250929"},
+		// Unknown prefixes are part of the model name
+		{"meta-llama/Llama-3-70b", "anthropic", "meta-llama/Llama-3-70b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			providerName, modelName := router.resolveModel(tt.target)
+			if providerName != tt.wantProvider || modelName != tt.wantModel {
+				t.Errorf("resolveModel(%q) = %s, %s, want %s, %s", tt.target, providerName, modelName, tt.wantProvider, tt.wantModel)
+			}
+		})
+	}
+
+	decision, err := router.DetermineRoute(&model.AnthropicRequest{Model: "local-llama/qwen2.5-coder:32b"}, nil)
+	if err != nil {
+		t.Fatalf("DetermineRoute returned error: %v", err)
+	}
+	if decision.Provider.Name() != "local-llama" || decision.TargetModel != "qwen2.5-coder:32b" || decision.OriginalModel != "local-llama/qwen2.5-coder:32b" {
+		t.Errorf("unexpected decision: %+v", decision)
+	}
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0 ||
 		(len(s) > 0 && len(substr) > 0 && s[0:len(substr)] == substr) ||
 		(len(s) > len(substr) && contains(s[1:], substr)))
 }
+
+func TestModelRouter_RoutingRules(t *testing.T) {
+	cfg := &config.Config{
+		Routing: config.RoutingConfig{
+			Rules: []config.RoutingRule{
+				{Name: "broken", Match: config.RuleMatch{System: "("}, Target: "gpt-4o"},
+				{Name: "no-model-override", Overrides: map[string]interface{}{"model": "gpt-4o"}},
+				{
+					Name:      "old-cli",
+					Match:     config.RuleMatch{UserAgentVersion: "<1.0.80"},
+					Target:    "claude-haiku-4-5",
+					Overrides: map[string]interface{}{"temperature": 0.2},
+				},
+				{Name: "web", Match: config.RuleMatch{Model: "claude-opus-*", Tools: []string{"WebSearch"}}, Target: "openai/gpt-4o"},
+				{Name: "team", Match: config.RuleMatch{Headers: map[string]string{"X-Team": "^infra$"}, System: "(?i)reviewer"}},
+				{Name: "huge", Match: config.RuleMatch{MinInputTokens: 1000}, Target: "gpt-4.1"},
+			},
+		},
+	}
+
+	providers := map[string]provider.Provider{
+		"anthropic": &stubProvider{name: "anthropic"},
+		"openai":    &stubProvider{name: "openai"},
+	}
+	router := NewModelRouter(cfg, providers, log.New(os.Stdout, "test: ", log.LstdFlags))
+
+	if len(router.rules) != 4 {
+		t.Fatalf("expected the 2 invalid rules to be skipped, got %d rules", len(router.rules))
+	}
+
+	longText := strings.Repeat("lorem ipsum dolor sit amet ", 500)
+
+	tests := []struct {
+		name      string
+		request   *model.AnthropicRequest
+		header    http.Header
+		wantRule  string
+		wantModel string
+	}{
+		{
+			name:      "user agent version",
+			request:   &model.AnthropicRequest{Model: "claude-sonnet-4-5-20250929"},
+			header:    http.Header{"User-Agent": {"claude-cli/1.0.79 (external, cli)"}},
+			wantRule:  "old-cli",
+			wantModel: "claude-haiku-4-5-20251001",
+		},
+		{
+			name:      "newer user agent falls through",
+			request:   &model.AnthropicRequest{Model: "claude-sonnet-4-5-20250929"},
+			header:    http.Header{"User-Agent": {"claude-cli/1.0.80 (external, cli)"}},
+			wantModel: "claude-sonnet-4-5-20250929",
+		},
+		{
+			name: "model glob and tools",
+			request: &model.AnthropicRequest{
+				Model: "claude-opus-4-1-20250805",
+				Tools: []model.Tool{{Name: "Read"}, {Name: "WebSearch"}},
+			},
+			wantRule:  "web",
+			wantModel: "gpt-4o",
+		},
+		{
+			name:      "missing tool",
+			request:   &model.AnthropicRequest{Model: "claude-opus-4-1-20250805", Tools: []model.Tool{{Name: "Read"}}},
+			wantModel: "claude-opus-4-1-20250805",
+		},
+		{
+			name: "header and system prompt, empty target keeps the model",
+			request: &model.AnthropicRequest{
+				Model:  "claude-sonnet-4-5-20250929",
+				System: []model.AnthropicSystemMessage{{Text: "You are a code Reviewer."}},
+			},
+			header:    http.Header{"X-Team": {"infra"}},
+			wantRule:  "team",
+			wantModel: "claude-sonnet-4-5-20250929",
+		},
+		{
+			name: "input tokens",
+			request: &model.AnthropicRequest{
+				Model:    "claude-sonnet-4-5-20250929",
+				Messages: []model.AnthropicMessage{{Role: "user", Content: longText}},
+			},
+			wantRule:  "huge",
+			wantModel: "gpt-4.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := router.DetermineRoute(tt.request, tt.header)
+			if err != nil {
+				t.Fatalf("DetermineRoute returned error: %v", err)
+			}
+			if decision.Rule != tt.wantRule {
+				t.Errorf("rule = %q, want %q", decision.Rule, tt.wantRule)
+			}
+			if decision.TargetModel != tt.wantModel {
+				t.Errorf("target model = %q, want %q", decision.TargetModel, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestVersionConstraints(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{">=1.0.80 <2.0.0", "1.0.80", true},
+		{">=1.0.80 <2.0.0", "1.0.79", false},
+		{">=1.0.80 <2.0.0", "2.0.0", false},
+		{">1.0", "1.0.1", true},
+		{"<=1.0", "1.0.0", true},
+		{"1.0.80", "1.0.80", true},
+		{"==1.0.80", "1.0.81", false},
+	}
+
+	for _, tt := range tests {
+		constraints, err := parseVersionConstraints(tt.constraint)
+		if err != nil {
+			t.Fatalf("parseVersionConstraints(%q) returned error: %v", tt.constraint, err)
+		}
+		version, ok := parseUserAgentVersion("claude-cli/" + tt.version + " (external, cli)")
+		if !ok {
+			t.Fatalf("failed to parse version %q", tt.version)
+		}
+
+		got := true
+		for _, c := range constraints {
+			got = got && c.allows(version)
+		}
+		if got != tt.want {
+			t.Errorf("%q allows %s = %v, want %v", tt.constraint, tt.version, got, tt.want)
+		}
+	}
+
+	if _, err := parseVersionConstraints(">=abc"); err == nil {
+		t.Error("expected an error for an invalid constraint")
+	}
+}
+
+func TestModelRouter_ContextRouting(t *testing.T) {
+	cfg := &config.Config{
+		Models: config.ModelCatalog{
+			{Match: "small-model", Provider: "anthropic", ContextWindow: 1000},
+			{Match: "other-small-model", Provider: "anthropic", ContextWindow: 1000},
+			{Match: "large-model", Provider: "openai", ContextWindow: 100000},
+		},
+		ContextRouting: config.ContextRoutingConfig{
+			Enable:  true,
+			Targets: map[string]string{"small-*": "large-model"},
+			Reject:  true,
+		},
+	}
+
+	providers := map[string]provider.Provider{
+		"anthropic": &stubProvider{name: "anthropic"},
+		"openai":    &stubProvider{name: "openai"},
+	}
+	router := NewModelRouter(cfg, providers, log.New(os.Stdout, "test: ", log.LstdFlags))
+
+	longText := strings.Repeat("lorem ipsum dolor sit amet ", 500)
+	request := func(modelName, text string) *model.AnthropicRequest {
+		return &model.AnthropicRequest{
+			Model:    modelName,
+			Messages: []model.AnthropicMessage{{Role: "user", Content: text}},
+		}
+	}
+
+	decision, err := router.DetermineRoute(request("small-model", "hello"), nil)
+	if err != nil {
+		t.Fatalf("DetermineRoute returned error: %v", err)
+	}
+	if decision.TargetModel != "small-model" {
+		t.Errorf("short request routed to %s, want small-model", decision.TargetModel)
+	}
+
+	decision, err = router.DetermineRoute(request("small-model", longText), nil)
+	if err != nil {
+		t.Fatalf("DetermineRoute returned error: %v", err)
+	}
+	if decision.TargetModel != "large-model" || decision.Provider.Name() != "openai" {
+		t.Errorf("long request routed to %s (%s), want large-model (openai)", decision.TargetModel, decision.Provider.Name())
+	}
+	if decision.EstimatedInputTokens <= 900 {
+		t.Errorf("estimated %d input tokens, want more than the 900 limit", decision.EstimatedInputTokens)
+	}
+
+	_, err = router.DetermineRoute(request("other-small-model", longText), nil)
+	var contextErr *ContextLengthError
+	if !errors.As(err, &contextErr) {
+		t.Fatalf("expected a ContextLengthError without a long-context target, got %v", err)
+	}
+	if contextErr.Limit != 900 || !strings.HasPrefix(contextErr.Error(), "prompt is too long") {
+		t.Errorf("unexpected error %+v: %v", contextErr, contextErr)
+	}
+}
+
+func TestModelRouter_Shadow(t *testing.T) {
+	cfg := &config.Config{
+		Shadow: config.ShadowConfig{
+			Enable:     true,
+			SampleRate: 0.5,
+			Targets: map[string]string{
+				"claude-opus-*":              "claude-haiku-4-5",
+				"claude-sonnet-4-5-20250929": "claude-sonnet-4-5",
+			},
+		},
+	}
+
+	providers := map[string]provider.Provider{
+		"anthropic": &stubProvider{name: "anthropic"},
+		"openai":    &stubProvider{name: "openai"},
+	}
+	router := NewModelRouter(cfg, providers, log.New(os.Stdout, "test: ", log.LstdFlags))
+
+	tests := []struct {
+		name       string
+		model      string
+		sample     float64
+		wantShadow string
+	}{
+		{"sampled", "claude-opus-4-1-20250805", 0.2, "claude-haiku-4-5-20251001"},
+		{"not sampled", "claude-opus-4-1-20250805", 0.7, ""},
+		{"no target", "gpt-4o", 0.0, ""},
+		{"shadow is the routed model", "claude-sonnet-4-5-20250929", 0.0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router.sample = func() float64 { return tt.sample }
+
+			decision, err := router.DetermineRoute(&model.AnthropicRequest{Model: tt.model}, nil)
+			if err != nil {
+				t.Fatalf("DetermineRoute returned error: %v", err)
+			}
+
+			got := ""
+			if decision.Shadow != nil {
+				got = decision.Shadow.Model
+			}
+			if got != tt.wantShadow {
+				t.Errorf("shadow = %q, want %q", got, tt.wantShadow)
+			}
+		})
+	}
+}
+
+func TestRoutingDecision_Trace(t *testing.T) {
+	cfg := &config.Config{
+		Failover: map[string][]string{"claude-opus-4-1-20250805": {"gpt-4o"}},
+		Routing: config.RoutingConfig{
+			Rules: []config.RoutingRule{{Name: "web", Match: config.RuleMatch{Tools: []string{"WebSearch"}}, Target: "claude-opus-4-1"}},
+		},
+	}
+	providers := map[string]provider.Provider{
+		"anthropic": &stubProvider{name: "anthropic"},
+		"openai":    &stubProvider{name: "openai"},
+	}
+	router := NewModelRouter(cfg, providers, log.New(os.Stdout, "test: ", log.LstdFlags))
+
+	decision, err := router.DetermineRoute(&model.AnthropicRequest{
+		Model: "claude-sonnet-4-5-20250929",
+		Tools: []model.Tool{{Name: "WebSearch"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("DetermineRoute returned error: %v", err)
+	}
+
+	trace := decision.Trace()
+	if trace.Reason != model.RoutingReasonRule || trace.Rule != "web" || trace.Provider != "anthropic" {
+		t.Errorf("unexpected trace %+v", trace)
+	}
+	want := []model.RouteCandidate{
+		{Model: "claude-opus-4-1-20250805", Provider: "anthropic"},
+		{Model: "gpt-4o", Provider: "openai"},
+	}
+	if len(trace.Candidates) != len(want) {
+		t.Fatalf("got candidates %+v, want %+v", trace.Candidates, want)
+	}
+	for i := range want {
+		if trace.Candidates[i] != want[i] {
+			t.Errorf("candidate %d = %+v, want %+v", i, trace.Candidates[i], want[i])
+		}
+	}
+
+	decision, err = router.DetermineRoute(&model.AnthropicRequest{Model: "claude-sonnet-4-5-20250929"}, nil)
+	if err != nil {
+		t.Fatalf("DetermineRoute returned error: %v", err)
+	}
+	if trace := decision.Trace(); trace.Reason != model.RoutingReasonDefault || trace.Detail != "subagent routing is disabled" {
+		t.Errorf("unexpected trace %+v", trace)
+	}
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+)
+
+// The pricing row used when nothing more specific matches a model
+const defaultPricingModel = "default"
+
+// Pricing tiers: regular requests, Message Batches and prompts past the long context threshold
+const (
+	standardPricingTier    = "standard"
+	batchPricingTier       = "batch"
+	longContextPricingTier = "long_context"
+)
+
+// LongContextThreshold is the number of input tokens, cached or not, above which a request is
+// billed at long context rates, for all of its tokens
+const LongContextThreshold = 200000
+
+var (
+	ErrPricingExists   = errors.New("a price for this model, date and tier already exists")
+	ErrPricingNotFound = errors.New("price not found")
+	ErrDefaultPricing  = errors.New("the last standard default price can't be deleted")
+)
+
+// defaultPriceSheet holds the Anthropic and OpenAI list prices seeded into new databases
+//
+//go:embed default_pricing.yaml
+var defaultPriceSheet []byte
+
+// PricingTable resolves the price of a model on a date the same way the usage views do: the
+// row for the exact model, then the longest pricing model that prefixes it, then the longest
+// family that prefixes it, then the default row. Of the dated rows for the matched model the
+// latest one in effect on the date is used, or the earliest one for dates before all of them.
+type PricingTable struct {
+	rows []model.PricingModel
+}
+
+func NewPricingTable(rows []model.PricingModel) *PricingTable {
+	return &PricingTable{rows: rows}
+}
+
+// Lookup returns the standard tier price of a model on a date (YYYY-MM-DD, "" for the latest)
+func (t *PricingTable) Lookup(modelName, date string) (model.PricingModel, bool) {
+	return t.LookupTier(modelName, standardPricingTier, date)
+}
+
+// LookupUsage returns the price a request was billed at. Long context requests use the
+// long_context tier when their model has one and the standard tier otherwise.
+func (t *PricingTable) LookupUsage(modelName, date string, usage UsageTokens) (model.PricingModel, bool) {
+	if usage.LongContext() {
+		if p, ok := t.LookupTier(modelName, longContextPricingTier, date); ok {
+			return p, true
+		}
+	}
+	return t.Lookup(modelName, date)
+}
+
+// LookupTier returns the price of a model in a pricing tier on a date
+func (t *PricingTable) LookupTier(modelName, tier, date string) (model.PricingModel, bool) {
+	var matched string
+	bestRank, bestLength := -1, 0
+	for _, p := range t.rows {
+		if p.PricingTier != tier {
+			continue
+		}
+		rank, length := pricingMatch(p, modelName)
+		switch {
+		case rank < 0:
+		case rank > bestRank, rank == bestRank && length > bestLength, rank == bestRank && length == bestLength && p.Model < matched:
+			matched, bestRank, bestLength = p.Model, rank, length
+		}
+	}
+	if bestRank < 0 {
+		return model.PricingModel{}, false
+	}
+
+	var dated []model.PricingModel
+	for _, p := range t.rows {
+		if p.Model == matched && p.PricingTier == tier {
+			dated = append(dated, p)
+		}
+	}
+	sort.Slice(dated, func(i, j int) bool { return dated[i].PricingDate < dated[j].PricingDate })
+
+	price := dated[0]
+	for _, p := range dated {
+		if date == "" || p.PricingDate <= date {
+			price = p
+		}
+	}
+	return price, true
+}
+
+// pricingMatch ranks how specifically a pricing row matches a model, -1 when it doesn't. length
+// breaks ties between prefix matches, longer is more specific.
+func pricingMatch(p model.PricingModel, modelName string) (rank, length int) {
+	switch {
+	case p.Model == modelName:
+		return 3, len(p.Model)
+	case p.Model == defaultPricingModel:
+		return 0, 0
+	case strings.HasPrefix(modelName, p.Model):
+		return 2, len(p.Model)
+	case p.Family != "" && strings.HasPrefix(modelName, p.Family):
+		return 1, len(p.Family)
+	default:
+		return -1, 0
+	}
+}
+
+// UsageTokens are the billed token counts of a response
+type UsageTokens struct {
+	Input         int64
+	CacheCreation int64
+	CacheRead     int64
+	Cache5m       int64
+	Cache1h       int64
+	Output        int64
+}
+
+// LongContext reports whether the request was past the long context threshold
+func (u UsageTokens) LongContext() bool {
+	return u.Input+u.CacheCreation+u.CacheRead > LongContextThreshold
+}
+
+// Cost prices the tokens the same way the usage_price_breakdown view does. Prices are per
+// million tokens and so is the result, divide by 1e6 for dollars.
+func (u UsageTokens) Cost(p model.PricingModel) float64 {
+	cost := float64(u.Input)*p.InputTokens +
+		float64(u.CacheRead)*p.CacheReadInputTokens +
+		float64(u.Output)*p.OutputTokens
+	if u.Cache5m+u.Cache1h > 0 {
+		cost += float64(u.Cache5m)*p.CacheCreationEphemeral5mInputTokens + float64(u.Cache1h)*p.CacheCreationEphemeral1hInputTokens
+	} else {
+		cost += float64(u.CacheCreation) * p.InputTokens
+	}
+	return cost
+}
+
+// pricingDate is the UTC date of a request timestamp, as the usage views compare it with
+// pricing_date
+func pricingDate(timestamp string) string {
+	if t, err := time.Parse(time.RFC3339, timestamp); err == nil {
+		return t.UTC().Format("2006-01-02")
+	}
+	if len(timestamp) >= 10 {
+		return timestamp[:10]
+	}
+	return ""
+}
+
+// ParsePriceSheet reads a YAML or JSON price sheet, a "models" list in the format GET
+// /api/pricing returns, and validates every entry
+func ParsePriceSheet(data []byte) ([]model.PricingModel, error) {
+	var sheet struct {
+		Models []model.PricingModel `yaml:"models"`
+	}
+	if err := yaml.Unmarshal(data, &sheet); err != nil {
+		return nil, fmt.Errorf("failed to parse price sheet: %w", err)
+	}
+	if len(sheet.Models) == 0 {
+		return nil, fmt.Errorf("price sheet has no models")
+	}
+
+	seen := map[string]int{}
+	for i := range sheet.Models {
+		p := &sheet.Models[i]
+		if err := ValidatePricing(p); err != nil {
+			return nil, fmt.Errorf("entry %d (%s): %w", i+1, p.Model, err)
+		}
+		key := p.Model + " " + p.PricingDate + " " + p.PricingTier
+		if first, ok := seen[key]; ok {
+			return nil, fmt.Errorf("entry %d (%s): same model, pricing_date and pricing_tier as entry %d", i+1, p.Model, first)
+		}
+		seen[key] = i + 1
+	}
+	return sheet.Models, nil
+}
+
+// DefaultPriceSheet returns the built-in Anthropic and OpenAI prices
+func DefaultPriceSheet() ([]model.PricingModel, error) {
+	return ParsePriceSheet(defaultPriceSheet)
+}
+
+// ValidatePricing checks a pricing row before it is written and fills in the defaults the
+// table would: the model as display name, the standard tier and today's date
+func ValidatePricing(p *model.PricingModel) error {
+	p.Model = strings.TrimSpace(p.Model)
+	if p.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if p.DisplayName == "" {
+		p.DisplayName = p.Model
+	}
+
+	switch p.PricingTier {
+	case "":
+		p.PricingTier = standardPricingTier
+	case standardPricingTier, batchPricingTier, longContextPricingTier:
+	default:
+		return fmt.Errorf("unknown pricing_tier %q, expected %s, %s or %s", p.PricingTier, standardPricingTier, batchPricingTier, longContextPricingTier)
+	}
+
+	if p.PricingDate == "" {
+		p.PricingDate = time.Now().UTC().Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", p.PricingDate); err != nil {
+		return fmt.Errorf("pricing_date %q must be a YYYY-MM-DD date", p.PricingDate)
+	}
+
+	prices := map[string]float64{
+		"input_tokens":                             p.InputTokens,
+		"output_tokens":                            p.OutputTokens,
+		"cache_read_input_tokens":                  p.CacheReadInputTokens,
+		"cache_creation_ephemeral_5m_input_tokens": p.CacheCreationEphemeral5mInputTokens,
+		"cache_creation_ephemeral_1h_input_tokens": p.CacheCreationEphemeral1hInputTokens,
+	}
+	names := make([]string, 0, len(prices))
+	for name := range prices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if v := prices[name]; v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("%s must be a price per million tokens of 0 or more", name)
+		}
+	}
+	return nil
+}
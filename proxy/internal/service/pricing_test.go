@@ -0,0 +1,201 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+)
+
+func TestPricingTable_Lookup(t *testing.T) {
+	price := func(modelName, family, date string, input float64) model.PricingModel {
+		return model.PricingModel{Model: modelName, Family: family, PricingDate: date, PricingTier: standardPricingTier, InputTokens: input}
+	}
+	table := NewPricingTable([]model.PricingModel{
+		price("default", "", "2024-01-01", 1),
+		price("claude-sonnet-4-5", "claude-sonnet", "2025-09-29", 3),
+		price("claude-sonnet-4", "claude-sonnet", "2025-05-22", 2.5),
+		price("claude-opus-4", "claude-opus", "2025-05-22", 15),
+		price("claude-opus-4-1", "claude-opus", "2025-08-05", 15),
+		price("gpt-4o", "gpt-4o", "2024-05-13", 5),
+		price("gpt-4o", "gpt-4o", "2024-10-01", 2.5),
+		{Model: "gpt-4o", Family: "gpt-4o", PricingDate: "2024-10-01", PricingTier: "long_context", InputTokens: 9},
+	})
+
+	tests := []struct {
+		name      string
+		model     string
+		date      string
+		wantModel string
+		wantInput float64
+	}{
+		{"exact model", "claude-opus-4-1", "2025-10-01", "claude-opus-4-1", 15},
+		{"longest model prefix", "claude-sonnet-4-5-20250929", "2025-10-01", "claude-sonnet-4-5", 3},
+		{"shorter model prefix", "claude-sonnet-4-20250514", "2025-10-01", "claude-sonnet-4", 2.5},
+		{"family prefix", "claude-opus-5", "2025-10-01", "claude-opus-4", 15},
+		{"default", "gemini-2.5-pro", "2025-10-01", "default", 1},
+		{"price in effect on the date", "gpt-4o-2024-08-06", "2024-09-15", "gpt-4o", 5},
+		{"price changed on the date", "gpt-4o", "2024-10-01", "gpt-4o", 2.5},
+		{"before the first price", "gpt-4o", "2024-01-01", "gpt-4o", 5},
+		{"latest price without a date", "gpt-4o", "", "gpt-4o", 2.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := table.Lookup(tt.model, tt.date)
+			if !ok {
+				t.Fatalf("Lookup(%q, %q) found no price", tt.model, tt.date)
+			}
+			if got.Model != tt.wantModel || got.InputTokens != tt.wantInput {
+				t.Errorf("Lookup(%q, %q) = %s at %v, want %s at %v", tt.model, tt.date, got.Model, got.InputTokens, tt.wantModel, tt.wantInput)
+			}
+		})
+	}
+
+	if got, ok := table.LookupTier("gpt-4o", "long_context", "2025-01-01"); !ok || got.InputTokens != 9 {
+		t.Errorf("LookupTier(long_context) = %+v, %v", got, ok)
+	}
+	if _, ok := NewPricingTable(nil).Lookup("gpt-4o", ""); ok {
+		t.Error("an empty table should not find a price")
+	}
+}
+
+func TestUsageTokens_Cost(t *testing.T) {
+	p := model.PricingModel{
+		InputTokens:                         3,
+		OutputTokens:                        15,
+		CacheReadInputTokens:                0.3,
+		CacheCreationEphemeral5mInputTokens: 3.75,
+		CacheCreationEphemeral1hInputTokens: 6,
+	}
+
+	tests := []struct {
+		name  string
+		usage UsageTokens
+		want  float64
+	}{
+		{"input and output", UsageTokens{Input: 1000, Output: 100}, 3000 + 1500},
+		{"cache read", UsageTokens{CacheRead: 1000}, 300},
+		{"cache creation without ttl breakdown", UsageTokens{CacheCreation: 1000}, 3000},
+		{"cache creation by ttl", UsageTokens{CacheCreation: 3000, Cache5m: 1000, Cache1h: 2000}, 3750 + 12000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.usage.Cost(p); got != tt.want {
+				t.Errorf("Cost = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePricing(t *testing.T) {
+	tests := []struct {
+		name    string
+		price   model.PricingModel
+		wantErr string
+	}{
+		{"valid", model.PricingModel{Model: "gpt-4o", PricingDate: "2024-08-06", PricingTier: "batch", InputTokens: 1.25}, ""},
+		{"defaults", model.PricingModel{Model: " gpt-4o "}, ""},
+		{"missing model", model.PricingModel{InputTokens: 1}, "model is required"},
+		{"unknown tier", model.PricingModel{Model: "gpt-4o", PricingTier: "flex"}, "unknown pricing_tier"},
+		{"date format", model.PricingModel{Model: "gpt-4o", PricingDate: "08/06/2024"}, "YYYY-MM-DD"},
+		{"invalid date", model.PricingModel{Model: "gpt-4o", PricingDate: "2024-02-30"}, "YYYY-MM-DD"},
+		{"negative price", model.PricingModel{Model: "gpt-4o", OutputTokens: -1}, "output_tokens"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.price
+			err := ValidatePricing(&p)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidatePricing returned error: %v", err)
+			}
+			if p.Model != "gpt-4o" || p.DisplayName == "" || p.PricingTier == "" || p.PricingDate == "" {
+				t.Errorf("defaults not filled in: %+v", p)
+			}
+		})
+	}
+}
+
+func TestParsePriceSheet(t *testing.T) {
+	yamlSheet := "models:\n  - model: gpt-4o\n    pricing_date: 2024-08-06\n    input_tokens: 2.5\n    output_tokens: 10\n"
+	prices, err := ParsePriceSheet([]byte(yamlSheet))
+	if err != nil {
+		t.Fatalf("ParsePriceSheet returned error: %v", err)
+	}
+	want := model.PricingModel{Model: "gpt-4o", DisplayName: "gpt-4o", PricingDate: "2024-08-06", PricingTier: standardPricingTier, InputTokens: 2.5, OutputTokens: 10}
+	if len(prices) != 1 || prices[0] != want {
+		t.Errorf("got %+v, want %+v", prices, want)
+	}
+
+	// The format GET /api/pricing returns can be imported again
+	jsonSheet := `{"models": [{"model": "gpt-4o", "pricing_date": "2024-08-06", "input_tokens": 2.5, "output_tokens": 10}]}`
+	if prices, err := ParsePriceSheet([]byte(jsonSheet)); err != nil || len(prices) != 1 || prices[0] != want {
+		t.Errorf("JSON sheet: got %+v, %v", prices, err)
+	}
+
+	for name, sheet := range map[string]string{
+		"empty":     "models: []\n",
+		"invalid":   "models:\n  - model: gpt-4o\n    pricing_tier: flex\n",
+		"duplicate": "models:\n  - {model: gpt-4o, pricing_date: 2024-08-06}\n  - {model: gpt-4o, pricing_date: 2024-08-06}\n",
+	} {
+		if _, err := ParsePriceSheet([]byte(sheet)); err == nil {
+			t.Errorf("%s sheet: expected an error", name)
+		}
+	}
+
+	defaults, err := DefaultPriceSheet()
+	if err != nil {
+		t.Fatalf("built-in price sheet is invalid: %v", err)
+	}
+	table := NewPricingTable(defaults)
+	for _, modelName := range []string{"claude-sonnet-4-5-20250929", "claude-opus-4-1-20250805", "claude-haiku-4-5-20251001", "gpt-4o-2024-08-06", "gpt-5-mini"} {
+		if _, ok := table.Lookup(modelName, ""); !ok {
+			t.Errorf("built-in price sheet has no price for %s", modelName)
+		}
+	}
+}
+
+func TestPricingTable_LookupUsage(t *testing.T) {
+	table := NewPricingTable([]model.PricingModel{
+		{Model: "default", PricingDate: "2024-01-01", PricingTier: standardPricingTier, InputTokens: 1},
+		{Model: "claude-sonnet-4-5", Family: "claude-sonnet", PricingDate: "2025-09-29", PricingTier: standardPricingTier, InputTokens: 3},
+		{Model: "claude-sonnet-4-5", Family: "claude-sonnet", PricingDate: "2025-09-29", PricingTier: longContextPricingTier, InputTokens: 6},
+		{Model: "claude-opus-4-1", Family: "claude-opus", PricingDate: "2025-08-05", PricingTier: standardPricingTier, InputTokens: 15},
+	})
+
+	long := UsageTokens{Input: 10, CacheCreation: 50000, CacheRead: LongContextThreshold}
+	short := UsageTokens{Input: 10, CacheRead: LongContextThreshold - 10}
+
+	tests := []struct {
+		name      string
+		model     string
+		usage     UsageTokens
+		wantTier  string
+		wantInput float64
+	}{
+		{"long context rates", "claude-sonnet-4-5-20250929", long, longContextPricingTier, 6},
+		{"at the threshold", "claude-sonnet-4-5-20250929", short, standardPricingTier, 3},
+		{"no long context rates", "claude-opus-4-1-20250805", long, standardPricingTier, 15},
+		{"default has no long context rates", "gpt-4o", long, standardPricingTier, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := table.LookupUsage(tt.model, "2025-10-01", tt.usage)
+			if !ok {
+				t.Fatalf("LookupUsage(%q) found no price", tt.model)
+			}
+			if got.PricingTier != tt.wantTier || got.InputTokens != tt.wantInput {
+				t.Errorf("got %s at %v, want %s at %v", got.PricingTier, got.InputTokens, tt.wantTier, tt.wantInput)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+)
+
+// Parameters a rule can't override because the proxy itself depends on them
+var protectedOverrides = map[string]bool{"model": true, "stream": true}
+
+var userAgentVersionPattern = regexp.MustCompile(`/v?(\d+(?:\.\d+)*)`)
+
+// routingRule is a config.RoutingRule with its patterns compiled
+type routingRule struct {
+	config.RoutingRule
+	system   *regexp.Regexp
+	headers  map[string]*regexp.Regexp
+	versions []versionConstraint
+}
+
+type versionConstraint struct {
+	op      string
+	version []int
+}
+
+func compileRoutingRule(index int, rule config.RoutingRule) (*routingRule, error) {
+	compiled := &routingRule{RoutingRule: rule}
+	if compiled.Name == "" {
+		compiled.Name = fmt.Sprintf("rule-%d", index+1)
+	}
+
+	if rule.Match.Model != "" {
+		if _, err := path.Match(rule.Match.Model, ""); err != nil {
+			return nil, fmt.Errorf("invalid model pattern %q: %w", rule.Match.Model, err)
+		}
+	}
+
+	if rule.Match.System != "" {
+		re, err := regexp.Compile(rule.Match.System)
+		if err != nil {
+			return nil, fmt.Errorf("invalid system regex: %w", err)
+		}
+		compiled.system = re
+	}
+
+	if len(rule.Match.Headers) > 0 {
+		compiled.headers = make(map[string]*regexp.Regexp, len(rule.Match.Headers))
+		for name, pattern := range rule.Match.Headers {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex for header %s: %w", name, err)
+			}
+			compiled.headers[name] = re
+		}
+	}
+
+	if rule.Match.UserAgentVersion != "" {
+		versions, err := parseVersionConstraints(rule.Match.UserAgentVersion)
+		if err != nil {
+			return nil, err
+		}
+		compiled.versions = versions
+	}
+
+	for key := range rule.Overrides {
+		if protectedOverrides[key] {
+			return nil, fmt.Errorf("%q can't be overridden, use target to change the model", key)
+		}
+	}
+
+	return compiled, nil
+}
+
+// routeInput is what routing decisions look at. The token estimate and the joined system prompt
+// are only computed when something asks for them.
+type routeInput struct {
+	req       *model.AnthropicRequest
+	header    http.Header
+	estimator *TokenEstimator
+
+	tokens      int
+	tokensKnown bool
+	system      *string
+}
+
+func (in *routeInput) inputTokens() int {
+	if !in.tokensKnown {
+		in.tokens = in.estimator.EstimateRequest(in.req)
+		in.tokensKnown = true
+	}
+	return in.tokens
+}
+
+func (in *routeInput) systemPrompt() string {
+	if in.system == nil {
+		texts := make([]string, 0, len(in.req.System))
+		for _, sys := range in.req.System {
+			texts = append(texts, sys.Text)
+		}
+		joined := strings.Join(texts, "\n")
+		in.system = &joined
+	}
+	return *in.system
+}
+
+func (r *routingRule) matches(in *routeInput) bool {
+	m := r.Match
+
+	if m.Model != "" {
+		if ok, _ := path.Match(m.Model, in.req.Model); !ok {
+			return false
+		}
+	}
+
+	if r.system != nil && !r.system.MatchString(in.systemPrompt()) {
+		return false
+	}
+
+	for _, name := range m.Tools {
+		found := false
+		for _, tool := range in.req.Tools {
+			if tool.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for name, re := range r.headers {
+		if !re.MatchString(in.header.Get(name)) {
+			return false
+		}
+	}
+
+	if len(r.versions) > 0 {
+		version, ok := parseUserAgentVersion(in.header.Get("User-Agent"))
+		if !ok {
+			return false
+		}
+		for _, c := range r.versions {
+			if !c.allows(version) {
+				return false
+			}
+		}
+	}
+
+	// Token estimates are the expensive part, checked last
+	if m.MinInputTokens > 0 && in.inputTokens() < m.MinInputTokens {
+		return false
+	}
+	if m.MaxInputTokens > 0 && in.inputTokens() > m.MaxInputTokens {
+		return false
+	}
+
+	return true
+}
+
+// parseVersionConstraints parses space-separated constraints such as ">=1.0.80 <2"
+func parseVersionConstraints(s string) ([]versionConstraint, error) {
+	var constraints []versionConstraint
+	for _, field := range strings.Fields(s) {
+		op := "="
+		for _, candidate := range []string{">=", "<=", "==", ">", "<", "="} {
+			if strings.HasPrefix(field, candidate) {
+				if candidate != "==" {
+					op = candidate
+				}
+				field = strings.TrimPrefix(field, candidate)
+				break
+			}
+		}
+
+		version, ok := parseVersion(field)
+		if !ok {
+			return nil, fmt.Errorf("invalid user_agent_version constraint %q", s)
+		}
+		constraints = append(constraints, versionConstraint{op: op, version: version})
+	}
+	return constraints, nil
+}
+
+func (c versionConstraint) allows(version []int) bool {
+	cmp := compareVersions(version, c.version)
+	switch c.op {
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	default:
+		return cmp == 0
+	}
+}
+
+// parseUserAgentVersion extracts the version of a user agent such as "claude-cli/1.0.80 (external, cli)"
+func parseUserAgentVersion(userAgent string) ([]int, bool) {
+	match := userAgentVersionPattern.FindStringSubmatch(userAgent)
+	if match == nil {
+		return nil, false
+	}
+	return parseVersion(match[1])
+}
+
+func parseVersion(s string) ([]int, bool) {
+	if s == "" {
+		return nil, false
+	}
+	parts := strings.Split(s, ".")
+	version := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		version[i] = n
+	}
+	return version, true
+}
+
+// compareVersions compares dotted versions, missing parts count as 0
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -20,16 +20,26 @@ type StorageService interface {
 	GetAllRequests(modelFilter string) ([]*model.RequestLog, error)
 	GetUsage(page, limit int, sortBy, sortOrder string) ([]model.UsageRecord, int, error)
 	GetPricing() ([]model.PricingModel, error)
+	CreatePricing(p model.PricingModel) error
+	UpdatePricing(p model.PricingModel) error
+	DeletePricing(modelName, pricingDate, pricingTier string) error
+	ImportPricing(prices []model.PricingModel) error
 	GetHourlyUsage() ([]model.HourlyUsage, error)
 	// New methods for week-based pagination and stats
-	GetRequestsSummary(modelFilter, startTime, endTime string) ([]*model.RequestSummary, int, error)
+	GetRequestsSummary(modelFilter, startTime, endTime string, routingFilter model.RoutingFilter) ([]*model.RequestSummary, int, error)
 	GetStats(startDate, endDate string) (*model.DashboardStats, error)
 	GetHourlyStats(startTime, endTime string) (*model.HourlyStatsResponse, error)
 	GetModelStats(startTime, endTime string) (*model.ModelStatsResponse, error)
+	GetAgentStats(startTime, endTime string) (*model.AgentStatsResponse, error)
 	GetLatestRequestDate() (*time.Time, error)
 	// Turns tab methods
 	GetTurns(startTime, endTime, sortBy, sortOrder string) ([]model.TurnSummary, int, error)
 	GetMessageContent(id int64) (*model.MessageContentRecord, error)
+	// Shadow traffic
+	SaveShadowResponse(shadow *model.ShadowResponse) error
+	GetShadowPairs(startTime, endTime string) ([]model.ShadowPair, error)
+	// Budgets
+	GetCostSince(since time.Time, modelPattern, apiKeyHash string) (float64, error)
 	// Live indexing
 	IndexRequest(requestID, timestamp string, body, response json.RawMessage) error
 }
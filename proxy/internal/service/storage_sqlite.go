@@ -64,6 +64,14 @@ func (s *sqliteStorageService) createTables() error {
 		model TEXT,
 		original_model TEXT,
 		routed_model TEXT,
+		attempted_models TEXT,
+		routing_rule TEXT,
+		routing_reason TEXT,
+		routing_agent TEXT,
+		routing_provider TEXT,
+		routing_trace TEXT,
+		parameter_overrides TEXT,
+		api_key_hash TEXT,
 		tokens_input BIGINT,
 		tokens_output BIGINT,
 		tokens_cached BIGINT,
@@ -82,26 +90,44 @@ func (s *sqliteStorageService) createTables() error {
 		cache_creation_ephemeral_5m_input_tokens BIGINT,
 		cache_creation_ephemeral_1h_input_tokens BIGINT,
 		output_tokens BIGINT,
+		reasoning_tokens BIGINT,
 		service_tier TEXT,
+		long_context INTEGER,
+		extra_usage TEXT,
 		request_bytes BIGINT,
 		request_messages BIGINT,
 		response_bytes BIGINT
 	);
 
-	CREATE TABLE IF NOT EXISTS pricing (
-		model TEXT NOT NULL PRIMARY KEY,
-		display_name TEXT NOT NULL,
-		family TEXT NOT NULL,
-		pricing_date DATE NOT NULL DEFAULT CURRENT_DATE,
-		pricing_tier TEXT NOT NULL DEFAULT 'standard',
-		input_tokens REAL NOT NULL DEFAULT 1.00,
-		output_tokens REAL NOT NULL DEFAULT 5.00,
-		cache_read_input_tokens REAL NOT NULL DEFAULT 0.10,
-		cache_creation_ephemeral_5m_input_tokens REAL NOT NULL DEFAULT 1.25,
-		cache_creation_ephemeral_1h_input_tokens REAL NOT NULL DEFAULT 2.00
+	CREATE TABLE IF NOT EXISTS pricing (` + pricingColumns + `);
+
+	CREATE TABLE IF NOT EXISTS request_attempts (
+		request_id TEXT NOT NULL,
+		attempt INTEGER NOT NULL,
+		provider TEXT NOT NULL,
+		model TEXT,
+		status_code INTEGER,
+		error TEXT,
+		duration_ms BIGINT,
+		backoff_ms BIGINT,
+		started_at DATETIME,
+		PRIMARY KEY (request_id, attempt)
 	);
 
-	INSERT OR IGNORE INTO pricing (model, display_name, family) VALUES ('default', 'Default', 'default');
+	CREATE TABLE IF NOT EXISTS shadow_responses (
+		request_id TEXT PRIMARY KEY,
+		model TEXT NOT NULL,
+		provider TEXT NOT NULL,
+		status_code INTEGER,
+		error TEXT,
+		response TEXT,
+		input_tokens BIGINT,
+		cache_creation_input_tokens BIGINT,
+		cache_read_input_tokens BIGINT,
+		output_tokens BIGINT,
+		duration_ms BIGINT,
+		created_at DATETIME
+	);
 	`
 
 	_, err := s.db.Exec(schema)
@@ -109,33 +135,154 @@ func (s *sqliteStorageService) createTables() error {
 		return err
 	}
 
+	// Columns added after the initial schema, existing databases need them before the views are created
+	for _, column := range []string{"routing_rule", "routing_reason", "routing_agent", "routing_provider", "routing_trace", "parameter_overrides", "api_key_hash", "attempted_models"} {
+		if err := s.addColumnIfMissing("requests", column, "TEXT"); err != nil {
+			return err
+		}
+	}
+	// Requests saved before the key hash had its own column still have it in their headers
+	_, err = s.db.Exec(`
+		UPDATE requests
+		SET api_key_hash = COALESCE(json_extract(headers, '$."X-Api-Key"[0]'), json_extract(headers, '$.Authorization[0]'), '')
+		WHERE api_key_hash IS NULL AND json_valid(headers)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to fill in api key hashes: %w", err)
+	}
+
+	if err := s.addColumnIfMissing("usage", "reasoning_tokens", "BIGINT"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("usage", "long_context", "INTEGER"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("usage", "extra_usage", "TEXT"); err != nil {
+		return err
+	}
+	// Flag the usage saved before long context requests were
+	_, err = s.db.Exec(`
+		UPDATE usage SET long_context = COALESCE(input_tokens, 0) + COALESCE(cache_creation_input_tokens, 0) + COALESCE(cache_read_input_tokens, 0) > ?
+		WHERE long_context IS NULL
+	`, LongContextThreshold)
+	if err != nil {
+		return fmt.Errorf("failed to flag long context usage: %w", err)
+	}
+
+	if err := s.migratePricingHistory(); err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO pricing (model, display_name, family)
+		SELECT 'default', 'Default', 'default'
+		WHERE NOT EXISTS (SELECT 1 FROM pricing WHERE model = 'default')
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to seed default pricing: %w", err)
+	}
+	if err := s.seedPriceSheet(); err != nil {
+		return err
+	}
+
 	// Create views (SQLite doesn't support CREATE OR REPLACE VIEW)
 	views := []string{
 		`DROP VIEW IF EXISTS usage_with_pricing`,
+		// Each usage row is priced for the model that answered, see PricingTable for how the
+		// pricing row is picked. Long context requests use the long_context tier when their model
+		// has one. pricing_model is the row that was used, the standard_price_* columns are the
+		// standard tier prices the long context premium is measured against.
 		`CREATE VIEW usage_with_pricing AS
+		WITH usage_models AS (
+			SELECT
+				u.*,
+				COALESCE(u.long_context, 0) as is_long_context,
+				COALESCE(r.timestamp, '') as request_timestamp,
+				COALESCE(r.user_agent, '') as request_user_agent,
+				COALESCE(r.model, '') as request_model,
+				COALESCE(NULLIF(r.routed_model, ''), r.model, '') as priced_model,
+				COALESCE(date(r.timestamp), date('now')) as usage_date
+			FROM usage u
+			LEFT JOIN requests r ON u.id = r.id
+		),
+		matches AS (
+			SELECT
+				m.id,
+				p.rowid as pricing_rowid,
+				p.model,
+				p.pricing_date,
+				p.pricing_tier,
+				CASE
+					WHEN p.model = m.priced_model THEN 3
+					WHEN p.model = 'default' THEN 0
+					WHEN substr(m.priced_model, 1, length(p.model)) = p.model THEN 2
+					ELSE 1
+				END as match_rank,
+				CASE
+					WHEN p.model = 'default' THEN 0
+					WHEN substr(m.priced_model, 1, length(p.model)) = p.model THEN length(p.model)
+					ELSE length(p.family)
+				END as match_length,
+				CASE WHEN p.pricing_date <= m.usage_date THEN p.pricing_date END as effective_date
+			FROM usage_models m
+			JOIN pricing p ON p.pricing_tier IN ('standard', CASE WHEN m.is_long_context = 1 THEN 'long_context' END) AND (
+				p.model IN (m.priced_model, 'default')
+				OR substr(m.priced_model, 1, length(p.model)) = p.model
+				OR (p.family <> '' AND substr(m.priced_model, 1, length(p.family)) = p.family)
+			)
+		),
+		candidates AS (
+			SELECT
+				id,
+				pricing_rowid,
+				pricing_tier,
+				ROW_NUMBER() OVER (
+					PARTITION BY id
+					ORDER BY pricing_tier = 'standard', match_rank DESC, match_length DESC, model,
+						effective_date IS NULL, effective_date DESC, pricing_date
+				) as candidate_rank,
+				ROW_NUMBER() OVER (
+					PARTITION BY id, pricing_tier
+					ORDER BY match_rank DESC, match_length DESC, model,
+						effective_date IS NULL, effective_date DESC, pricing_date
+				) as tier_rank
+			FROM matches
+		)
 		SELECT
-			u.id,
-			COALESCE(u.input_tokens, 0) as input_tokens,
-			COALESCE(u.cache_creation_input_tokens, 0) as cache_creation_input_tokens,
-			COALESCE(u.cache_read_input_tokens, 0) as cache_read_input_tokens,
-			COALESCE(u.cache_creation_ephemeral_5m_input_tokens, 0) as cache_creation_ephemeral_5m_input_tokens,
-			COALESCE(u.cache_creation_ephemeral_1h_input_tokens, 0) as cache_creation_ephemeral_1h_input_tokens,
-			COALESCE(u.output_tokens, 0) as output_tokens,
-			COALESCE(u.service_tier, '') as service_tier,
-			COALESCE(r.timestamp, '') as timestamp,
-			COALESCE(r.user_agent, '') as user_agent,
-			COALESCE(r.model, '') as model,
+			m.id,
+			COALESCE(m.input_tokens, 0) as input_tokens,
+			COALESCE(m.cache_creation_input_tokens, 0) as cache_creation_input_tokens,
+			COALESCE(m.cache_read_input_tokens, 0) as cache_read_input_tokens,
+			COALESCE(m.cache_creation_ephemeral_5m_input_tokens, 0) as cache_creation_ephemeral_5m_input_tokens,
+			COALESCE(m.cache_creation_ephemeral_1h_input_tokens, 0) as cache_creation_ephemeral_1h_input_tokens,
+			COALESCE(m.output_tokens, 0) as output_tokens,
+			COALESCE(m.reasoning_tokens, 0) as reasoning_tokens,
+			COALESCE(m.service_tier, '') as service_tier,
+			m.is_long_context as long_context,
+			m.extra_usage,
+			m.request_timestamp as timestamp,
+			m.request_user_agent as user_agent,
+			m.request_model as model,
+			COALESCE(p.model, '') as pricing_model,
 			p.pricing_date,
 			p.pricing_tier,
-			p.input_tokens as price_input_tokens,
-			p.output_tokens as price_output_tokens,
-			p.cache_read_input_tokens as price_cache_read_input_tokens,
-			p.cache_creation_ephemeral_5m_input_tokens as price_cache_creation_ephemeral_5m_input_tokens,
-			p.cache_creation_ephemeral_1h_input_tokens as price_cache_creation_ephemeral_1h_input_tokens
-		FROM usage u
-		LEFT JOIN requests r ON u.id = r.id
-		INNER JOIN pricing p ON p.model = 'default'`,
+			COALESCE(p.input_tokens, 0) as price_input_tokens,
+			COALESCE(p.output_tokens, 0) as price_output_tokens,
+			COALESCE(p.cache_read_input_tokens, 0) as price_cache_read_input_tokens,
+			COALESCE(p.cache_creation_ephemeral_5m_input_tokens, 0) as price_cache_creation_ephemeral_5m_input_tokens,
+			COALESCE(p.cache_creation_ephemeral_1h_input_tokens, 0) as price_cache_creation_ephemeral_1h_input_tokens,
+			COALESCE(sp.input_tokens, 0) as standard_price_input_tokens,
+			COALESCE(sp.output_tokens, 0) as standard_price_output_tokens,
+			COALESCE(sp.cache_read_input_tokens, 0) as standard_price_cache_read_input_tokens,
+			COALESCE(sp.cache_creation_ephemeral_5m_input_tokens, 0) as standard_price_cache_creation_ephemeral_5m_input_tokens,
+			COALESCE(sp.cache_creation_ephemeral_1h_input_tokens, 0) as standard_price_cache_creation_ephemeral_1h_input_tokens
+		FROM usage_models m
+		LEFT JOIN candidates c ON c.id = m.id AND c.candidate_rank = 1
+		LEFT JOIN pricing p ON p.rowid = c.pricing_rowid
+		LEFT JOIN candidates sc ON sc.id = m.id AND sc.pricing_tier = 'standard' AND sc.tier_rank = 1
+		LEFT JOIN pricing sp ON sp.rowid = sc.pricing_rowid`,
 		`DROP VIEW IF EXISTS usage_price_breakdown`,
+		// long_context_cost is the part of total_cost above what the request would have cost at
+		// standard rates
 		`CREATE VIEW usage_price_breakdown AS
 		WITH costs AS (
 			SELECT
@@ -150,7 +297,17 @@ func (s *sqliteStorageService) createTables() error {
 				cache_read_input_tokens * price_cache_read_input_tokens as cache_read_cost,
 				cache_creation_ephemeral_5m_input_tokens * price_cache_creation_ephemeral_5m_input_tokens as cache_5m_cost,
 				cache_creation_ephemeral_1h_input_tokens * price_cache_creation_ephemeral_1h_input_tokens as cache_1h_cost,
-				output_tokens * price_output_tokens as output_cost
+				output_tokens * price_output_tokens as output_cost,
+				input_tokens * standard_price_input_tokens
+					+ cache_read_input_tokens * standard_price_cache_read_input_tokens
+					+ case
+						when cache_creation_ephemeral_5m_input_tokens + cache_creation_ephemeral_1h_input_tokens > 0 then
+							cache_creation_ephemeral_5m_input_tokens * standard_price_cache_creation_ephemeral_5m_input_tokens
+								+ cache_creation_ephemeral_1h_input_tokens * standard_price_cache_creation_ephemeral_1h_input_tokens
+						else
+							cache_creation_input_tokens * standard_price_input_tokens
+					end
+					+ output_tokens * standard_price_output_tokens as standard_cost
 			FROM usage_with_pricing
 		),
 		costs_with_total AS (
@@ -170,6 +327,7 @@ func (s *sqliteStorageService) createTables() error {
 		)
 		SELECT
 			*,
+			case when long_context = 1 then total_cost - standard_cost else 0 end as long_context_cost,
 			ROUND(100.0 * input_cost / NULLIF(total_cost, 0), 1) as input_pct,
 			ROUND(100.0 * cache_creation_cost / NULLIF(total_cost, 0), 1) as cache_creation_pct,
 			ROUND(100.0 * cache_read_cost / NULLIF(total_cost, 0), 1) as cache_read_pct,
@@ -188,6 +346,103 @@ func (s *sqliteStorageService) createTables() error {
 	return nil
 }
 
+// A model can have one price per date and tier, the row in effect at a request's date prices it
+const pricingColumns = `
+		model TEXT NOT NULL,
+		display_name TEXT NOT NULL,
+		family TEXT NOT NULL,
+		pricing_date DATE NOT NULL DEFAULT CURRENT_DATE,
+		pricing_tier TEXT NOT NULL DEFAULT 'standard',
+		input_tokens REAL NOT NULL DEFAULT 1.00,
+		output_tokens REAL NOT NULL DEFAULT 5.00,
+		cache_read_input_tokens REAL NOT NULL DEFAULT 0.10,
+		cache_creation_ephemeral_5m_input_tokens REAL NOT NULL DEFAULT 1.25,
+		cache_creation_ephemeral_1h_input_tokens REAL NOT NULL DEFAULT 2.00,
+		PRIMARY KEY (model, pricing_date, pricing_tier)
+	`
+
+// seedPriceSheet imports the built-in price sheet into databases that have no model prices
+// yet besides the default row
+func (s *sqliteStorageService) seedPriceSheet() error {
+	var priced int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM pricing WHERE model <> ?", defaultPricingModel).Scan(&priced); err != nil {
+		return fmt.Errorf("failed to count pricing: %w", err)
+	}
+	if priced > 0 {
+		return nil
+	}
+	prices, err := DefaultPriceSheet()
+	if err != nil {
+		return err
+	}
+	return s.ImportPricing(prices)
+}
+
+// migratePricingHistory rebuilds a pricing table keyed by model alone, from before dated
+// prices, with the (model, pricing_date, pricing_tier) key
+func (s *sqliteStorageService) migratePricingHistory() error {
+	var keyColumns int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('pricing') WHERE pk > 0").Scan(&keyColumns); err != nil {
+		return fmt.Errorf("failed to read pricing schema: %w", err)
+	}
+	if keyColumns != 1 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		// The views are created again after the migration
+		"DROP VIEW IF EXISTS usage_price_breakdown",
+		"DROP VIEW IF EXISTS usage_with_pricing",
+		"CREATE TABLE pricing_history (" + pricingColumns + ")",
+		`INSERT INTO pricing_history (model, display_name, family, pricing_date, pricing_tier, input_tokens, output_tokens,
+			cache_read_input_tokens, cache_creation_ephemeral_5m_input_tokens, cache_creation_ephemeral_1h_input_tokens)
+		SELECT model, display_name, family, pricing_date, pricing_tier, input_tokens, output_tokens,
+			cache_read_input_tokens, cache_creation_ephemeral_5m_input_tokens, cache_creation_ephemeral_1h_input_tokens
+		FROM pricing`,
+		"DROP TABLE pricing",
+		"ALTER TABLE pricing_history RENAME TO pricing",
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to migrate pricing table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// addColumnIfMissing adds a column to an existing table, CREATE TABLE IF NOT EXISTS won't do it for older databases
+func (s *sqliteStorageService) addColumnIfMissing(table, column, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read %s schema: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to scan %s schema: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	if _, err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
 func (s *sqliteStorageService) SaveRequest(request *model.RequestLog) (string, error) {
 	headersJSON, err := json.Marshal(request.Headers)
 	if err != nil {
@@ -200,10 +455,34 @@ func (s *sqliteStorageService) SaveRequest(request *model.RequestLog) (string, e
 	}
 
 	query := `
-		INSERT INTO requests (id, timestamp, method, endpoint, headers, body, user_agent, content_type, model, original_model, routed_model)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO requests (id, timestamp, method, endpoint, headers, body, user_agent, content_type, model, original_model, routed_model,
+			routing_rule, routing_reason, routing_agent, routing_provider, routing_trace, parameter_overrides, api_key_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
+	// The reason, agent and provider get their own columns so the summary can filter on them
+	var routingReason, routingAgent, routingProvider string
+	var routingTrace sql.NullString
+	if request.Routing != nil {
+		routingReason = request.Routing.Reason
+		routingAgent = request.Routing.Agent
+		routingProvider = request.Routing.Provider
+		traceJSON, err := json.Marshal(request.Routing)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal routing trace: %w", err)
+		}
+		routingTrace = sql.NullString{String: string(traceJSON), Valid: true}
+	}
+
+	var parameterOverrides sql.NullString
+	if request.ParameterOverrides != nil {
+		overridesJSON, err := json.Marshal(request.ParameterOverrides)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal parameter overrides: %w", err)
+		}
+		parameterOverrides = sql.NullString{String: string(overridesJSON), Valid: true}
+	}
+
 	_, err = s.db.Exec(query,
 		request.RequestID,
 		request.Timestamp,
@@ -216,6 +495,13 @@ func (s *sqliteStorageService) SaveRequest(request *model.RequestLog) (string, e
 		request.Model,
 		request.OriginalModel,
 		request.RoutedModel,
+		request.RoutingRule,
+		routingReason,
+		routingAgent,
+		routingProvider,
+		routingTrace,
+		parameterOverrides,
+		request.APIKeyHash,
 	)
 
 	if err != nil {
@@ -325,6 +611,13 @@ func (s *sqliteStorageService) GetRequests(page, limit int) ([]model.RequestLog,
 }
 
 func (s *sqliteStorageService) ClearRequests() (int, error) {
+	if _, err := s.db.Exec("DELETE FROM request_attempts"); err != nil {
+		return 0, fmt.Errorf("failed to clear request attempts: %w", err)
+	}
+	if _, err := s.db.Exec("DELETE FROM shadow_responses"); err != nil {
+		return 0, fmt.Errorf("failed to clear shadow responses: %w", err)
+	}
+
 	result, err := s.db.Exec("DELETE FROM requests")
 	if err != nil {
 		return 0, fmt.Errorf("failed to clear requests: %w", err)
@@ -399,12 +692,18 @@ func (s *sqliteStorageService) UpdateRequestWithResponse(request *model.RequestL
 		s.saveUsage(request.RequestID, request.Response.Body, requestBytes, requestMessages, responseBytes)
 	}
 
-	query := "UPDATE requests SET response = ?, tokens_input = ?, tokens_output = ?, tokens_cached = ? WHERE id = ?"
-	_, err = s.db.Exec(query, string(responseJSON), tokensInput, tokensOutput, tokensCached, request.RequestID)
+	// routed_model is written again since failover may have moved the request to another model,
+	// attempted_models keeps every model it was sent to
+	query := "UPDATE requests SET response = ?, tokens_input = ?, tokens_output = ?, tokens_cached = ?, routed_model = ?, attempted_models = ? WHERE id = ?"
+	_, err = s.db.Exec(query, string(responseJSON), tokensInput, tokensOutput, tokensCached, request.RoutedModel, request.AttemptedModels, request.RequestID)
 	if err != nil {
 		return fmt.Errorf("failed to update request with response: %w", err)
 	}
 
+	if err := s.saveAttempts(request.RequestID, request.Attempts); err != nil {
+		log.Printf("⚠️ Error saving attempts for %s: %v", request.RequestID, err)
+	}
+
 	statusCode := 0
 	if request.Response != nil {
 		statusCode = request.Response.StatusCode
@@ -415,6 +714,259 @@ func (s *sqliteStorageService) UpdateRequestWithResponse(request *model.RequestL
 	return nil
 }
 
+// saveAttempts stores the upstream attempts made for a request
+func (s *sqliteStorageService) saveAttempts(requestID string, attempts []model.RequestAttempt) error {
+	for _, a := range attempts {
+		_, err := s.db.Exec(`
+			INSERT OR REPLACE INTO request_attempts (request_id, attempt, provider, model, status_code, error, duration_ms, backoff_ms, started_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, requestID, a.Attempt, a.Provider, a.Model, a.StatusCode, a.Error, a.DurationMs, a.BackoffMs, a.StartedAt)
+		if err != nil {
+			return fmt.Errorf("failed to insert attempt %d: %w", a.Attempt, err)
+		}
+	}
+	return nil
+}
+
+// getAttempts loads the upstream attempts made for a request, in order
+func (s *sqliteStorageService) getAttempts(requestID string) ([]model.RequestAttempt, error) {
+	rows, err := s.db.Query(`
+		SELECT attempt, provider, COALESCE(model, ''), COALESCE(status_code, 0), COALESCE(error, ''),
+			COALESCE(duration_ms, 0), COALESCE(backoff_ms, 0), COALESCE(started_at, '')
+		FROM request_attempts
+		WHERE request_id = ?
+		ORDER BY attempt
+	`, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attempts: %w", err)
+	}
+	defer rows.Close()
+
+	var attempts []model.RequestAttempt
+	for rows.Next() {
+		var a model.RequestAttempt
+		if err := rows.Scan(&a.Attempt, &a.Provider, &a.Model, &a.StatusCode, &a.Error, &a.DurationMs, &a.BackoffMs, &a.StartedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan attempt: %w", err)
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+func (s *sqliteStorageService) SaveShadowResponse(shadow *model.ShadowResponse) error {
+	var usage struct {
+		Usage struct {
+			InputTokens              int64 `json:"input_tokens"`
+			CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+			CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+			OutputTokens             int64 `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if len(shadow.Body) > 0 {
+		if err := json.Unmarshal(shadow.Body, &usage); err == nil {
+			shadow.InputTokens = usage.Usage.InputTokens
+			shadow.CacheCreationInputTokens = usage.Usage.CacheCreationInputTokens
+			shadow.CacheReadInputTokens = usage.Usage.CacheReadInputTokens
+			shadow.OutputTokens = usage.Usage.OutputTokens
+		}
+	}
+
+	_, err := s.db.Exec(`
+		INSERT OR REPLACE INTO shadow_responses (
+			request_id, model, provider, status_code, error, response, input_tokens,
+			cache_creation_input_tokens, cache_read_input_tokens, output_tokens, duration_ms, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		shadow.RequestID,
+		shadow.Model,
+		shadow.Provider,
+		shadow.StatusCode,
+		shadow.Error,
+		string(shadow.Body),
+		shadow.InputTokens,
+		shadow.CacheCreationInputTokens,
+		shadow.CacheReadInputTokens,
+		shadow.OutputTokens,
+		shadow.DurationMs,
+		shadow.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert shadow response: %w", err)
+	}
+	return nil
+}
+
+// getShadowResponse loads the shadow response linked to a request, nil if it wasn't mirrored
+func (s *sqliteStorageService) getShadowResponse(requestID string) (*model.ShadowResponse, error) {
+	var shadow model.ShadowResponse
+	var body string
+	err := s.db.QueryRow(`
+		SELECT request_id, model, provider, COALESCE(status_code, 0), COALESCE(error, ''), COALESCE(response, ''),
+			COALESCE(input_tokens, 0), COALESCE(cache_creation_input_tokens, 0), COALESCE(cache_read_input_tokens, 0),
+			COALESCE(output_tokens, 0), COALESCE(duration_ms, 0), COALESCE(created_at, '')
+		FROM shadow_responses
+		WHERE request_id = ?
+	`, requestID).Scan(
+		&shadow.RequestID,
+		&shadow.Model,
+		&shadow.Provider,
+		&shadow.StatusCode,
+		&shadow.Error,
+		&body,
+		&shadow.InputTokens,
+		&shadow.CacheCreationInputTokens,
+		&shadow.CacheReadInputTokens,
+		&shadow.OutputTokens,
+		&shadow.DurationMs,
+		&shadow.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shadow response: %w", err)
+	}
+	if json.Valid([]byte(body)) {
+		shadow.Body = json.RawMessage(body)
+	}
+	return &shadow, nil
+}
+
+// GetShadowPairs lists mirrored requests with their primary and shadow outcomes, newest first.
+// The shadow cost is priced for the shadow model the same way the usage views price requests.
+func (s *sqliteStorageService) GetShadowPairs(startTime, endTime string) ([]model.ShadowPair, error) {
+	query := `
+		SELECT
+			sr.request_id,
+			r.timestamp,
+			COALESCE(NULLIF(r.routed_model, ''), r.model, ''),
+			sr.model,
+			COALESCE(json_extract(r.response, '$.statusCode'), 0),
+			COALESCE(sr.status_code, 0),
+			COALESCE(sr.error, ''),
+			COALESCE(json_extract(r.response, '$.responseTime'), 0),
+			COALESCE(sr.duration_ms, 0),
+			COALESCE(u.output_tokens, 0),
+			COALESCE(sr.output_tokens, 0),
+			COALESCE(u.total_cost, 0),
+			COALESCE(sr.input_tokens, 0),
+			COALESCE(sr.cache_creation_input_tokens, 0),
+			COALESCE(sr.cache_read_input_tokens, 0)
+		FROM shadow_responses sr
+		JOIN requests r ON r.id = sr.request_id
+		LEFT JOIN usage_price_breakdown u ON u.id = sr.request_id
+	`
+	args := []interface{}{}
+	if startTime != "" && endTime != "" {
+		query += " WHERE datetime(r.timestamp) >= datetime(?) AND datetime(r.timestamp) <= datetime(?)"
+		args = append(args, startTime, endTime)
+	}
+	query += " ORDER BY r.timestamp DESC"
+
+	prices, err := s.GetPricing()
+	if err != nil {
+		return nil, err
+	}
+	pricing := NewPricingTable(prices)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shadow responses: %w", err)
+	}
+	defer rows.Close()
+
+	pairs := []model.ShadowPair{}
+	for rows.Next() {
+		var p model.ShadowPair
+		var shadowUsage UsageTokens
+		err := rows.Scan(
+			&p.RequestID,
+			&p.Timestamp,
+			&p.PrimaryModel,
+			&p.ShadowModel,
+			&p.PrimaryStatus,
+			&p.ShadowStatus,
+			&p.ShadowError,
+			&p.PrimaryLatencyMs,
+			&p.ShadowLatencyMs,
+			&p.PrimaryOutputTokens,
+			&p.ShadowOutputTokens,
+			&p.PrimaryCost,
+			&shadowUsage.Input,
+			&shadowUsage.CacheCreation,
+			&shadowUsage.CacheRead,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shadow pair: %w", err)
+		}
+		shadowUsage.Output = p.ShadowOutputTokens
+		if price, ok := pricing.LookupUsage(p.ShadowModel, pricingDate(p.Timestamp), shadowUsage); ok {
+			p.ShadowCost = shadowUsage.Cost(price)
+		}
+		p.LatencyDeltaMs = p.ShadowLatencyMs - p.PrimaryLatencyMs
+		p.CostDelta = p.ShadowCost - p.PrimaryCost
+		pairs = append(pairs, p)
+	}
+	return pairs, rows.Err()
+}
+
+// GetCostSince sums what the requests since a time cost, in the units of usage_price_breakdown.
+// modelPattern is a glob on the routed model and apiKeyHash the hashed key, empty ones match
+// every request. Only the usage of the period is priced, with the same PricingTable rules the
+// view follows, as the view prices the whole history before it can be filtered.
+func (s *sqliteStorageService) GetCostSince(since time.Time, modelPattern, apiKeyHash string) (float64, error) {
+	// Timestamps are stored with the local offset they were written with, so a row at or after
+	// since can be lexically earlier by up to that offset. Comparing with the day before first
+	// lets the timestamp index narrow the scan before the exact comparison.
+	lowerBound := since.UTC().AddDate(0, 0, -1).Format("2006-01-02")
+	query := `
+		SELECT
+			COALESCE(NULLIF(r.routed_model, ''), r.model, ''),
+			COALESCE(date(r.timestamp), date('now')),
+			COALESCE(u.input_tokens, 0), COALESCE(u.cache_creation_input_tokens, 0), COALESCE(u.cache_read_input_tokens, 0),
+			COALESCE(u.cache_creation_ephemeral_5m_input_tokens, 0), COALESCE(u.cache_creation_ephemeral_1h_input_tokens, 0),
+			COALESCE(u.output_tokens, 0)
+		FROM requests r
+		JOIN usage u ON u.id = r.id
+		WHERE r.timestamp >= ? AND datetime(r.timestamp) >= datetime(?)
+	`
+	args := []interface{}{lowerBound, since.Format(time.RFC3339)}
+	if modelPattern != "" {
+		query += " AND COALESCE(NULLIF(r.routed_model, ''), r.model, '') GLOB ?"
+		args = append(args, modelPattern)
+	}
+	if apiKeyHash != "" {
+		query += " AND r.api_key_hash = ?"
+		args = append(args, apiKeyHash)
+	}
+
+	prices, err := s.GetPricing()
+	if err != nil {
+		return 0, err
+	}
+	pricing := NewPricingTable(prices)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query request costs: %w", err)
+	}
+	defer rows.Close()
+
+	var cost float64
+	for rows.Next() {
+		var modelName, date string
+		var usage UsageTokens
+		err := rows.Scan(&modelName, &date, &usage.Input, &usage.CacheCreation, &usage.CacheRead, &usage.Cache5m, &usage.Cache1h, &usage.Output)
+		if err != nil {
+			return 0, fmt.Errorf("failed to scan request cost: %w", err)
+		}
+		if price, ok := pricing.LookupUsage(modelName, date, usage); ok {
+			cost += usage.Cost(price)
+		}
+	}
+	return cost, rows.Err()
+}
+
 func (s *sqliteStorageService) saveUsage(requestID string, responseBody []byte, requestBytes, requestMessages, responseBytes int64) {
 	// Known fields in usage
 	knownFields := map[string]bool{
@@ -423,6 +975,7 @@ func (s *sqliteStorageService) saveUsage(requestID string, responseBody []byte,
 		"cache_read_input_tokens":     true,
 		"cache_creation":              true,
 		"output_tokens":               true,
+		"reasoning_tokens":            true,
 		"service_tier":                true,
 	}
 	knownCacheCreationFields := map[string]bool{
@@ -441,20 +994,32 @@ func (s *sqliteStorageService) saveUsage(requestID string, responseBody []byte,
 		return
 	}
 
-	// Check for unknown fields
-	for key := range respBody.Usage {
+	// Fields without a column, such as server_tool_use, are kept as JSON in extra_usage
+	extra := map[string]interface{}{}
+	for key, value := range respBody.Usage {
 		if !knownFields[key] {
-			log.Printf("WARNING: Unknown usage field: %s", key)
+			extra[key] = value
 		}
 	}
-
-	// Check cache_creation for unknown fields
 	if cacheCreation, ok := respBody.Usage["cache_creation"].(map[string]interface{}); ok {
-		for key := range cacheCreation {
+		unknown := map[string]interface{}{}
+		for key, value := range cacheCreation {
 			if !knownCacheCreationFields[key] {
-				log.Printf("WARNING: Unknown usage.cache_creation field: %s", key)
+				unknown[key] = value
 			}
 		}
+		if len(unknown) > 0 {
+			extra["cache_creation"] = unknown
+		}
+	}
+	var extraUsage interface{}
+	if len(extra) > 0 {
+		extraJSON, err := json.Marshal(extra)
+		if err != nil {
+			log.Printf("WARNING: Failed to encode extra usage fields: %v", err)
+		} else {
+			extraUsage = string(extraJSON)
+		}
 	}
 
 	// Parse with typed struct for insertion
@@ -464,6 +1029,7 @@ func (s *sqliteStorageService) saveUsage(requestID string, responseBody []byte,
 			CacheCreationInputTokens int64  `json:"cache_creation_input_tokens"`
 			CacheReadInputTokens     int64  `json:"cache_read_input_tokens"`
 			OutputTokens             int64  `json:"output_tokens"`
+			ReasoningTokens          int64  `json:"reasoning_tokens"`
 			ServiceTier              string `json:"service_tier"`
 			CacheCreation            struct {
 				Ephemeral5mInputTokens int64 `json:"ephemeral_5m_input_tokens"`
@@ -476,12 +1042,18 @@ func (s *sqliteStorageService) saveUsage(requestID string, responseBody []byte,
 		return
 	}
 
+	longContext := UsageTokens{
+		Input:         usage.Usage.InputTokens,
+		CacheCreation: usage.Usage.CacheCreationInputTokens,
+		CacheRead:     usage.Usage.CacheReadInputTokens,
+	}.LongContext()
+
 	query := `
 		INSERT OR REPLACE INTO usage (
 			id, input_tokens, cache_creation_input_tokens, cache_read_input_tokens,
 			cache_creation_ephemeral_5m_input_tokens, cache_creation_ephemeral_1h_input_tokens,
-			output_tokens, service_tier, request_bytes, request_messages, response_bytes
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			output_tokens, reasoning_tokens, service_tier, long_context, extra_usage, request_bytes, request_messages, response_bytes
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	_, err := s.db.Exec(query,
 		requestID,
@@ -491,7 +1063,10 @@ func (s *sqliteStorageService) saveUsage(requestID string, responseBody []byte,
 		usage.Usage.CacheCreation.Ephemeral5mInputTokens,
 		usage.Usage.CacheCreation.Ephemeral1hInputTokens,
 		usage.Usage.OutputTokens,
+		usage.Usage.ReasoningTokens,
 		usage.Usage.ServiceTier,
+		longContext,
+		extraUsage,
 		requestBytes,
 		requestMessages,
 		responseBytes,
@@ -508,7 +1083,7 @@ func (s *sqliteStorageService) EnsureDirectoryExists() error {
 
 func (s *sqliteStorageService) GetRequestByShortID(shortID string) (*model.RequestLog, string, error) {
 	query := `
-		SELECT id, timestamp, method, endpoint, headers, body, model, user_agent, content_type, prompt_grade, response, original_model, routed_model, tokens_input, tokens_output, tokens_cached
+		SELECT id, timestamp, method, endpoint, headers, body, model, user_agent, content_type, prompt_grade, response, original_model, routed_model, COALESCE(attempted_models, ''), COALESCE(routing_rule, ''), routing_trace, parameter_overrides, tokens_input, tokens_output, tokens_cached
 		FROM requests
 		WHERE id LIKE ?
 		ORDER BY timestamp DESC
@@ -517,7 +1092,7 @@ func (s *sqliteStorageService) GetRequestByShortID(shortID string) (*model.Reque
 
 	var req model.RequestLog
 	var headersJSON, bodyJSON string
-	var promptGradeJSON, responseJSON sql.NullString
+	var promptGradeJSON, responseJSON, routingTraceJSON, parameterOverridesJSON sql.NullString
 	var tokensInput, tokensOutput, tokensCached sql.NullInt64
 
 	err := s.db.QueryRow(query, "%"+shortID).Scan(
@@ -534,6 +1109,10 @@ func (s *sqliteStorageService) GetRequestByShortID(shortID string) (*model.Reque
 		&responseJSON,
 		&req.OriginalModel,
 		&req.RoutedModel,
+		&req.AttemptedModels,
+		&req.RoutingRule,
+		&routingTraceJSON,
+		&parameterOverridesJSON,
 		&tokensInput,
 		&tokensOutput,
 		&tokensCached,
@@ -571,6 +1150,20 @@ func (s *sqliteStorageService) GetRequestByShortID(shortID string) (*model.Reque
 		}
 	}
 
+	if routingTraceJSON.Valid {
+		var trace model.RoutingTrace
+		if err := json.Unmarshal([]byte(routingTraceJSON.String), &trace); err == nil {
+			req.Routing = &trace
+		}
+	}
+
+	if parameterOverridesJSON.Valid {
+		var overrides model.ParameterOverrides
+		if err := json.Unmarshal([]byte(parameterOverridesJSON.String), &overrides); err == nil {
+			req.ParameterOverrides = &overrides
+		}
+	}
+
 	if tokensInput.Valid {
 		req.TokensInput = tokensInput.Int64
 	}
@@ -581,6 +1174,18 @@ func (s *sqliteStorageService) GetRequestByShortID(shortID string) (*model.Reque
 		req.TokensCached = tokensCached.Int64
 	}
 
+	attempts, err := s.getAttempts(req.RequestID)
+	if err != nil {
+		log.Printf("⚠️ Error loading attempts for %s: %v", req.RequestID, err)
+	}
+	req.Attempts = attempts
+
+	shadow, err := s.getShadowResponse(req.RequestID)
+	if err != nil {
+		log.Printf("⚠️ Error loading shadow response for %s: %v", req.RequestID, err)
+	}
+	req.Shadow = shadow
+
 	return &req, req.RequestID, nil
 }
 
@@ -694,8 +1299,26 @@ func (s *sqliteStorageService) Close() error {
 	return s.db.Close()
 }
 
-// GetRequestsSummary returns minimal data for list view with date filtering
-func (s *sqliteStorageService) GetRequestsSummary(modelFilter, startTime, endTime string) ([]*model.RequestSummary, int, error) {
+// routingFilterClauses turns a routing filter into WHERE clauses on the routing columns
+func routingFilterClauses(filter model.RoutingFilter) ([]string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+	for column, value := range map[string]string{
+		"routing_reason":   filter.Reason,
+		"routing_agent":    filter.Agent,
+		"routing_rule":     filter.Rule,
+		"routing_provider": filter.Provider,
+	} {
+		if value != "" {
+			clauses = append(clauses, column+" = ?")
+			args = append(args, value)
+		}
+	}
+	return clauses, args
+}
+
+// GetRequestsSummary returns minimal data for list view with date and routing filtering
+func (s *sqliteStorageService) GetRequestsSummary(modelFilter, startTime, endTime string, routingFilter model.RoutingFilter) ([]*model.RequestSummary, int, error) {
 	// First get total count
 	countQuery := "SELECT COUNT(*) FROM requests"
 	countArgs := []interface{}{}
@@ -711,6 +1334,10 @@ func (s *sqliteStorageService) GetRequestsSummary(modelFilter, startTime, endTim
 		countArgs = append(countArgs, startTime, endTime)
 	}
 
+	routingClauses, routingArgs := routingFilterClauses(routingFilter)
+	whereClauses = append(whereClauses, routingClauses...)
+	countArgs = append(countArgs, routingArgs...)
+
 	if len(whereClauses) > 0 {
 		countQuery += " WHERE " + strings.Join(whereClauses, " AND ")
 	}
@@ -722,7 +1349,9 @@ func (s *sqliteStorageService) GetRequestsSummary(modelFilter, startTime, endTim
 
 	// Then get the data
 	query := `
-		SELECT id, timestamp, method, endpoint, model, original_model, routed_model, response
+		SELECT id, timestamp, method, endpoint, model, original_model, routed_model, COALESCE(attempted_models, ''), COALESCE(routing_rule, ''),
+			COALESCE(routing_reason, ''), COALESCE(routing_agent, ''), COALESCE(routing_provider, ''), response,
+			(SELECT COUNT(*) FROM request_attempts a WHERE a.request_id = requests.id) AS attempts
 		FROM requests
 	`
 	args := []interface{}{}
@@ -738,6 +1367,9 @@ func (s *sqliteStorageService) GetRequestsSummary(modelFilter, startTime, endTim
 		args = append(args, startTime, endTime)
 	}
 
+	queryWhereClauses = append(queryWhereClauses, routingClauses...)
+	args = append(args, routingArgs...)
+
 	if len(queryWhereClauses) > 0 {
 		query += " WHERE " + strings.Join(queryWhereClauses, " AND ")
 	}
@@ -763,7 +1395,13 @@ func (s *sqliteStorageService) GetRequestsSummary(modelFilter, startTime, endTim
 			&sum.Model,
 			&sum.OriginalModel,
 			&sum.RoutedModel,
+			&sum.AttemptedModels,
+			&sum.RoutingRule,
+			&sum.RoutingReason,
+			&sum.RoutingAgent,
+			&sum.Provider,
 			&responseJSON,
+			&sum.Attempts,
 		)
 		if err != nil {
 			continue
@@ -1043,6 +1681,47 @@ func (s *sqliteStorageService) GetModelStats(startTime, endTime string) (*model.
 	return &model.ModelStatsResponse{ModelStats: modelStats}, nil
 }
 
+// GetAgentStats breaks tokens and cost down by the agent each request was attributed to,
+// most expensive first. start and end are optional.
+func (s *sqliteStorageService) GetAgentStats(startTime, endTime string) (*model.AgentStatsResponse, error) {
+	query := `
+		SELECT
+			COALESCE(NULLIF(r.routing_agent, ''), 'unclassified') as agent,
+			COUNT(*),
+			COALESCE(SUM(u.input_tokens), 0),
+			COALESCE(SUM(u.output_tokens), 0),
+			COALESCE(SUM(u.cache_creation_input_tokens), 0),
+			COALESCE(SUM(u.cache_read_input_tokens), 0),
+			COALESCE(SUM(u.total_cost), 0) as total_cost
+		FROM requests r
+		LEFT JOIN usage_price_breakdown u ON u.id = r.id
+	`
+	args := []interface{}{}
+	if startTime != "" && endTime != "" {
+		query += " WHERE datetime(r.timestamp) >= datetime(?) AND datetime(r.timestamp) <= datetime(?)"
+		args = append(args, startTime, endTime)
+	}
+	query += " GROUP BY agent ORDER BY total_cost DESC, agent"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agent stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []model.AgentStats{}
+	for rows.Next() {
+		var a model.AgentStats
+		err := rows.Scan(&a.Agent, &a.Requests, &a.InputTokens, &a.OutputTokens,
+			&a.CacheCreationInputTokens, &a.CacheReadInputTokens, &a.TotalCost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan agent stats: %w", err)
+		}
+		stats = append(stats, a)
+	}
+	return &model.AgentStatsResponse{AgentStats: stats}, rows.Err()
+}
+
 // GetLatestRequestDate returns the timestamp of the most recent request
 func (s *sqliteStorageService) GetLatestRequestDate() (*time.Time, error) {
 	var timestamp string
@@ -1079,10 +1758,13 @@ func (s *sqliteStorageService) GetUsage(page, limit int, sortBy, sortOrder strin
 		"cache_creation_ephemeral_5m_input_tokens": "cache_creation_ephemeral_5m_input_tokens",
 		"cache_creation_ephemeral_1h_input_tokens": "cache_creation_ephemeral_1h_input_tokens",
 		"output_tokens":                            "output_tokens",
+		"reasoning_tokens":                         "reasoning_tokens",
 		"service_tier":                             "service_tier",
 		"timestamp":                                "timestamp",
 		"user_agent":                               "user_agent",
 		"model":                                    "model",
+		"long_context":                             "long_context",
+		"pricing_model":                            "pricing_model",
 		"input_cost":                               "input_cost",
 		"cache_creation_cost":                      "cache_creation_cost",
 		"cache_read_cost":                          "cache_read_cost",
@@ -1090,6 +1772,7 @@ func (s *sqliteStorageService) GetUsage(page, limit int, sortBy, sortOrder strin
 		"cache_1h_cost":                            "cache_1h_cost",
 		"output_cost":                              "output_cost",
 		"total_cost":                               "total_cost",
+		"long_context_cost":                        "long_context_cost",
 	}
 	sortColumn, ok := validColumns[sortBy]
 	if !ok {
@@ -1112,8 +1795,9 @@ func (s *sqliteStorageService) GetUsage(page, limit int, sortBy, sortOrder strin
 		SELECT
 			id, input_tokens, cache_creation_input_tokens, cache_read_input_tokens,
 			cache_creation_ephemeral_5m_input_tokens, cache_creation_ephemeral_1h_input_tokens,
-			output_tokens, service_tier, timestamp, user_agent, model,
-			input_cost, cache_creation_cost, cache_read_cost, cache_5m_cost, cache_1h_cost, output_cost, total_cost,
+			output_tokens, reasoning_tokens, service_tier, timestamp, user_agent, model,
+			long_context, COALESCE(extra_usage, ''), pricing_model, COALESCE(pricing_date, ''), COALESCE(pricing_tier, ''),
+			input_cost, cache_creation_cost, cache_read_cost, cache_5m_cost, cache_1h_cost, output_cost, total_cost, long_context_cost,
 			COALESCE(input_pct, 0), COALESCE(cache_creation_pct, 0), COALESCE(cache_read_pct, 0),
 			COALESCE(cache_5m_pct, 0), COALESCE(cache_1h_pct, 0), COALESCE(output_pct, 0)
 		FROM usage_price_breakdown
@@ -1129,6 +1813,7 @@ func (s *sqliteStorageService) GetUsage(page, limit int, sortBy, sortOrder strin
 	var records []model.UsageRecord
 	for rows.Next() {
 		var rec model.UsageRecord
+		var extraUsage string
 		err := rows.Scan(
 			&rec.ID,
 			&rec.InputTokens,
@@ -1137,10 +1822,16 @@ func (s *sqliteStorageService) GetUsage(page, limit int, sortBy, sortOrder strin
 			&rec.CacheCreationEphemeral5mInputTokens,
 			&rec.CacheCreationEphemeral1hInputTokens,
 			&rec.OutputTokens,
+			&rec.ReasoningTokens,
 			&rec.ServiceTier,
 			&rec.Timestamp,
 			&rec.UserAgent,
 			&rec.Model,
+			&rec.LongContext,
+			&extraUsage,
+			&rec.PricingModel,
+			&rec.PricingDate,
+			&rec.PricingTier,
 			&rec.InputCost,
 			&rec.CacheCreationCost,
 			&rec.CacheReadCost,
@@ -1148,6 +1839,7 @@ func (s *sqliteStorageService) GetUsage(page, limit int, sortBy, sortOrder strin
 			&rec.Cache1hCost,
 			&rec.OutputCost,
 			&rec.TotalCost,
+			&rec.LongContextCost,
 			&rec.InputPct,
 			&rec.CacheCreationPct,
 			&rec.CacheReadPct,
@@ -1158,6 +1850,9 @@ func (s *sqliteStorageService) GetUsage(page, limit int, sortBy, sortOrder strin
 		if err != nil {
 			continue
 		}
+		if extraUsage != "" {
+			rec.ExtraUsage = json.RawMessage(extraUsage)
+		}
 		records = append(records, rec)
 	}
 
@@ -1166,11 +1861,11 @@ func (s *sqliteStorageService) GetUsage(page, limit int, sortBy, sortOrder strin
 
 func (s *sqliteStorageService) GetPricing() ([]model.PricingModel, error) {
 	query := `
-		SELECT model, display_name, family, pricing_date, pricing_tier,
+		SELECT model, display_name, family, date(pricing_date), pricing_tier,
 			input_tokens, output_tokens, cache_read_input_tokens,
 			cache_creation_ephemeral_5m_input_tokens, cache_creation_ephemeral_1h_input_tokens
 		FROM pricing
-		ORDER BY family, model
+		ORDER BY family, model, pricing_tier, pricing_date
 	`
 
 	rows, err := s.db.Query(query)
@@ -1203,6 +1898,107 @@ func (s *sqliteStorageService) GetPricing() ([]model.PricingModel, error) {
 	return models, nil
 }
 
+const pricingUpsert = `
+	INSERT INTO pricing (model, display_name, family, pricing_date, pricing_tier, input_tokens, output_tokens,
+		cache_read_input_tokens, cache_creation_ephemeral_5m_input_tokens, cache_creation_ephemeral_1h_input_tokens)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (model, pricing_date, pricing_tier) DO `
+
+func pricingValues(p model.PricingModel) []interface{} {
+	return []interface{}{p.Model, p.DisplayName, p.Family, p.PricingDate, p.PricingTier, p.InputTokens, p.OutputTokens,
+		p.CacheReadInputTokens, p.CacheCreationEphemeral5mInputTokens, p.CacheCreationEphemeral1hInputTokens}
+}
+
+// CreatePricing adds a validated pricing row, ErrPricingExists when the model already has a
+// price on that date and tier
+func (s *sqliteStorageService) CreatePricing(p model.PricingModel) error {
+	result, err := s.db.Exec(pricingUpsert+"NOTHING", pricingValues(p)...)
+	if err != nil {
+		return fmt.Errorf("failed to create pricing: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrPricingExists
+	}
+	return nil
+}
+
+// UpdatePricing replaces the prices of the row with the same model, date and tier
+func (s *sqliteStorageService) UpdatePricing(p model.PricingModel) error {
+	result, err := s.db.Exec(`
+		UPDATE pricing SET display_name = ?, family = ?, input_tokens = ?, output_tokens = ?, cache_read_input_tokens = ?,
+			cache_creation_ephemeral_5m_input_tokens = ?, cache_creation_ephemeral_1h_input_tokens = ?
+		WHERE model = ? AND pricing_date = ? AND pricing_tier = ?
+	`, p.DisplayName, p.Family, p.InputTokens, p.OutputTokens, p.CacheReadInputTokens,
+		p.CacheCreationEphemeral5mInputTokens, p.CacheCreationEphemeral1hInputTokens,
+		p.Model, p.PricingDate, p.PricingTier)
+	if err != nil {
+		return fmt.Errorf("failed to update pricing: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrPricingNotFound
+	}
+	return nil
+}
+
+// DeletePricing removes a pricing row. The standard default row is what unknown models are
+// priced with, so the last one is kept.
+func (s *sqliteStorageService) DeletePricing(modelName, pricingDate, pricingTier string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if modelName == defaultPricingModel && pricingTier == standardPricingTier {
+		var defaults int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM pricing WHERE model = ? AND pricing_tier = ?", modelName, pricingTier).Scan(&defaults); err != nil {
+			return fmt.Errorf("failed to count default pricing: %w", err)
+		}
+		if defaults <= 1 {
+			return ErrDefaultPricing
+		}
+	}
+
+	result, err := tx.Exec("DELETE FROM pricing WHERE model = ? AND pricing_date = ? AND pricing_tier = ?", modelName, pricingDate, pricingTier)
+	if err != nil {
+		return fmt.Errorf("failed to delete pricing: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrPricingNotFound
+	}
+	return tx.Commit()
+}
+
+// ImportPricing writes validated pricing rows in one transaction, replacing the rows with the
+// same model, date and tier
+func (s *sqliteStorageService) ImportPricing(prices []model.PricingModel) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(pricingUpsert + `UPDATE SET
+		display_name = excluded.display_name,
+		family = excluded.family,
+		input_tokens = excluded.input_tokens,
+		output_tokens = excluded.output_tokens,
+		cache_read_input_tokens = excluded.cache_read_input_tokens,
+		cache_creation_ephemeral_5m_input_tokens = excluded.cache_creation_ephemeral_5m_input_tokens,
+		cache_creation_ephemeral_1h_input_tokens = excluded.cache_creation_ephemeral_1h_input_tokens`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare pricing import: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, p := range prices {
+		if _, err := stmt.Exec(pricingValues(p)...); err != nil {
+			return fmt.Errorf("failed to import pricing for %s: %w", p.Model, err)
+		}
+	}
+	return tx.Commit()
+}
+
 func (s *sqliteStorageService) GetHourlyUsage() ([]model.HourlyUsage, error) {
 	query := `
 		SELECT
@@ -1280,18 +2076,10 @@ func (s *sqliteStorageService) GetTurns(startTime, endTime, sortBy, sortOrder st
 			rcs.response_signature,
 			rcs.response_message_id,
 			COALESCE(u.response_bytes, 0) as response_bytes,
-			CASE
-				WHEN COALESCE(u.input_tokens, 0) + COALESCE(u.cache_creation_input_tokens, 0) > 200000 THEN NULL
-				ELSE COALESCE(u.input_tokens, 0) + COALESCE(u.cache_creation_input_tokens, 0)
-			END as input_tokens,
-			CASE
-				WHEN COALESCE(u.input_tokens, 0) + COALESCE(u.cache_creation_input_tokens, 0) > 200000 THEN NULL
-				ELSE COALESCE(u.output_tokens, 0)
-			END as output_tokens,
-			CASE
-				WHEN COALESCE(u.input_tokens, 0) + COALESCE(u.cache_creation_input_tokens, 0) > 200000 THEN NULL
-				ELSE COALESCE(u.cache_read_input_tokens, 0)
-			END as cache_reads,
+			COALESCE(u.input_tokens, 0) + COALESCE(u.cache_creation_input_tokens, 0) as input_tokens,
+			COALESCE(u.output_tokens, 0) as output_tokens,
+			COALESCE(u.cache_read_input_tokens, 0) as cache_reads,
+			COALESCE(u.long_context, 0) as long_context,
 			COALESCE(json_array_length(r.body, '$.system'), 0) as system_count,
 			COALESCE(json_array_length(r.body, '$.tools'), 0) as tools_count,
 			CASE
@@ -1301,26 +2089,14 @@ func (s *sqliteStorageService) GetTurns(startTime, endTime, sortBy, sortOrder st
 				WHEN mc.role = 'user' AND mc.signature = 'text' AND COALESCE(json_array_length(r.body, '$.tools'), 0) = 0 THEN 'Agent'
 				ELSE 'LLM'
 			END as reason,
+			COALESCE((
+				SELECT SUM(mc2.token_estimate)
+				FROM messages m2
+				JOIN message_content mc2 ON m2.message_id = mc2.id
+				WHERE m2.id = rcs.id AND m2.kind = 0
+			), 0) + COALESCE(rcs.system_tokens, 0) + COALESCE(rcs.tools_tokens, 0) as context_tokens,
+			COALESCE(mc.token_estimate, 0) as last_msg_tokens,
 			CASE
-				WHEN (COALESCE((
-					SELECT SUM(mc2.token_estimate)
-					FROM messages m2
-					JOIN message_content mc2 ON m2.message_id = mc2.id
-					WHERE m2.id = rcs.id AND m2.kind = 0
-				), 0) + COALESCE(rcs.system_tokens, 0) + COALESCE(rcs.tools_tokens, 0)) > 200000 THEN NULL
-				ELSE COALESCE((
-					SELECT SUM(mc2.token_estimate)
-					FROM messages m2
-					JOIN message_content mc2 ON m2.message_id = mc2.id
-					WHERE m2.id = rcs.id AND m2.kind = 0
-				), 0) + COALESCE(rcs.system_tokens, 0) + COALESCE(rcs.tools_tokens, 0)
-			END as context_tokens,
-			CASE
-				WHEN COALESCE(mc.token_estimate, 0) > 200000 THEN NULL
-				ELSE COALESCE(mc.token_estimate, 0)
-			END as last_msg_tokens,
-			CASE
-				WHEN COALESCE(resp_mc.token_estimate, 0) > 200000 THEN NULL
 				WHEN resp_mc.token_estimate > 0 THEN resp_mc.token_estimate + 100
 				ELSE COALESCE(resp_mc.token_estimate, 0)
 			END as response_tokens
@@ -1345,7 +2121,6 @@ func (s *sqliteStorageService) GetTurns(startTime, endTime, sortBy, sortOrder st
 	for rows.Next() {
 		var t model.TurnSummary
 		var streaming, responseMessageID sql.NullInt64
-		var inputTokens, outputTokens, cacheReads, contextTokens, lastMsgTokens, responseTokens sql.NullInt64
 		var stopReason, requestRole, requestSignature, responseRole, responseSignature sql.NullString
 
 		err := rows.Scan(
@@ -1365,15 +2140,16 @@ func (s *sqliteStorageService) GetTurns(startTime, endTime, sortBy, sortOrder st
 			&responseSignature,
 			&responseMessageID,
 			&t.ResponseBytes,
-			&inputTokens,
-			&outputTokens,
-			&cacheReads,
+			&t.InputTokens,
+			&t.OutputTokens,
+			&t.CacheReads,
+			&t.LongContext,
 			&t.SystemCount,
 			&t.ToolsCount,
 			&t.Reason,
-			&contextTokens,
-			&lastMsgTokens,
-			&responseTokens,
+			&t.ContextTokens,
+			&t.LastMsgTokens,
+			&t.ResponseTokens,
 		)
 		if err != nil {
 			continue
@@ -1401,24 +2177,6 @@ func (s *sqliteStorageService) GetTurns(startTime, endTime, sortBy, sortOrder st
 		if responseMessageID.Valid {
 			t.ResponseMessageID = &responseMessageID.Int64
 		}
-		if inputTokens.Valid {
-			t.InputTokens = inputTokens.Int64
-		}
-		if outputTokens.Valid {
-			t.OutputTokens = outputTokens.Int64
-		}
-		if cacheReads.Valid {
-			t.CacheReads = cacheReads.Int64
-		}
-		if contextTokens.Valid {
-			t.ContextTokens = contextTokens.Int64
-		}
-		if lastMsgTokens.Valid {
-			t.LastMsgTokens = lastMsgTokens.Int64
-		}
-		if responseTokens.Valid {
-			t.ResponseTokens = responseTokens.Int64
-		}
 
 		turns = append(turns, t)
 	}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seifghazi/claude-code-monitor/internal/config"
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+)
+
+func TestSQLiteStorage_GetCostSince(t *testing.T) {
+	storage, err := NewSQLiteStorageService(&config.StorageConfig{DBPath: filepath.Join(t.TempDir(), "requests.db")})
+	if err != nil {
+		t.Fatalf("NewSQLiteStorageService returned error: %v", err)
+	}
+	s := storage.(*sqliteStorageService)
+
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	save := func(id string, timestamp time.Time, routedModel, keyHash, usage string) {
+		t.Helper()
+		request := &model.RequestLog{
+			RequestID:   id,
+			Timestamp:   timestamp.Format(time.RFC3339),
+			Method:      "POST",
+			Endpoint:    "/v1/messages",
+			Headers:     map[string][]string{},
+			Body:        map[string]interface{}{},
+			Model:       routedModel,
+			RoutedModel: routedModel,
+			APIKeyHash:  keyHash,
+		}
+		if _, err := s.SaveRequest(request); err != nil {
+			t.Fatalf("SaveRequest returned error: %v", err)
+		}
+		s.saveUsage(id, []byte(usage), 0, 0, 0)
+	}
+
+	const standard = `{"usage": {"input_tokens": 1000, "cache_read_input_tokens": 5000, "output_tokens": 200}}`
+	const longContext = `{"usage": {"input_tokens": 250000, "output_tokens": 1000}}`
+	save("opus", since.Add(time.Minute), "claude-opus-4-1-20250805", "sha256:team", standard)
+	save("sonnet", since.Add(time.Hour), "claude-sonnet-4-5-20250929", "sha256:ci", longContext)
+	// Written in another time zone, still after the start of the period
+	save("remote", since.Add(2*time.Hour).In(time.FixedZone("UTC-10", -10*3600)), "claude-sonnet-4-5-20250929", "sha256:team", standard)
+	save("yesterday", since.Add(-time.Minute), "claude-opus-4-1-20250805", "sha256:team", standard)
+
+	// The cost the view computes for the same requests
+	viewCost := func(ids ...string) float64 {
+		t.Helper()
+		var total float64
+		for _, id := range ids {
+			var cost float64
+			if err := s.db.QueryRow("SELECT total_cost FROM usage_price_breakdown WHERE id = ?", id).Scan(&cost); err != nil {
+				t.Fatalf("failed to read the cost of %s from the view: %v", id, err)
+			}
+			total += cost
+		}
+		return total
+	}
+	if viewCost("sonnet") == 0 || viewCost("opus") == 0 {
+		t.Fatal("the default price sheet should price both models")
+	}
+
+	tests := []struct {
+		name         string
+		since        time.Time
+		modelPattern string
+		apiKeyHash   string
+		want         float64
+	}{
+		{"period", since, "", "", viewCost("opus", "sonnet", "remote")},
+		{"model glob", since, "claude-sonnet-*", "", viewCost("sonnet", "remote")},
+		{"api key", since, "", "sha256:team", viewCost("opus", "remote")},
+		{"model and key", since, "claude-opus-*", "sha256:team", viewCost("opus")},
+		{"earlier period", since.Add(-time.Hour), "", "", viewCost("opus", "sonnet", "remote", "yesterday")},
+		{"nothing matches", since, "gpt-*", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.GetCostSince(tt.since, tt.modelPattern, tt.apiKeyHash)
+			if err != nil {
+				t.Fatalf("GetCostSince returned error: %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("GetCostSince = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
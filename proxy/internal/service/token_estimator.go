@@ -0,0 +1,100 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/tiktoken-go/tokenizer"
+
+	"github.com/seifghazi/claude-code-monitor/internal/model"
+)
+
+// Rough cost of an image or document block, their real size depends on resolution and page count
+const mediaBlockTokens = 1600
+
+// TokenEstimator estimates the input tokens of a request before it is sent. It uses tiktoken's
+// cl100k_base encoding, which is close to but not the same as Claude's tokenizer.
+type TokenEstimator struct {
+	enc tokenizer.Codec
+}
+
+// cl100k is loaded once and shared by the router's estimators and the indexer
+var cl100k = sync.OnceValue(func() tokenizer.Codec {
+	enc, err := tokenizer.Get(tokenizer.Cl100kBase)
+	if err != nil {
+		log.Printf("⚠️ Failed to initialize tokenizer, token estimates will be 0: %v", err)
+		return nil
+	}
+	return enc
+})
+
+func NewTokenEstimator() *TokenEstimator {
+	return &TokenEstimator{enc: cl100k()}
+}
+
+// EstimateRequest counts the tokens of the system prompt, messages and tool definitions
+func (e *TokenEstimator) EstimateRequest(req *model.AnthropicRequest) int {
+	if e.enc == nil {
+		return 0
+	}
+
+	total := 0
+	for _, sys := range req.System {
+		total += e.count(sys.Text)
+	}
+	for _, msg := range req.Messages {
+		total += e.estimateContent(msg.Content)
+	}
+	if len(req.Tools) > 0 {
+		if toolsJSON, err := json.Marshal(req.Tools); err == nil {
+			total += e.count(string(toolsJSON))
+		}
+	}
+	return total
+}
+
+func (e *TokenEstimator) estimateContent(content interface{}) int {
+	switch v := content.(type) {
+	case string:
+		return e.count(v)
+	case []interface{}:
+		total := 0
+		for _, item := range v {
+			block, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch block["type"] {
+			case "image", "document":
+				total += mediaBlockTokens
+				continue
+			}
+			for key, val := range block {
+				switch key {
+				case "text", "thinking":
+					if s, ok := val.(string); ok {
+						total += e.count(s)
+					}
+				case "content": // tool_result, a string or nested blocks
+					total += e.estimateContent(val)
+				case "input": // tool_use
+					if inputJSON, err := json.Marshal(val); err == nil {
+						total += e.count(string(inputJSON))
+					}
+				}
+			}
+		}
+		return total
+	default:
+		return 0
+	}
+}
+
+func (e *TokenEstimator) count(text string) int {
+	if text == "" || e.enc == nil {
+		return 0
+	}
+	ids, _, _ := e.enc.Encode(text)
+	return len(ids)
+}
@@ -32,6 +32,10 @@ interface UsageRecord {
   timestamp: string;
   user_agent: string;
   model: string;
+  long_context: boolean;
+  pricing_model: string;
+  pricing_date: string;
+  pricing_tier: string;
   input_cost: number;
   cache_creation_cost: number;
   cache_read_cost: number;
@@ -39,6 +43,7 @@ interface UsageRecord {
   cache_1h_cost: number;
   output_cost: number;
   total_cost: number;
+  long_context_cost: number;
   input_pct: number;
   cache_creation_pct: number;
   cache_read_pct: number;
@@ -369,8 +374,13 @@ export default function TokensIndex() {
                 </thead>
                 <tbody className="divide-y divide-gray-200">
                   {pricingModels.map((pricing) => (
-                    <tr key={pricing.model} className="hover:bg-gray-50">
-                      <td className="px-3 py-2 font-medium text-gray-900">{pricing.display_name}</td>
+                    <tr key={`${pricing.model}-${pricing.pricing_tier}-${pricing.pricing_date}`} className="hover:bg-gray-50">
+                      <td className="px-3 py-2 font-medium text-gray-900" title={`${pricing.model}, from ${pricing.pricing_date}`}>
+                        {pricing.display_name}
+                        {pricing.pricing_tier !== 'standard' && (
+                          <span className="ml-1 text-xs text-gray-500">({pricing.pricing_tier.replace('_', ' ')})</span>
+                        )}
+                      </td>
                       <td className="px-3 py-2 text-right font-mono text-gray-700">${pricing.input_tokens.toFixed(2)}</td>
                       <td className="px-3 py-2 text-right font-mono text-gray-700">${pricing.output_tokens.toFixed(2)}</td>
                       <td className="px-3 py-2 text-right font-mono text-green-600">${pricing.cache_read_input_tokens.toFixed(2)}</td>
@@ -737,7 +747,7 @@ export default function TokensIndex() {
                       </td>
                       <td className="px-3 py-2 border-r border-gray-200">
                         {record.model ? (
-                          <span className={`font-medium ${
+                          <span title={record.pricing_model ? `Priced as ${record.pricing_model} (${record.pricing_tier}, ${record.pricing_date})` : undefined} className={`font-medium ${
                             record.model.includes('opus') ? 'text-purple-600' :
                             record.model.includes('sonnet') ? 'text-indigo-600' :
                             record.model.includes('haiku') ? 'text-teal-600' : 'text-gray-700'
@@ -747,6 +757,14 @@ export default function TokensIndex() {
                              record.model.includes('haiku') ? 'Haiku' : record.model}
                           </span>
                         ) : '-'}
+                        {record.long_context && (
+                          <span
+                            className="ml-1 text-xs text-amber-700"
+                            title={`Long context, $${(record.long_context_cost / 1000000).toFixed(4)} above standard rates`}
+                          >
+                            LC
+                          </span>
+                        )}
                       </td>
                       {/* Token columns */}
                       <td className="px-3 py-2 font-mono text-right text-gray-700">
@@ -33,6 +33,7 @@ interface Turn {
   inputTokens: number;
   outputTokens: number;
   cacheReads: number;
+  longContext: boolean;
   systemCount: number;
   toolsCount: number;
   reason: string;
@@ -1422,7 +1423,10 @@ export default function TurnsIndex() {
                       <td className="px-3 py-2 text-right font-mono text-blue-600">
                         {formatTokenCount(turn.responseTokens)}
                       </td>
-                      <td className="px-3 py-2 text-right font-mono text-gray-700 border-l border-gray-200">
+                      <td
+                        className={`px-3 py-2 text-right font-mono border-l border-gray-200 ${turn.longContext ? 'text-amber-700' : 'text-gray-700'}`}
+                        title={turn.longContext ? 'Long context request, billed at long context rates' : undefined}
+                      >
                         {formatTokenCount(turn.inputTokens)}
                       </td>
                       <td className="px-3 py-2 text-right font-mono text-gray-700">